@@ -307,48 +307,9 @@ func (m *Mapping) queryColumnInfo(schema, table string, columns []string) (*mapp
 	var info = &mappingInfo{
 		ignore: true,
 	}
-	rules := m.Match(schema, table)
-	if len(rules) == 0 {
-		m.cache.Lock()
-		m.cache.infos[tableName(schema, table)] = info
-		m.cache.Unlock()
-
-		return info, nil
-	}
-
-	var (
-		schemaRules []*Rule
-		tableRules  = make([]*Rule, 0, 1)
-	)
-	// classify rules into schema level rules and table level
-	// table level rules have highest priority
-	for i := range rules {
-		rule, ok := rules[i].(*Rule)
-		if !ok {
-			return nil, errors.NotValidf("column mapping rule %+v", rules[i])
-		}
-
-		if len(rule.PatternTable) == 0 {
-			schemaRules = append(schemaRules, rule)
-		} else {
-			tableRules = append(tableRules, rule)
-		}
-	}
-
-	// only support one expression for one table now, refine it later
-	var rule *Rule
-	if len(table) == 0 || len(tableRules) == 0 {
-		if len(schemaRules) != 1 {
-			return nil, errors.NotSupportedf("`%s`.`%s` matches %d schema column mapping rules which should be one. It's", schema, table, len(schemaRules))
-		}
-
-		rule = schemaRules[0]
-	} else {
-		if len(tableRules) != 1 {
-			return nil, errors.NotSupportedf("`%s`.`%s` matches %d table column mapping rules which should be one. It's", schema, table, len(tableRules))
-		}
-
-		rule = tableRules[0]
+	rule, err := m.matchRule(schema, table)
+	if err != nil {
+		return nil, errors.Trace(err)
 	}
 	if rule == nil {
 		m.cache.Lock()
@@ -362,7 +323,7 @@ func (m *Mapping) queryColumnInfo(schema, table string, columns []string) (*mapp
 	sourcePosition := findColumnPosition(columns, rule.SourceColumn)
 	targetPosition := findColumnPosition(columns, rule.TargetColumn)
 
-	sourcePosition, targetPosition, err := rule.adjustColumnPosition(sourcePosition, targetPosition)
+	sourcePosition, targetPosition, err = rule.adjustColumnPosition(sourcePosition, targetPosition)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -388,6 +349,62 @@ func (m *Mapping) queryColumnInfo(schema, table string, columns []string) (*mapp
 	return info, nil
 }
 
+// QueryRule returns the rule used to map the table's row value, returns nil if the table doesn't match any rule
+func (m *Mapping) QueryRule(schema, table string) (*Rule, error) {
+	if m == nil {
+		return nil, nil
+	}
+
+	if !m.caseSensitive {
+		schema, table = strings.ToLower(schema), strings.ToLower(table)
+	}
+
+	rule, err := m.matchRule(schema, table)
+	return rule, errors.Trace(err)
+}
+
+// matchRule returns the only rule matched by the table, the table level rule has higher priority than the schema level rule
+func (m *Mapping) matchRule(schema, table string) (*Rule, error) {
+	rules := m.Match(schema, table)
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	var (
+		schemaRules []*Rule
+		tableRules  = make([]*Rule, 0, 1)
+	)
+	// classify rules into schema level rules and table level
+	// table level rules have highest priority
+	for i := range rules {
+		rule, ok := rules[i].(*Rule)
+		if !ok {
+			return nil, errors.NotValidf("column mapping rule %+v", rules[i])
+		}
+
+		if len(rule.PatternTable) == 0 {
+			schemaRules = append(schemaRules, rule)
+		} else {
+			tableRules = append(tableRules, rule)
+		}
+	}
+
+	// only support one expression for one table now, refine it later
+	if len(table) == 0 || len(tableRules) == 0 {
+		if len(schemaRules) != 1 {
+			return nil, errors.NotSupportedf("`%s`.`%s` matches %d schema column mapping rules which should be one. It's", schema, table, len(schemaRules))
+		}
+
+		return schemaRules[0], nil
+	}
+
+	if len(tableRules) != 1 {
+		return nil, errors.NotSupportedf("`%s`.`%s` matches %d table column mapping rules which should be one. It's", schema, table, len(tableRules))
+	}
+
+	return tableRules[0], nil
+}
+
 func (m *Mapping) resetCache() {
 	m.cache.Lock()
 	m.cache.infos = make(map[string]*mappingInfo)
@@ -282,3 +282,36 @@ func (t *testColumnMappingSuit) TestCaseSensitive(c *C) {
 	c.Assert(vals, DeepEquals, []interface{}{1, "1"})
 	c.Assert(poss, IsNil)
 }
+
+func (t *testColumnMappingSuit) TestQueryRule(c *C) {
+	rules := []*Rule{
+		{"test*", "", "", "id", AddPrefix, []string{"instance_id:"}, ""},
+		{"test*", "t1", "", "id", PartitionID, []string{"1", "test", "t"}, ""},
+		{"dup*", "", "", "id", AddPrefix, []string{"a"}, ""},
+		{"du*", "", "", "name", AddSuffix, []string{"b"}, ""},
+	}
+
+	m, err := NewMapping(false, rules)
+	c.Assert(err, IsNil)
+
+	// the table level rule has higher priority
+	rule, err := m.QueryRule("Test_1", "T1")
+	c.Assert(err, IsNil)
+	c.Assert(rule, Equals, rules[1])
+
+	rule, err = m.QueryRule("test_1", "t2")
+	c.Assert(err, IsNil)
+	c.Assert(rule, Equals, rules[0])
+
+	rule, err = m.QueryRule("abc", "t1")
+	c.Assert(err, IsNil)
+	c.Assert(rule, IsNil)
+
+	_, err = m.QueryRule("dup_1", "t1")
+	c.Assert(err, NotNil)
+
+	var nilMapping *Mapping
+	rule, err = nilMapping.QueryRule("test_1", "t1")
+	c.Assert(err, IsNil)
+	c.Assert(rule, IsNil)
+}
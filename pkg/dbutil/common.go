@@ -18,6 +18,8 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"math"
+	"math/big"
 	"net/url"
 	"os"
 	"strconv"
@@ -173,6 +175,11 @@ func GetCreateTableSQL(ctx context.Context, db QueryExecutor, schemaName string,
 // GetRowCount returns row count of the table.
 // if not specify where condition, return total row count of the table.
 func GetRowCount(ctx context.Context, db QueryExecutor, schemaName string, tableName string, where string, args []interface{}) (int64, error) {
+	return GetRowCountFrom(ctx, db, TableName(schemaName, tableName), where, args)
+}
+
+// GetRowCountFrom is same as GetRowCount, but counts the rows from the table expression, for example a derived table returned by DerivedTable.
+func GetRowCountFrom(ctx context.Context, db QueryExecutor, from string, where string, args []interface{}) (int64, error) {
 	/*
 		select count example result:
 		mysql> SELECT count(1) cnt from `test`.`itest` where id > 0;
@@ -183,7 +190,7 @@ func GetRowCount(ctx context.Context, db QueryExecutor, schemaName string, table
 		+------+
 	*/
 
-	query := fmt.Sprintf("SELECT COUNT(1) cnt FROM %s", TableName(schemaName, tableName))
+	query := fmt.Sprintf("SELECT COUNT(1) cnt FROM %s", from)
 	if len(where) > 0 {
 		query += fmt.Sprintf(" WHERE %s", where)
 	}
@@ -195,12 +202,31 @@ func GetRowCount(ctx context.Context, db QueryExecutor, schemaName string, table
 		return 0, errors.Trace(err)
 	}
 	if !cnt.Valid {
-		return 0, errors.NotFoundf("table `%s`.`%s`", schemaName, tableName)
+		return 0, errors.NotFoundf("table %s", from)
 	}
 
 	return cnt.Int64, nil
 }
 
+// GetColumnsFrom returns the column names of the rows from the table expression, for example a derived table returned by DerivedTable.
+func GetColumnsFrom(ctx context.Context, db QueryExecutor, from string) ([]string, error) {
+	query := fmt.Sprintf("SELECT * FROM %s LIMIT 0", from)
+	log.Debug("get columns", zap.String("sql", query))
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return columns, errors.Trace(rows.Err())
+}
+
 // GetRandomValues returns some random value. Tips: limitArgs is the value in limitRange.
 func GetRandomValues(ctx context.Context, db QueryExecutor, schemaName, table, column string, num int, limitRange string, limitArgs []interface{}, collation string) ([]string, error) {
 	/*
@@ -420,38 +446,150 @@ func GetSchemas(ctx context.Context, db QueryExecutor) ([]string, error) {
 
 // GetCRC32Checksum returns checksum code of some data by given condition
 func GetCRC32Checksum(ctx context.Context, db QueryExecutor, schemaName, tableName string, tbInfo *model.TableInfo, limitRange string, args []interface{}) (int64, error) {
+	checksum, _, err := GetCRC32ChecksumFrom(ctx, db, TableName(schemaName, tableName), tbInfo, nil, limitRange, args)
+	return checksum, errors.Trace(err)
+}
+
+// GetCRC32ChecksumFrom is same as GetCRC32Checksum, but calculates the checksum of the rows from the table expression,
+// and also returns the num of the rows. the column's checksum is calculated by the expression in columnExprs if exists,
+// columnExprs is column name => expression, for example "id" => "`id` + 100".
+func GetCRC32ChecksumFrom(ctx context.Context, db QueryExecutor, from string, tbInfo *model.TableInfo, columnExprs map[string]string, limitRange string, args []interface{}) (int64, int64, error) {
 	/*
 		calculate CRC32 checksum example:
-		mysql> SELECT BIT_XOR(CAST(CRC32(CONCAT_WS(',', id, name, age, CONCAT(ISNULL(id), ISNULL(name), ISNULL(age))))AS UNSIGNED)) AS checksum FROM test.test WHERE id > 0 AND id < 10;
-		+------------+
-		| checksum   |
-		+------------+
-		| 1466098199 |
-		+------------+
+		mysql> SELECT BIT_XOR(CAST(CRC32(CONCAT_WS(',', id, name, age, CONCAT(ISNULL(id), ISNULL(name), ISNULL(age))))AS UNSIGNED)) AS checksum, COUNT(*) AS cnt FROM test.test WHERE id > 0 AND id < 10;
+		+------------+-----+
+		| checksum   | cnt |
+		+------------+-----+
+		| 1466098199 |   9 |
+		+------------+-----+
 	*/
+	query := fmt.Sprintf("SELECT BIT_XOR(CAST(CRC32(%s)AS UNSIGNED)) AS checksum, COUNT(*) AS cnt FROM %s WHERE %s;",
+		rowConcatExpr(tbInfo, columnExprs), from, limitRange)
+	log.Debug("checksum", zap.String("sql", query), zap.Reflect("args", args))
+
+	var (
+		checksum sql.NullInt64
+		count    int64
+	)
+	err := db.QueryRowContext(ctx, query, args...).Scan(&checksum, &count)
+	if err != nil {
+		return -1, 0, errors.Trace(err)
+	}
+	if !checksum.Valid {
+		// if don't have any data, the checksum will be `NULL`
+		log.Warn("get empty checksum", zap.String("sql", query), zap.Reflect("args", args))
+		return 0, 0, nil
+	}
+
+	return checksum.Int64, count, nil
+}
+
+// rowConcatExpr returns the expression concats all the columns' value of a row, used to calculate the row's hash.
+func rowConcatExpr(tbInfo *model.TableInfo, columnExprs map[string]string) string {
 	columnNames := make([]string, 0, len(tbInfo.Columns))
 	columnIsNull := make([]string, 0, len(tbInfo.Columns))
 	for _, col := range tbInfo.Columns {
-		columnNames = append(columnNames, ColumnName(col.Name.O))
-		columnIsNull = append(columnIsNull, fmt.Sprintf("ISNULL(%s)", ColumnName(col.Name.O)))
+		expr, ok := columnExprs[col.Name.O]
+		if !ok {
+			expr = ColumnName(col.Name.O)
+		}
+		columnNames = append(columnNames, expr)
+		columnIsNull = append(columnIsNull, fmt.Sprintf("ISNULL(%s)", expr))
 	}
 
-	query := fmt.Sprintf("SELECT BIT_XOR(CAST(CRC32(CONCAT_WS(',', %s, CONCAT(%s)))AS UNSIGNED)) AS checksum FROM %s WHERE %s;",
-		strings.Join(columnNames, ", "), strings.Join(columnIsNull, ", "), TableName(schemaName, tableName), limitRange)
+	return fmt.Sprintf("CONCAT_WS(',', %s, CONCAT(%s))", strings.Join(columnNames, ", "), strings.Join(columnIsNull, ", "))
+}
+
+// RowsChecksum is an order-independent checksum of some rows. every row's MD5 is split into two 64-bit halves,
+// and the halves of all the rows are summed up modulo 2^64, so duplicate rows will not cancel out like BIT_XOR.
+type RowsChecksum struct {
+	Count int64
+	High  uint64
+	Low   uint64
+}
+
+// Add merges the checksum of other rows, the result is same as calculating the checksum of all the rows together.
+func (c *RowsChecksum) Add(other *RowsChecksum) {
+	c.Count += other.Count
+	c.High += other.High
+	c.Low += other.Low
+}
+
+// Equal returns true if the two checksums are same.
+func (c *RowsChecksum) Equal(other *RowsChecksum) bool {
+	return c.Count == other.Count && c.High == other.High && c.Low == other.Low
+}
+
+// String returns the checksum in the format "count:high:low", high and low are in hex.
+func (c *RowsChecksum) String() string {
+	return fmt.Sprintf("%d:%016x:%016x", c.Count, c.High, c.Low)
+}
+
+// GetMD5Checksum returns the order-independent checksum of some data by given condition
+func GetMD5Checksum(ctx context.Context, db QueryExecutor, schemaName, tableName string, tbInfo *model.TableInfo, limitRange string, args []interface{}) (*RowsChecksum, error) {
+	return GetMD5ChecksumFrom(ctx, db, TableName(schemaName, tableName), tbInfo, nil, limitRange, args)
+}
+
+// GetMD5ChecksumFrom is same as GetMD5Checksum, but calculates the checksum of the rows from the table expression,
+// and the column's checksum is calculated by the expression in columnExprs if exists.
+func GetMD5ChecksumFrom(ctx context.Context, db QueryExecutor, from string, tbInfo *model.TableInfo, columnExprs map[string]string, limitRange string, args []interface{}) (*RowsChecksum, error) {
+	/*
+		calculate MD5 checksum example:
+		mysql> SELECT COUNT(*) AS cnt, SUM(CAST(CONV(SUBSTRING(MD5(CONCAT_WS(',', id, name, CONCAT(ISNULL(id), ISNULL(name)))), 1, 16), 16, 10) AS UNSIGNED)) AS high,
+		    -> SUM(CAST(CONV(SUBSTRING(MD5(CONCAT_WS(',', id, name, CONCAT(ISNULL(id), ISNULL(name)))), 17, 16), 16, 10) AS UNSIGNED)) AS low FROM test.test WHERE id > 0 AND id < 10;
+		+-----+-----------------------+----------------------+
+		| cnt | high                  | low                  |
+		+-----+-----------------------+----------------------+
+		|   9 | 82376454385629123411  | 96421287364587129385 |
+		+-----+-----------------------+----------------------+
+	*/
+	rowMD5 := fmt.Sprintf("MD5(%s)", rowConcatExpr(tbInfo, columnExprs))
+	query := fmt.Sprintf("SELECT COUNT(*) AS cnt, SUM(CAST(CONV(SUBSTRING(%s, 1, 16), 16, 10) AS UNSIGNED)) AS high, SUM(CAST(CONV(SUBSTRING(%s, 17, 16), 16, 10) AS UNSIGNED)) AS low FROM %s WHERE %s;",
+		rowMD5, rowMD5, from, limitRange)
 	log.Debug("checksum", zap.String("sql", query), zap.Reflect("args", args))
 
-	var checksum sql.NullInt64
-	err := db.QueryRowContext(ctx, query, args...).Scan(&checksum)
+	var (
+		count     int64
+		high, low sql.NullString
+	)
+	err := db.QueryRowContext(ctx, query, args...).Scan(&count, &high, &low)
 	if err != nil {
-		return -1, errors.Trace(err)
+		return nil, errors.Trace(err)
 	}
-	if !checksum.Valid {
-		// if don't have any data, the checksum will be `NULL`
+
+	checksum := &RowsChecksum{Count: count}
+	if !high.Valid || !low.Valid {
+		// if don't have any data, the sum will be `NULL`
 		log.Warn("get empty checksum", zap.String("sql", query), zap.Reflect("args", args))
-		return 0, nil
+		return checksum, nil
 	}
 
-	return checksum.Int64, nil
+	// the sum may exceed uint64, only keep the low 64 bits
+	checksum.High, err = parseUint64Mod(high.String)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	checksum.Low, err = parseUint64Mod(low.String)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return checksum, nil
+}
+
+// parseUint64Mod parses a non-negative decimal integer, and returns it modulo 2^64.
+func parseUint64Mod(s string) (uint64, error) {
+	// the sum of unsigned bigint is returned as decimal, may contain the fractional part
+	if idx := strings.IndexByte(s, '.'); idx >= 0 {
+		s = s[:idx]
+	}
+
+	num, ok := new(big.Int).SetString(s, 10)
+	if !ok || num.Sign() < 0 {
+		return 0, errors.Errorf("invalid checksum %s", s)
+	}
+
+	return new(big.Int).And(num, new(big.Int).SetUint64(math.MaxUint64)).Uint64(), nil
 }
 
 // Bucket saves the bucket information from TiDB.
@@ -724,6 +862,11 @@ func TableName(schema, table string) string {
 	return fmt.Sprintf("`%s`.`%s`", escapeName(schema), escapeName(table))
 }
 
+// DerivedTable returns the derived table of the query, which can be used as a table in the FROM clause.
+func DerivedTable(query string) string {
+	return fmt.Sprintf("(%s) AS %s", strings.TrimRight(strings.TrimSpace(query), ";"), ColumnName("derived_table"))
+}
+
 // ColumnName returns `column`
 func ColumnName(column string) string {
 	return fmt.Sprintf("`%s`", escapeName(column))
@@ -15,12 +15,14 @@ package dbutil
 
 import (
 	"context"
+	"math"
 	"time"
 
 	sqlmock "github.com/DATA-DOG/go-sqlmock"
 	"github.com/go-sql-driver/mysql"
 	. "github.com/pingcap/check"
 	"github.com/pingcap/errors"
+	"github.com/pingcap/parser"
 	"github.com/pingcap/parser/model"
 	pmysql "github.com/pingcap/parser/mysql"
 	"github.com/pingcap/parser/types"
@@ -243,3 +245,106 @@ func (s *testDBSuite) TestFormatTimeZoneOffset(c *C) {
 		c.Assert(k, Equals, offset)
 	}
 }
+
+func (s *testDBSuite) TestGetMD5Checksum(c *C) {
+	db, mock, err := sqlmock.New()
+	c.Assert(err, IsNil)
+
+	tableInfo, err := GetTableInfoBySQL("CREATE TABLE `test`.`t` (`id` int, `name` varchar(24), primary key(`id`))", parser.New())
+	c.Assert(err, IsNil)
+
+	// 2^64 + 1 and 2^65 + 2, only keep the low 64 bits
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) AS cnt, SUM\\(CAST\\(CONV\\(SUBSTRING\\(MD5\\(CONCAT_WS\\(',', `id`, `name`, CONCAT\\(ISNULL\\(`id`\\), ISNULL\\(`name`\\)\\)\\)\\), 1, 16\\), 16, 10\\) AS UNSIGNED\\)\\) AS high.* FROM `test`.`t` WHERE `id` > \\?").
+		WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"cnt", "high", "low"}).AddRow(3, "18446744073709551617", "36893488147419103234"))
+	checksum, err := GetMD5Checksum(context.Background(), db, "test", "t", tableInfo, "`id` > ?", []interface{}{1})
+	c.Assert(err, IsNil)
+	c.Assert(checksum, DeepEquals, &RowsChecksum{Count: 3, High: 1, Low: 2})
+	c.Assert(checksum.String(), Equals, "3:0000000000000001:0000000000000002")
+
+	// empty data
+	mock.ExpectQuery("SELECT COUNT").WillReturnRows(sqlmock.NewRows([]string{"cnt", "high", "low"}).AddRow(0, nil, nil))
+	checksum, err = GetMD5Checksum(context.Background(), db, "test", "t", tableInfo, "TRUE", nil)
+	c.Assert(err, IsNil)
+	c.Assert(checksum, DeepEquals, &RowsChecksum{})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		c.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (s *testDBSuite) TestGetChecksumWithExprs(c *C) {
+	db, mock, err := sqlmock.New()
+	c.Assert(err, IsNil)
+
+	tableInfo, err := GetTableInfoBySQL("CREATE TABLE `test`.`t` (`id` int, `name` varchar(24), primary key(`id`))", parser.New())
+	c.Assert(err, IsNil)
+	columnExprs := map[string]string{"name": "CONCAT('a:', `name`)"}
+
+	mock.ExpectQuery("SELECT BIT_XOR\\(CAST\\(CRC32\\(CONCAT_WS\\(',', `id`, CONCAT\\('a:', `name`\\), CONCAT\\(ISNULL\\(`id`\\), ISNULL\\(CONCAT\\('a:', `name`\\)\\)\\)\\)\\)AS UNSIGNED\\)\\) AS checksum, COUNT\\(\\*\\) AS cnt FROM `test`.`t` WHERE TRUE").
+		WillReturnRows(sqlmock.NewRows([]string{"checksum", "cnt"}).AddRow(100, 1))
+	crc32, count, err := GetCRC32ChecksumFrom(context.Background(), db, "`test`.`t`", tableInfo, columnExprs, "TRUE", nil)
+	c.Assert(err, IsNil)
+	c.Assert(crc32, Equals, int64(100))
+	c.Assert(count, Equals, int64(1))
+
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) AS cnt, SUM\\(CAST\\(CONV\\(SUBSTRING\\(MD5\\(CONCAT_WS\\(',', `id`, CONCAT\\('a:', `name`\\), .* FROM `test`.`t` WHERE TRUE").
+		WillReturnRows(sqlmock.NewRows([]string{"cnt", "high", "low"}).AddRow(1, "1", "2"))
+	checksum, err := GetMD5ChecksumFrom(context.Background(), db, "`test`.`t`", tableInfo, columnExprs, "TRUE", nil)
+	c.Assert(err, IsNil)
+	c.Assert(checksum, DeepEquals, &RowsChecksum{Count: 1, High: 1, Low: 2})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		c.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (s *testDBSuite) TestGetChecksumFromDerivedTable(c *C) {
+	db, mock, err := sqlmock.New()
+	c.Assert(err, IsNil)
+
+	tableInfo, err := GetTableInfoBySQL("CREATE TABLE `test`.`t` (`id` int, `cnt` bigint, primary key(`id`))", parser.New())
+	c.Assert(err, IsNil)
+	from := DerivedTable(" SELECT `id`, COUNT(*) AS `cnt` FROM `test`.`t1` GROUP BY `id`; ")
+	c.Assert(from, Equals, "(SELECT `id`, COUNT(*) AS `cnt` FROM `test`.`t1` GROUP BY `id`) AS `derived_table`")
+
+	mock.ExpectQuery("SELECT BIT_XOR\\(CAST\\(CRC32\\(CONCAT_WS\\(',', `id`, `cnt`, .* FROM \\(SELECT `id`, COUNT\\(\\*\\) AS `cnt` FROM `test`.`t1` GROUP BY `id`\\) AS `derived_table` WHERE `id` > \\?").
+		WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"checksum", "cnt"}).AddRow(100, 5))
+	crc32, count, err := GetCRC32ChecksumFrom(context.Background(), db, from, tableInfo, nil, "`id` > ?", []interface{}{1})
+	c.Assert(err, IsNil)
+	c.Assert(crc32, Equals, int64(100))
+	c.Assert(count, Equals, int64(5))
+
+	mock.ExpectQuery("SELECT COUNT\\(1\\) cnt FROM \\(SELECT .*\\) AS `derived_table` WHERE TRUE").
+		WillReturnRows(sqlmock.NewRows([]string{"cnt"}).AddRow(5))
+	count, err = GetRowCountFrom(context.Background(), db, from, "TRUE", nil)
+	c.Assert(err, IsNil)
+	c.Assert(count, Equals, int64(5))
+
+	mock.ExpectQuery("SELECT \\* FROM \\(SELECT .*\\) AS `derived_table` LIMIT 0").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "cnt"}))
+	columns, err := GetColumnsFrom(context.Background(), db, from)
+	c.Assert(err, IsNil)
+	c.Assert(columns, DeepEquals, []string{"id", "cnt"})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		c.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (s *testDBSuite) TestRowsChecksum(c *C) {
+	checksum := &RowsChecksum{Count: 1, High: 10, Low: 20}
+	checksum.Add(&RowsChecksum{Count: 2, High: math.MaxUint64, Low: 5})
+	c.Assert(checksum, DeepEquals, &RowsChecksum{Count: 3, High: 9, Low: 25})
+
+	// the row count is also compared
+	c.Assert(checksum.Equal(&RowsChecksum{Count: 3, High: 9, Low: 25}), IsTrue)
+	c.Assert(checksum.Equal(&RowsChecksum{Count: 4, High: 9, Low: 25}), IsFalse)
+
+	for _, num := range []string{"abc", "-1"} {
+		_, err := parseUint64Mod(num)
+		c.Assert(err, NotNil)
+	}
+	num, err := parseUint64Mod("18446744073709551616.0000")
+	c.Assert(err, IsNil)
+	c.Assert(num, Equals, uint64(0))
+}
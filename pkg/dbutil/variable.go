@@ -139,3 +139,46 @@ func ShowGrants(ctx context.Context, db QueryExecutor, user, host string) ([]str
 
 	return readGrantsFunc()
 }
+
+// ShowMySQLStatus queries MySQL global status and returns its value.
+func ShowMySQLStatus(ctx context.Context, db QueryExecutor, status string) (value string, err error) {
+	query := fmt.Sprintf("SHOW GLOBAL STATUS LIKE '%s';", status)
+	err = db.QueryRowContext(ctx, query).Scan(&status, &value)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	return value, nil
+}
+
+// ShowReplicationLag queries the replication lag in seconds by `SHOW SLAVE STATUS`,
+// returns false if the database is not a replica or the replication is not running.
+func ShowReplicationLag(ctx context.Context, db QueryExecutor) (lag int64, ok bool, err error) {
+	rows, err := db.QueryContext(ctx, "SHOW SLAVE STATUS")
+	if err != nil {
+		return 0, false, errors.Trace(err)
+	}
+	defer rows.Close()
+
+	// the max lag of all the replication channels
+	for rows.Next() {
+		row, err := ScanRow(rows)
+		if err != nil {
+			return 0, false, errors.Trace(err)
+		}
+
+		// the lag is NULL if the replication is not running
+		data, exist := row["Seconds_Behind_Master"]
+		if !exist || data.IsNull {
+			continue
+		}
+		channelLag, err := strconv.ParseInt(string(data.Data), 10, 64)
+		if err != nil {
+			return 0, false, errors.Annotatef(err, "parse Seconds_Behind_Master %s failed", data.Data)
+		}
+		if !ok || channelLag > lag {
+			lag, ok = channelLag, true
+		}
+	}
+
+	return lag, ok, errors.Trace(rows.Err())
+}
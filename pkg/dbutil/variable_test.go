@@ -99,3 +99,31 @@ func (*testDBSuite) TestShowGrantsPasswordMasked(c *C) {
 		c.Assert(mock.ExpectationsWereMet(), IsNil)
 	}
 }
+
+func (*testDBSuite) TestShowLoadStatus(c *C) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	c.Assert(err, IsNil)
+
+	mock.ExpectQuery("SHOW GLOBAL STATUS LIKE 'Threads_running'").
+		WillReturnRows(sqlmock.NewRows([]string{"Variable_name", "Value"}).AddRow("Threads_running", "12"))
+	value, err := ShowMySQLStatus(ctx, db, "Threads_running")
+	c.Assert(err, IsNil)
+	c.Assert(value, Equals, "12")
+
+	// not a replica
+	mock.ExpectQuery("SHOW SLAVE STATUS").WillReturnRows(sqlmock.NewRows([]string{"Slave_IO_State", "Seconds_Behind_Master"}))
+	_, ok, err := ShowReplicationLag(ctx, db)
+	c.Assert(err, IsNil)
+	c.Assert(ok, IsFalse)
+
+	// returns the max lag of the running channels
+	mock.ExpectQuery("SHOW SLAVE STATUS").WillReturnRows(sqlmock.NewRows([]string{"Slave_IO_State", "Seconds_Behind_Master"}).
+		AddRow("Waiting for master to send event", "3").AddRow("", nil).AddRow("Waiting for master to send event", "10"))
+	lag, ok, err := ShowReplicationLag(ctx, db)
+	c.Assert(err, IsNil)
+	c.Assert(ok, IsTrue)
+	c.Assert(lag, Equals, int64(10))
+
+	c.Assert(mock.ExpectationsWereMet(), IsNil)
+}
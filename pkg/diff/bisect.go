@@ -0,0 +1,91 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/parser/model"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultBisectMaxDepth is the default max times to split a mismatching chunk
+	defaultBisectMaxDepth = 4
+)
+
+// getMismatchRanges splits the chunk whose checksum is not equal into sub ranges recursively, and checksums them
+// to find the smallest mismatching sub ranges, so only need to compare rows in these sub ranges.
+func (t *TableDiff) getMismatchRanges(ctx context.Context, chunk *ChunkRange) ([]*ChunkRange, error) {
+	fields, err := getSplitFields(t.TargetTable.info, parseSplitFields(t.Fields))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	ranges, err := t.bisectChunk(ctx, chunk, fields, 0)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	// the data may be changed after checked the chunk, compare the whole chunk in this case
+	if len(ranges) == 0 {
+		return []*ChunkRange{chunk}, nil
+	}
+
+	log.Info("find mismatching sub ranges by bisection", zap.Int("chunk", chunk.ID), zap.Int("sub range num", len(ranges)))
+	return ranges, nil
+}
+
+// bisectChunk splits the mismatching chunk into two sub ranges, and returns the mismatching sub ranges after
+// split them until reach the max depth or can't split them anymore.
+func (t *TableDiff) bisectChunk(ctx context.Context, chunk *ChunkRange, fields []*model.ColumnInfo, depth int) ([]*ChunkRange, error) {
+	if depth >= t.BisectMaxDepth {
+		return []*ChunkRange{chunk}, nil
+	}
+
+	subChunks, err := splitRangeByRandom(t.TargetTable.Conn, t.TargetTable.Throttler, chunk, 2, t.TargetTable.Schema, t.TargetTable.Table, fields, t.Range, t.Collation)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if len(subChunks) <= 1 {
+		return []*ChunkRange{chunk}, nil
+	}
+
+	mismatchRanges := make([]*ChunkRange, 0, len(subChunks))
+	for _, subChunk := range subChunks {
+		conditions, args := subChunk.toString(t.Collation)
+		subChunk.ID = chunk.ID
+		subChunk.Where = fmt.Sprintf("((%s) AND %s)", conditions, t.Range)
+		subChunk.Args = args
+
+		equal, _, err := t.compareChecksum(ctx, subChunk)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if equal {
+			continue
+		}
+
+		ranges, err := t.bisectChunk(ctx, subChunk, fields, depth+1)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		mismatchRanges = append(mismatchRanges, ranges...)
+	}
+
+	return mismatchRanges, nil
+}
@@ -0,0 +1,75 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	. "github.com/pingcap/check"
+	"github.com/pingcap/parser"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+)
+
+var _ = Suite(&testBisectSuite{})
+
+type testBisectSuite struct{}
+
+func (s *testBisectSuite) TestGetMismatchRanges(c *C) {
+	createTableSQL := "CREATE TABLE `test`.`atest` (`id` int(24), `name` varchar(24), primary key(`id`))"
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	c.Assert(err, IsNil)
+
+	sourceDB, sourceMock, err := sqlmock.New()
+	c.Assert(err, IsNil)
+	targetDB, targetMock, err := sqlmock.New()
+	c.Assert(err, IsNil)
+
+	tableDiff := &TableDiff{
+		SourceTables:   []*TableInstance{{Conn: sourceDB, Schema: "test", Table: "atest", info: tableInfo}},
+		TargetTable:    &TableInstance{Conn: targetDB, Schema: "test", Table: "atest", info: tableInfo},
+		Range:          "TRUE",
+		UseChecksum:    true,
+		UseBisect:      true,
+		BisectMaxDepth: 1,
+	}
+	chunk := NewChunkRange()
+	chunk.ID = 3
+	chunk.Where = "((TRUE) AND TRUE)"
+
+	// split the chunk into `id` <= 5 and `id` > 5, only the second sub range is not equal
+	targetMock.ExpectQuery("SELECT `id` FROM \\(SELECT `id`, rand\\(\\) rand_value FROM `test`.`atest` WHERE \\(TRUE\\) AND TRUE ORDER BY rand_value LIMIT 1\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("5"))
+	targetMock.ExpectQuery("SELECT BIT_XOR.*WHERE \\(\\(\\(`id` <= \\?\\)\\) AND TRUE\\)").WithArgs("5").WillReturnRows(sqlmock.NewRows([]string{"checksum", "cnt"}).AddRow(123, 1))
+	sourceMock.ExpectQuery("SELECT BIT_XOR.*WHERE \\(\\(\\(`id` <= \\?\\)\\) AND TRUE\\)").WithArgs("5").WillReturnRows(sqlmock.NewRows([]string{"checksum", "cnt"}).AddRow(123, 1))
+	targetMock.ExpectQuery("SELECT BIT_XOR.*WHERE \\(\\(\\(`id` > \\?\\)\\) AND TRUE\\)").WithArgs("5").WillReturnRows(sqlmock.NewRows([]string{"checksum", "cnt"}).AddRow(456, 1))
+	sourceMock.ExpectQuery("SELECT BIT_XOR.*WHERE \\(\\(\\(`id` > \\?\\)\\) AND TRUE\\)").WithArgs("5").WillReturnRows(sqlmock.NewRows([]string{"checksum", "cnt"}).AddRow(789, 1))
+
+	ranges, err := tableDiff.getMismatchRanges(context.Background(), chunk)
+	c.Assert(err, IsNil)
+	c.Assert(ranges, HasLen, 1)
+	c.Assert(ranges[0].ID, Equals, 3)
+	c.Assert(ranges[0].Where, Equals, "(((`id` > ?)) AND TRUE)")
+	c.Assert(ranges[0].Args, DeepEquals, []string{"5"})
+
+	c.Assert(sourceMock.ExpectationsWereMet(), IsNil)
+	c.Assert(targetMock.ExpectationsWereMet(), IsNil)
+
+	// can't split the chunk if don't get any random value, will compare the whole chunk
+	targetMock.ExpectQuery("SELECT `id` FROM").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	ranges, err = tableDiff.getMismatchRanges(context.Background(), chunk)
+	c.Assert(err, IsNil)
+	c.Assert(ranges, DeepEquals, []*ChunkRange{chunk})
+	c.Assert(targetMock.ExpectationsWereMet(), IsNil)
+}
@@ -53,11 +53,21 @@ var (
 	// for table: don't have this state
 	ignoreState = "ignore"
 
+	// for chunk: means this chunk's fix sqls are applied, and it is checking again
+	// for table: don't have this state
+	repairingState = "repairing"
+
+	// for chunk: means this chunk's data is not equal, and it is equal after applied the fix sqls
+	// for table: don't have this state
+	repairedState = "repaired"
+
 	checkpointSchemaName = "sync_diff_inspector"
 
 	summaryTableName = "summary"
 
 	chunkTableName = "chunk"
+
+	watermarkTableName = "watermark"
 )
 
 // tableSummaryInfo saves a table's summary information
@@ -68,6 +78,23 @@ type tableSummaryInfo struct {
 	successNum int64
 	failedNum  int64
 	ignoreNum  int64
+
+	// rows' difference found in this table's chunks
+	insertRowNum int64
+	deleteRowNum int64
+	updateRowNum int64
+
+	// column name => the num of updated rows which have different value in this column
+	diffColumnNum map[string]int64
+
+	// chunks' data is equal after applied fix sqls, they are not success or failed chunks, and rows fixed by fix sqls
+	repairedNum int64
+	fixedRowNum int64
+
+	// chunks whose row count is different, and the row count of the chunks compared by row count
+	countMismatchNum int64
+	sourceRowCount   int64
+	targetRowCount   int64
 }
 
 func newTableSummaryInfo(totalNum int64) *tableSummaryInfo {
@@ -94,6 +121,84 @@ func (s *tableSummaryInfo) addIgnoreNum() {
 	s.Unlock()
 }
 
+func (s *tableSummaryInfo) addInsertRowNum() {
+	s.Lock()
+	s.insertRowNum++
+	s.Unlock()
+}
+
+func (s *tableSummaryInfo) addDeleteRowNum() {
+	s.Lock()
+	s.deleteRowNum++
+	s.Unlock()
+}
+
+func (s *tableSummaryInfo) addUpdateRowNum(diffColumns []string) {
+	s.Lock()
+	s.updateRowNum++
+	if s.diffColumnNum == nil {
+		s.diffColumnNum = make(map[string]int64)
+	}
+	for _, column := range diffColumns {
+		s.diffColumnNum[column]++
+	}
+	s.Unlock()
+}
+
+func (s *tableSummaryInfo) getRowNum() (insertRowNum, deleteRowNum, updateRowNum int64) {
+	s.RLock()
+	defer s.RUnlock()
+	return s.insertRowNum, s.deleteRowNum, s.updateRowNum
+}
+
+func (s *tableSummaryInfo) addRepairedNum() {
+	s.Lock()
+	s.repairedNum++
+	s.Unlock()
+}
+
+func (s *tableSummaryInfo) addFixedRowNum(num int64) {
+	s.Lock()
+	s.fixedRowNum += num
+	s.Unlock()
+}
+
+func (s *tableSummaryInfo) getRepairNum() (repairedNum, fixedRowNum int64) {
+	s.RLock()
+	defer s.RUnlock()
+	return s.repairedNum, s.fixedRowNum
+}
+
+func (s *tableSummaryInfo) addRowCount(sourceRowCount, targetRowCount int64) {
+	s.Lock()
+	s.sourceRowCount += sourceRowCount
+	s.targetRowCount += targetRowCount
+	if sourceRowCount != targetRowCount {
+		s.countMismatchNum++
+	}
+	s.Unlock()
+}
+
+func (s *tableSummaryInfo) getRowCount() (countMismatchNum, sourceRowCount, targetRowCount int64) {
+	s.RLock()
+	defer s.RUnlock()
+	return s.countMismatchNum, s.sourceRowCount, s.targetRowCount
+}
+
+func (s *tableSummaryInfo) getDiffColumnNum() map[string]int64 {
+	s.RLock()
+	defer s.RUnlock()
+
+	if len(s.diffColumnNum) == 0 {
+		return nil
+	}
+	diffColumnNum := make(map[string]int64, len(s.diffColumnNum))
+	for column, num := range s.diffColumnNum {
+		diffColumnNum[column] = num
+	}
+	return diffColumnNum
+}
+
 func (s *tableSummaryInfo) get() (totalNum, successNum, failedNum, ignoreNum int64) {
 	s.RLock()
 	defer s.RUnlock()
@@ -101,6 +206,33 @@ func (s *tableSummaryInfo) get() (totalNum, successNum, failedNum, ignoreNum int
 
 }
 
+// toTableSummary returns the summary saved in checkpoint, the state is calculated by the chunks' state
+func (s *tableSummaryInfo) toTableSummary() *TableSummary {
+	total, successNum, failedNum, ignoreNum := s.get()
+	repairedNum, _ := s.getRepairNum()
+
+	// the table with repaired chunks is not equal
+	checkedNum := successNum + failedNum + ignoreNum + repairedNum
+	state := checkingState
+	if checkedNum == 0 {
+		state = notCheckedState
+	} else if checkedNum == total {
+		if total == successNum+ignoreNum {
+			state = successState
+		} else {
+			state = failedState
+		}
+	}
+
+	return &TableSummary{
+		ChunkNum:   total,
+		SuccessNum: successNum,
+		FailedNum:  failedNum,
+		IgnoreNum:  ignoreNum,
+		State:      state,
+	}
+}
+
 // saveChunk saves the chunk's info to `chunk` table
 func saveChunk(ctx context.Context, db *sql.DB, chunkID int, instanceID, schema, table, checksum string, chunk *ChunkRange) error {
 	chunkBytes, err := json.Marshal(chunk)
@@ -109,7 +241,7 @@ func saveChunk(ctx context.Context, db *sql.DB, chunkID int, instanceID, schema,
 	}
 
 	sql := fmt.Sprintf("REPLACE INTO `%s`.`%s` VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?);", checkpointSchemaName, chunkTableName)
-	err = dbutil.ExecSQLWithRetry(ctx, db, sql, chunkID, instanceID, schema, table, chunk.Where, checksum, string(chunkBytes), chunk.State, time.Now())
+	err = dbutil.ExecSQLWithRetry(ctx, retryCountingDB{db}, sql, chunkID, instanceID, schema, table, chunk.Where, checksum, string(chunkBytes), chunk.State, time.Now())
 	if err != nil {
 		log.Error("save chunk info failed", zap.Error(err))
 		return errors.Trace(err)
@@ -140,7 +272,7 @@ func initChunks(ctx context.Context, db *sql.DB, instanceID, schema, table strin
 
 		if num >= batch || i == len(chunks)-1 {
 			sql := fmt.Sprintf("%s%s", sqlPrefix, strings.Join(valuesPlaceholdersArray, ", "))
-			err = dbutil.ExecSQLWithRetry(ctx, db, sql, values...)
+			err = dbutil.ExecSQLWithRetry(ctx, retryCountingDB{db}, sql, values...)
 			if err != nil {
 				log.Error("save chunk info failed", zap.Error(err))
 				return errors.Trace(err)
@@ -252,7 +384,7 @@ func getTableSummary(ctx context.Context, db *sql.DB, schema, table string) (tot
 // initTableSummary initials a table's summary info in table `summary`
 func initTableSummary(ctx context.Context, db *sql.DB, schema, table string, configHash string) error {
 	sql := fmt.Sprintf("REPLACE INTO `%s`.`%s`(`schema`, `table`, `state`, `config_hash`) VALUES(?, ?, ?, ?)", checkpointSchemaName, summaryTableName)
-	err := dbutil.ExecSQLWithRetry(ctx, db, sql, schema, table, notCheckedState, configHash)
+	err := dbutil.ExecSQLWithRetry(ctx, retryCountingDB{db}, sql, schema, table, notCheckedState, configHash)
 	if err != nil {
 		log.Error("save summary info failed", zap.Error(err))
 		return errors.Trace(err)
@@ -261,34 +393,10 @@ func initTableSummary(ctx context.Context, db *sql.DB, schema, table string, con
 	return nil
 }
 
-// updateTableSummary gets summary info from `chunk` table, and then update `summary` table
-func updateTableSummary(ctx context.Context, db *sql.DB, instanceID, schema, table string, summaryInfo *tableSummaryInfo) error {
-	if summaryInfo == nil {
-		return nil
-	}
-
-	total, successNum, failedNum, ignoreNum := summaryInfo.get()
-	if total == 0 {
-		// don't need to update summary info
-		return nil
-	}
-
-	log.Info("summary info", zap.String("instance_id", instanceID), zap.String("schema", schema), zap.String("table", table), zap.Int64("chunk num", total), zap.Int64("success num", successNum), zap.Int64("failed num", failedNum), zap.Int64("ignore num", ignoreNum))
-
-	checkedNum := successNum + failedNum + ignoreNum
-	state := checkingState
-	if checkedNum == 0 {
-		state = notCheckedState
-	} else if checkedNum == total {
-		if total == successNum+ignoreNum {
-			state = successState
-		} else {
-			state = failedState
-		}
-	}
-
+// updateTableSummary updates the table's summary info in table `summary`
+func updateTableSummary(ctx context.Context, db *sql.DB, schema, table string, summary *TableSummary) error {
 	updateSQL := fmt.Sprintf("UPDATE `%s`.`%s` SET `chunk_num` = ?, `check_success_num` = ?, `check_failed_num` = ?, `check_ignore_num` = ?, `state` = ? WHERE `schema` = ? AND `table` = ?", checkpointSchemaName, summaryTableName)
-	err := dbutil.ExecSQLWithRetry(ctx, db, updateSQL, total, successNum, failedNum, ignoreNum, state, schema, table)
+	err := dbutil.ExecSQLWithRetry(ctx, retryCountingDB{db}, updateSQL, summary.ChunkNum, summary.SuccessNum, summary.FailedNum, summary.IgnoreNum, summary.State, schema, table)
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -359,6 +467,68 @@ func createCheckpointTable(ctx context.Context, db *sql.DB) error {
 		return errors.Trace(err)
 	}
 
+	/* example
+	mysql> select * from sync_diff_inspector.watermark;
+	+--------+-------+-------------+---------------------+---------------------+
+	| schema | table | column      | high_watermark      | update_time         |
+	+--------+-------+-------------+---------------------+---------------------+
+	| diff   | test  | update_time | 2019-03-26 12:40:00 | 2019-03-26 12:42:11 |
+	+--------+-------+-------------+---------------------+---------------------+
+
+	note: high_watermark is the max value of the update time column checked in incremental mode,
+	only the rows updated after it will be checked next time.
+	*/
+	createWatermarkTableSQL :=
+		"CREATE TABLE IF NOT EXISTS `sync_diff_inspector`.`watermark`(" +
+			"`schema` varchar(64), `table` varchar(64)," +
+			"`column` varchar(64)," +
+			"`high_watermark` varchar(64)," +
+			"`update_time` datetime ON UPDATE CURRENT_TIMESTAMP," +
+			"PRIMARY KEY(`schema`, `table`));"
+	_, err = db.ExecContext(ctx, createWatermarkTableSQL)
+	if err != nil {
+		log.Error("create watermark table", zap.Error(err))
+		return errors.Trace(err)
+	}
+
+	return nil
+}
+
+// loadWatermark returns the high watermark of the table's update time column checked last time,
+// returns empty string if the table is never checked in incremental mode or the column is changed.
+func loadWatermark(ctx context.Context, db *sql.DB, schema, table, column string) (string, error) {
+	query := fmt.Sprintf("SELECT `column`, `high_watermark` FROM `%s`.`%s` WHERE `schema` = ? AND `table` = ? LIMIT 1", checkpointSchemaName, watermarkTableName)
+	rows, err := db.QueryContext(ctx, query, schema, table)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var savedColumn, watermark sql.NullString
+		if err := rows.Scan(&savedColumn, &watermark); err != nil {
+			return "", errors.Trace(err)
+		}
+
+		if !watermark.Valid || savedColumn.String != column {
+			log.Info("update time column is changed, will check all the data", zap.String("schema", schema), zap.String("table", table), zap.String("old column", savedColumn.String), zap.String("new column", column))
+			return "", nil
+		}
+		return watermark.String, nil
+	}
+
+	return "", errors.Trace(rows.Err())
+}
+
+// saveWatermark saves the high watermark of the table's update time column
+func saveWatermark(ctx context.Context, db *sql.DB, schema, table, column, watermark string) error {
+	sql := fmt.Sprintf("REPLACE INTO `%s`.`%s`(`schema`, `table`, `column`, `high_watermark`, `update_time`) VALUES(?, ?, ?, ?, ?)", checkpointSchemaName, watermarkTableName)
+	err := dbutil.ExecSQLWithRetry(ctx, retryCountingDB{db}, sql, schema, table, column, watermark, time.Now())
+	if err != nil {
+		log.Error("save watermark failed", zap.Error(err))
+		return errors.Trace(err)
+	}
+
 	return nil
 }
 
@@ -0,0 +1,335 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"go.uber.org/zap"
+)
+
+const (
+	// the chunks' state is saved frequently, so only flush them to file at most once in this interval,
+	// the chunks not flushed will be checked again, so it's safe.
+	fileCheckpointFlushInterval = time.Second
+)
+
+// fileCheckpoint is the content of the checkpoint file, the tables are keyed by the quoted table name.
+type fileCheckpoint struct {
+	Summaries map[string]*fileTableSummary `json:"summaries"`
+	// table name => instance id => chunk id => chunk
+	Chunks     map[string]map[string]map[int]json.RawMessage `json:"chunks"`
+	Watermarks map[string]*fileWatermark                     `json:"watermarks"`
+}
+
+type fileTableSummary struct {
+	TableSummary
+	ConfigHash string    `json:"config-hash"`
+	UpdateTime time.Time `json:"update-time"`
+}
+
+type fileWatermark struct {
+	Column        string    `json:"column"`
+	HighWatermark string    `json:"high-watermark"`
+	UpdateTime    time.Time `json:"update-time"`
+}
+
+// fileCheckpointStore saves the checkpoint in a local json file, used when can't write the target database.
+type fileCheckpointStore struct {
+	sync.Mutex
+
+	path      string
+	loaded    bool
+	dirty     bool
+	lastFlush time.Time
+
+	cp *fileCheckpoint
+}
+
+// NewFileCheckpointStore returns a CheckpointStore which saves the checkpoint in the local json file.
+func NewFileCheckpointStore(path string) CheckpointStore {
+	return &fileCheckpointStore{
+		path: path,
+		cp:   newFileCheckpoint(),
+	}
+}
+
+func newFileCheckpoint() *fileCheckpoint {
+	return &fileCheckpoint{
+		Summaries:  make(map[string]*fileTableSummary),
+		Chunks:     make(map[string]map[string]map[int]json.RawMessage),
+		Watermarks: make(map[string]*fileWatermark),
+	}
+}
+
+// Init loads the checkpoint file if exists, only loads it once.
+func (s *fileCheckpointStore) Init(ctx context.Context) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.loaded {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return errors.Annotatef(err, "read checkpoint file %s", s.path)
+		}
+	}
+
+	cp := newFileCheckpoint()
+	if len(data) != 0 {
+		if err = json.Unmarshal(data, cp); err != nil {
+			return errors.Annotatef(err, "parse checkpoint file %s", s.path)
+		}
+		// the maps are nil if the file is written by hand
+		if cp.Summaries == nil {
+			cp.Summaries = make(map[string]*fileTableSummary)
+		}
+		if cp.Chunks == nil {
+			cp.Chunks = make(map[string]map[string]map[int]json.RawMessage)
+		}
+		if cp.Watermarks == nil {
+			cp.Watermarks = make(map[string]*fileWatermark)
+		}
+	}
+
+	s.cp = cp
+	s.loaded = true
+	log.Info("load checkpoint file", zap.String("path", s.path), zap.Int("table num", len(cp.Summaries)))
+	return nil
+}
+
+func (s *fileCheckpointStore) UseCheckpoint(ctx context.Context, schema, table, configHash string) (bool, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	summary, ok := s.cp.Summaries[dbutil.TableName(schema, table)]
+	if !ok || summary.ConfigHash != configHash {
+		return false, nil
+	}
+
+	// is state is success, will begin a new check for this table
+	// if state is not checked, the chunk info maybe not exists, so just return false
+	if summary.State == successState || summary.State == notCheckedState {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+func (s *fileCheckpointStore) Clean(ctx context.Context, schema, table string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	tableName := dbutil.TableName(schema, table)
+	delete(s.cp.Summaries, tableName)
+	delete(s.cp.Chunks, tableName)
+
+	return errors.Trace(s.flush())
+}
+
+func (s *fileCheckpointStore) InitTableSummary(ctx context.Context, schema, table, configHash string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.cp.Summaries[dbutil.TableName(schema, table)] = &fileTableSummary{
+		TableSummary: TableSummary{State: notCheckedState},
+		ConfigHash:   configHash,
+		UpdateTime:   time.Now(),
+	}
+
+	return errors.Trace(s.flush())
+}
+
+func (s *fileCheckpointStore) UpdateTableSummary(ctx context.Context, schema, table string, summary *TableSummary) error {
+	s.Lock()
+	defer s.Unlock()
+
+	// same as the sql store, only update the initialized summary
+	saved, ok := s.cp.Summaries[dbutil.TableName(schema, table)]
+	if !ok {
+		return nil
+	}
+	saved.TableSummary = *summary
+	saved.UpdateTime = time.Now()
+
+	return errors.Trace(s.flush())
+}
+
+func (s *fileCheckpointStore) GetTableSummary(ctx context.Context, schema, table string) (*TableSummary, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	saved, ok := s.cp.Summaries[dbutil.TableName(schema, table)]
+	if !ok {
+		return nil, errors.NotFoundf("schema %s, table %s summary info", schema, table)
+	}
+
+	summary := saved.TableSummary
+	return &summary, nil
+}
+
+func (s *fileCheckpointStore) InitChunks(ctx context.Context, instanceID, schema, table string, chunks []*ChunkRange) error {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, chunk := range chunks {
+		if err := s.saveChunk(instanceID, schema, table, chunk); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	return errors.Trace(s.flush())
+}
+
+func (s *fileCheckpointStore) SaveChunk(ctx context.Context, instanceID, schema, table string, chunk *ChunkRange) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if err := s.saveChunk(instanceID, schema, table, chunk); err != nil {
+		return errors.Trace(err)
+	}
+
+	if time.Since(s.lastFlush) < fileCheckpointFlushInterval {
+		return nil
+	}
+	return errors.Trace(s.flush())
+}
+
+// saveChunk saves the chunk in memory, the chunk is marshaled because it will be changed by the caller later.
+func (s *fileCheckpointStore) saveChunk(instanceID, schema, table string, chunk *ChunkRange) error {
+	chunkBytes, err := json.Marshal(chunk)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	tableName := dbutil.TableName(schema, table)
+	if _, ok := s.cp.Chunks[tableName]; !ok {
+		s.cp.Chunks[tableName] = make(map[string]map[int]json.RawMessage)
+	}
+	if _, ok := s.cp.Chunks[tableName][instanceID]; !ok {
+		s.cp.Chunks[tableName][instanceID] = make(map[int]json.RawMessage)
+	}
+	s.cp.Chunks[tableName][instanceID][chunk.ID] = chunkBytes
+	s.dirty = true
+
+	return nil
+}
+
+func (s *fileCheckpointStore) LoadChunks(ctx context.Context, instanceID, schema, table string) ([]*ChunkRange, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	savedChunks := s.cp.Chunks[dbutil.TableName(schema, table)][instanceID]
+	chunks := make([]*ChunkRange, 0, len(savedChunks))
+	for _, chunkBytes := range savedChunks {
+		chunk := new(ChunkRange)
+		if err := json.Unmarshal(chunkBytes, &chunk); err != nil {
+			return nil, errors.Trace(err)
+		}
+		chunk.updateColumnOffset()
+		chunks = append(chunks, chunk)
+	}
+	sort.Slice(chunks, func(i, j int) bool {
+		return chunks[i].ID < chunks[j].ID
+	})
+
+	return chunks, nil
+}
+
+func (s *fileCheckpointStore) LoadWatermark(ctx context.Context, schema, table, column string) (string, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	watermark, ok := s.cp.Watermarks[dbutil.TableName(schema, table)]
+	if !ok {
+		return "", nil
+	}
+	if watermark.Column != column {
+		log.Info("update time column is changed, will check all the data", zap.String("schema", schema), zap.String("table", table), zap.String("old column", watermark.Column), zap.String("new column", column))
+		return "", nil
+	}
+
+	return watermark.HighWatermark, nil
+}
+
+func (s *fileCheckpointStore) SaveWatermark(ctx context.Context, schema, table, column, watermark string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.cp.Watermarks[dbutil.TableName(schema, table)] = &fileWatermark{
+		Column:        column,
+		HighWatermark: watermark,
+		UpdateTime:    time.Now(),
+	}
+
+	return errors.Trace(s.flush())
+}
+
+func (s *fileCheckpointStore) Close() error {
+	s.Lock()
+	defer s.Unlock()
+
+	if !s.dirty {
+		return nil
+	}
+	return errors.Trace(s.flush())
+}
+
+// flush writes the checkpoint to a temporary file and then renames it, so the checkpoint file is always complete.
+func (s *fileCheckpointStore) flush() error {
+	data, err := json.Marshal(s.cp)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	dir := filepath.Dir(s.path)
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return errors.Trace(err)
+	}
+
+	tmpFile, err := ioutil.TempFile(dir, filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	tmpPath := tmpFile.Name()
+
+	_, err = tmpFile.Write(data)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, s.path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return errors.Annotatef(err, "write checkpoint file %s", s.path)
+	}
+
+	s.dirty = false
+	s.lastFlush = time.Now()
+	return nil
+}
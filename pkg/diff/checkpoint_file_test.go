@@ -0,0 +1,165 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+
+	. "github.com/pingcap/check"
+)
+
+var _ = Suite(&testFileCheckpointSuite{})
+
+type testFileCheckpointSuite struct{}
+
+func (s *testFileCheckpointSuite) TestFileCheckpointStore(c *C) {
+	ctx := context.Background()
+	path := filepath.Join(c.MkDir(), "checkpoint", "checkpoint.json")
+
+	store := NewFileCheckpointStore(path)
+	c.Assert(store.Init(ctx), IsNil)
+
+	_, err := store.GetTableSummary(ctx, "test", "checkpoint")
+	c.Assert(err, ErrorMatches, ".*not found.*")
+	useCheckpoint, err := store.UseCheckpoint(ctx, "test", "checkpoint", "123")
+	c.Assert(err, IsNil)
+	c.Assert(useCheckpoint, IsFalse)
+
+	// init the table's summary and chunks
+	c.Assert(store.Clean(ctx, "test", "checkpoint"), IsNil)
+	c.Assert(store.InitTableSummary(ctx, "test", "checkpoint", "123"), IsNil)
+	summary, err := store.GetTableSummary(ctx, "test", "checkpoint")
+	c.Assert(err, IsNil)
+	c.Assert(summary, DeepEquals, &TableSummary{State: notCheckedState})
+
+	chunks := []*ChunkRange{
+		{ID: 0, Bounds: []*Bound{{Column: "a", Lower: "1"}}, State: notCheckedState, columnOffset: map[string]int{"a": 0}},
+		{ID: 1, Bounds: []*Bound{{Column: "a", Upper: "1"}}, State: notCheckedState, columnOffset: map[string]int{"a": 0}},
+	}
+	c.Assert(store.InitChunks(ctx, "target", "test", "checkpoint", chunks), IsNil)
+
+	// the chunk's state may be not flushed until close
+	chunks[1].State = successState
+	c.Assert(store.SaveChunk(ctx, "target", "test", "checkpoint", chunks[1]), IsNil)
+	summaryInfo := newTableSummaryInfo(2)
+	summaryInfo.addSuccessNum()
+	c.Assert(store.UpdateTableSummary(ctx, "test", "checkpoint", summaryInfo.toTableSummary()), IsNil)
+	c.Assert(store.SaveWatermark(ctx, "test", "checkpoint", "update_time", "2021-01-01 00:00:00"), IsNil)
+	c.Assert(store.Close(), IsNil)
+
+	// load the checkpoint from file
+	store = NewFileCheckpointStore(path)
+	c.Assert(store.Init(ctx), IsNil)
+
+	useCheckpoint, err = store.UseCheckpoint(ctx, "test", "checkpoint", "456")
+	c.Assert(err, IsNil)
+	c.Assert(useCheckpoint, IsFalse)
+	useCheckpoint, err = store.UseCheckpoint(ctx, "test", "checkpoint", "123")
+	c.Assert(err, IsNil)
+	c.Assert(useCheckpoint, IsTrue)
+
+	summary, err = store.GetTableSummary(ctx, "test", "checkpoint")
+	c.Assert(err, IsNil)
+	c.Assert(summary, DeepEquals, &TableSummary{ChunkNum: 2, SuccessNum: 1, State: checkingState})
+
+	loadedChunks, err := store.LoadChunks(ctx, "target", "test", "checkpoint")
+	c.Assert(err, IsNil)
+	c.Assert(loadedChunks, DeepEquals, chunks)
+	loadedChunks, err = store.LoadChunks(ctx, "source", "test", "checkpoint")
+	c.Assert(err, IsNil)
+	c.Assert(loadedChunks, HasLen, 0)
+
+	watermark, err := store.LoadWatermark(ctx, "test", "checkpoint", "update_time")
+	c.Assert(err, IsNil)
+	c.Assert(watermark, Equals, "2021-01-01 00:00:00")
+	watermark, err = store.LoadWatermark(ctx, "test", "checkpoint", "modify_time")
+	c.Assert(err, IsNil)
+	c.Assert(watermark, Equals, "")
+
+	// clean will not delete the watermark
+	c.Assert(store.Clean(ctx, "test", "checkpoint"), IsNil)
+	_, err = store.GetTableSummary(ctx, "test", "checkpoint")
+	c.Assert(err, ErrorMatches, ".*not found.*")
+	loadedChunks, err = store.LoadChunks(ctx, "target", "test", "checkpoint")
+	c.Assert(err, IsNil)
+	c.Assert(loadedChunks, HasLen, 0)
+	watermark, err = store.LoadWatermark(ctx, "test", "checkpoint", "update_time")
+	c.Assert(err, IsNil)
+	c.Assert(watermark, Equals, "2021-01-01 00:00:00")
+	c.Assert(store.Close(), IsNil)
+
+	// the file is invalid
+	c.Assert(ioutil.WriteFile(path, []byte("{"), 0644), IsNil)
+	c.Assert(NewFileCheckpointStore(path).Init(ctx), ErrorMatches, "parse checkpoint file.*")
+}
+
+func (s *testFileCheckpointSuite) TestInspectCheckpoint(c *C) {
+	ctx := context.Background()
+	store := NewFileCheckpointStore(filepath.Join(c.MkDir(), "checkpoint.json"))
+
+	newTableDiff := func() *TableDiff {
+		return &TableDiff{
+			SourceTables: []*TableInstance{
+				{InstanceID: "source-1", Schema: "test", Table: "t1"},
+				{InstanceID: "source-2", Schema: "test", Table: "t2"},
+			},
+			TargetTable:     &TableInstance{InstanceID: "target", Schema: "test", Table: "t"},
+			UseCheckpoint:   true,
+			CheckpointStore: store,
+		}
+	}
+
+	tbDiff := newTableDiff()
+	inspection, err := tbDiff.InspectCheckpoint(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(inspection, DeepEquals, &CheckpointInspection{Reason: "no checkpoint"})
+
+	// the check is interrupted after checked one chunk
+	_, err = tbDiff.LoadCheckpoint(ctx)
+	c.Assert(err, IsNil)
+	chunks := []*ChunkRange{
+		{ID: 0, Bounds: []*Bound{{Column: "a", Lower: "1"}}, State: successState},
+		{ID: 1, Bounds: []*Bound{{Column: "a", Upper: "1"}}, State: notCheckedState},
+	}
+	c.Assert(store.InitChunks(ctx, "target", "test", "t", chunks), IsNil)
+	c.Assert(store.UpdateTableSummary(ctx, "test", "t", &TableSummary{ChunkNum: 2, SuccessNum: 1, State: checkingState}), IsNil)
+
+	// the order of source tables and the chunk size are changed
+	tbDiff = newTableDiff()
+	tbDiff.SourceTables[0], tbDiff.SourceTables[1] = tbDiff.SourceTables[1], tbDiff.SourceTables[0]
+	tbDiff.ChunkSize = 5000
+	inspection, err = tbDiff.InspectCheckpoint(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(inspection, DeepEquals, &CheckpointInspection{Resume: true, ChunkNum: 2, SkipChunkNum: 1})
+
+	tbDiff = newTableDiff()
+	tbDiff.Range = "a > 10"
+	inspection, err = tbDiff.InspectCheckpoint(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(inspection, DeepEquals, &CheckpointInspection{Reason: "config is changed"})
+
+	tbDiff = newTableDiff()
+	tbDiff.UseCheckpoint = false
+	inspection, err = tbDiff.InspectCheckpoint(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(inspection, DeepEquals, &CheckpointInspection{Reason: "checkpoint is disabled"})
+
+	c.Assert(store.UpdateTableSummary(ctx, "test", "t", &TableSummary{ChunkNum: 2, SuccessNum: 2, State: successState}), IsNil)
+	inspection, err = newTableDiff().InspectCheckpoint(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(inspection, DeepEquals, &CheckpointInspection{Reason: "checked finished last time"})
+	c.Assert(store.Close(), IsNil)
+}
@@ -0,0 +1,151 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pingcap/errors"
+)
+
+const (
+	// SQLCheckpointStore saves the checkpoint in the schema `sync_diff_inspector` of a database
+	SQLCheckpointStore = "sql"
+	// FileCheckpointStore saves the checkpoint in a local json file
+	FileCheckpointStore = "file"
+)
+
+// TableSummary is the check summary of a table saved in checkpoint.
+type TableSummary struct {
+	ChunkNum   int64  `json:"chunk-num"`
+	SuccessNum int64  `json:"success-num"`
+	FailedNum  int64  `json:"failed-num"`
+	IgnoreNum  int64  `json:"ignore-num"`
+	State      string `json:"state"`
+}
+
+// CheckpointStore saves the tables' check progress, so the check can continue from the last checkpoint
+// if the config is not changed.
+type CheckpointStore interface {
+	// Init prepares the store before saving checkpoint, for example creates the checkpoint tables.
+	Init(ctx context.Context) error
+
+	// UseCheckpoint returns true if the table is not checked finished last time with the same config hash.
+	UseCheckpoint(ctx context.Context, schema, table, configHash string) (bool, error)
+
+	// Clean deletes the table's summary and chunks.
+	Clean(ctx context.Context, schema, table string) error
+
+	// InitTableSummary initials the table's summary with not checked state.
+	InitTableSummary(ctx context.Context, schema, table, configHash string) error
+
+	// UpdateTableSummary updates the table's summary.
+	UpdateTableSummary(ctx context.Context, schema, table string, summary *TableSummary) error
+
+	// GetTableSummary returns the table's summary, returns not found error if the table is not checked.
+	GetTableSummary(ctx context.Context, schema, table string) (*TableSummary, error)
+
+	// InitChunks saves the chunks split for the table.
+	InitChunks(ctx context.Context, instanceID, schema, table string, chunks []*ChunkRange) error
+
+	// SaveChunk saves the chunk's state.
+	SaveChunk(ctx context.Context, instanceID, schema, table string, chunk *ChunkRange) error
+
+	// LoadChunks returns the chunks saved for the table.
+	LoadChunks(ctx context.Context, instanceID, schema, table string) ([]*ChunkRange, error)
+
+	// LoadWatermark returns the high watermark of the table's update time column,
+	// returns empty string if the table is never checked in incremental mode or the column is changed.
+	LoadWatermark(ctx context.Context, schema, table, column string) (string, error)
+
+	// SaveWatermark saves the high watermark of the table's update time column.
+	SaveWatermark(ctx context.Context, schema, table, column, watermark string) error
+
+	// Close flushes the checkpoint and releases the resources.
+	Close() error
+}
+
+// sqlCheckpointStore saves the checkpoint in the schema `sync_diff_inspector` of the database.
+type sqlCheckpointStore struct {
+	db *sql.DB
+}
+
+// NewSQLCheckpointStore returns a CheckpointStore which saves the checkpoint in the database,
+// the db is owned by the caller and will not be closed by the store.
+func NewSQLCheckpointStore(db *sql.DB) CheckpointStore {
+	return &sqlCheckpointStore{db: db}
+}
+
+func (s *sqlCheckpointStore) Init(ctx context.Context) error {
+	return errors.Trace(createCheckpointTable(ctx, s.db))
+}
+
+func (s *sqlCheckpointStore) UseCheckpoint(ctx context.Context, schema, table, configHash string) (bool, error) {
+	useCheckpoint, err := loadFromCheckPoint(ctx, s.db, schema, table, configHash)
+	return useCheckpoint, errors.Trace(err)
+}
+
+func (s *sqlCheckpointStore) Clean(ctx context.Context, schema, table string) error {
+	return errors.Trace(cleanCheckpoint(ctx, s.db, schema, table))
+}
+
+func (s *sqlCheckpointStore) InitTableSummary(ctx context.Context, schema, table, configHash string) error {
+	return errors.Trace(initTableSummary(ctx, s.db, schema, table, configHash))
+}
+
+func (s *sqlCheckpointStore) UpdateTableSummary(ctx context.Context, schema, table string, summary *TableSummary) error {
+	return errors.Trace(updateTableSummary(ctx, s.db, schema, table, summary))
+}
+
+func (s *sqlCheckpointStore) GetTableSummary(ctx context.Context, schema, table string) (*TableSummary, error) {
+	total, successNum, failedNum, ignoreNum, state, err := getTableSummary(ctx, s.db, schema, table)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return &TableSummary{
+		ChunkNum:   total,
+		SuccessNum: successNum,
+		FailedNum:  failedNum,
+		IgnoreNum:  ignoreNum,
+		State:      state,
+	}, nil
+}
+
+func (s *sqlCheckpointStore) InitChunks(ctx context.Context, instanceID, schema, table string, chunks []*ChunkRange) error {
+	return errors.Trace(initChunks(ctx, s.db, instanceID, schema, table, chunks))
+}
+
+func (s *sqlCheckpointStore) SaveChunk(ctx context.Context, instanceID, schema, table string, chunk *ChunkRange) error {
+	return errors.Trace(saveChunk(ctx, s.db, chunk.ID, instanceID, schema, table, "", chunk))
+}
+
+func (s *sqlCheckpointStore) LoadChunks(ctx context.Context, instanceID, schema, table string) ([]*ChunkRange, error) {
+	chunks, err := loadChunks(ctx, s.db, instanceID, schema, table)
+	return chunks, errors.Trace(err)
+}
+
+func (s *sqlCheckpointStore) LoadWatermark(ctx context.Context, schema, table, column string) (string, error) {
+	watermark, err := loadWatermark(ctx, s.db, schema, table, column)
+	return watermark, errors.Trace(err)
+}
+
+func (s *sqlCheckpointStore) SaveWatermark(ctx context.Context, schema, table, column, watermark string) error {
+	return errors.Trace(saveWatermark(ctx, s.db, schema, table, column, watermark))
+}
+
+func (s *sqlCheckpointStore) Close() error {
+	return nil
+}
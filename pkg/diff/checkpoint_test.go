@@ -88,7 +88,7 @@ func (s *testCheckpointSuite) testUpdateSummary(c *C, db *sql.DB) {
 	summaryInfo.addFailedNum()
 	summaryInfo.addIgnoreNum()
 
-	err := updateTableSummary(context.Background(), db, "target", "test", "checkpoint", summaryInfo)
+	err := updateTableSummary(context.Background(), db, "test", "checkpoint", summaryInfo.toTableSummary())
 	c.Assert(err, IsNil)
 
 	total, successNum, failedNum, ignoreNum, state, err := getTableSummary(context.Background(), db, "test", "checkpoint")
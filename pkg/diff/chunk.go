@@ -226,6 +226,9 @@ func (s *randomSpliter) split(table *TableInstance, columns []*model.ColumnInfo,
 	s.collation = collation
 
 	// get the chunk count by data count and chunk size
+	if err := table.Throttler.WaitQuery(context.Background()); err != nil {
+		return nil, errors.Trace(err)
+	}
 	cnt, err := dbutil.GetRowCount(context.Background(), table.Conn, table.Schema, table.Table, limits, nil)
 	if err != nil {
 		return nil, errors.Trace(err)
@@ -233,7 +236,7 @@ func (s *randomSpliter) split(table *TableInstance, columns []*model.ColumnInfo,
 
 	chunkCnt := (int(cnt) + chunkSize - 1) / chunkSize
 	log.Info("split range by random", zap.Int64("row count", cnt), zap.Int("split chunk num", chunkCnt))
-	chunks, err := splitRangeByRandom(table.Conn, NewChunkRange(), chunkCnt, table.Schema, table.Table, columns, s.limits, s.collation)
+	chunks, err := splitRangeByRandom(table.Conn, table.Throttler, NewChunkRange(), chunkCnt, table.Schema, table.Table, columns, s.limits, s.collation)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -241,8 +244,8 @@ func (s *randomSpliter) split(table *TableInstance, columns []*model.ColumnInfo,
 	return chunks, nil
 }
 
-// splitRangeByRandom splits a chunk to multiple chunks by random
-func splitRangeByRandom(db *sql.DB, chunk *ChunkRange, count int, schema string, table string, columns []*model.ColumnInfo, limits, collation string) (chunks []*ChunkRange, err error) {
+// splitRangeByRandom splits a chunk to multiple chunks by random, the queries are limited by the throttler
+func splitRangeByRandom(db *sql.DB, throttler *Throttler, chunk *ChunkRange, count int, schema string, table string, columns []*model.ColumnInfo, limits, collation string) (chunks []*ChunkRange, err error) {
 	if count <= 1 {
 		chunks = append(chunks, chunk)
 		return chunks, nil
@@ -253,6 +256,9 @@ func splitRangeByRandom(db *sql.DB, chunk *ChunkRange, count int, schema string,
 
 	randomValues := make([][]string, len(columns))
 	for i, column := range columns {
+		if err = throttler.WaitQuery(context.Background()); err != nil {
+			return nil, errors.Trace(err)
+		}
 		randomValues[i], err = dbutil.GetRandomValues(context.Background(), db, schema, table, column.Name.O, count-1, limitRange, utils.StringsToInterfaces(args), collation)
 		if err != nil {
 			return nil, errors.Trace(err)
@@ -298,6 +304,9 @@ func (s *bucketSpliter) split(table *TableInstance, columns []*model.ColumnInfo,
 	s.limits = limits
 	s.collation = collation
 
+	if err := s.table.Throttler.WaitQuery(context.Background()); err != nil {
+		return nil, errors.Trace(err)
+	}
 	buckets, err := dbutil.GetBucketsInfo(context.Background(), s.table.Conn, s.table.Schema, s.table.Table, s.table.info)
 	if err != nil {
 		return nil, errors.Trace(err)
@@ -363,7 +372,7 @@ func (s *bucketSpliter) getChunksByBuckets() (chunks []*ChunkRange, err error) {
 			if count == 0 {
 				continue
 			} else if count >= 2 {
-				splitChunks, err := splitRangeByRandom(s.table.Conn, chunk, int(count), s.table.Schema, s.table.Table, indexColumns, s.limits, s.collation)
+				splitChunks, err := splitRangeByRandom(s.table.Conn, s.table.Throttler, chunk, int(count), s.table.Schema, s.table.Table, indexColumns, s.limits, s.collation)
 				if err != nil {
 					return nil, errors.Trace(err)
 				}
@@ -401,6 +410,20 @@ func getChunksForTable(table *TableInstance, columns []*model.ColumnInfo, chunkS
 	return chunks, err
 }
 
+// parseSplitFields parses the fields split by ',' in config.
+func parseSplitFields(splitFields string) []string {
+	var splitFieldArr []string
+	if len(splitFields) != 0 {
+		splitFieldArr = strings.Split(splitFields, ",")
+	}
+
+	for i := range splitFieldArr {
+		splitFieldArr[i] = strings.TrimSpace(splitFieldArr[i])
+	}
+
+	return splitFieldArr
+}
+
 // getSplitFields returns fields to split chunks, order by pk, uk, index, columns.
 func getSplitFields(table *model.TableInfo, splitFields []string) ([]*model.ColumnInfo, error) {
 	cols := make([]*model.ColumnInfo, 0, len(table.Columns))
@@ -435,17 +458,8 @@ func getSplitFields(table *model.TableInfo, splitFields []string) ([]*model.Colu
 }
 
 // SplitChunks splits the table to some chunks.
-func SplitChunks(ctx context.Context, table *TableInstance, splitFields, limits string, chunkSize int, collation string, useTiDBStatsInfo bool, cpDB *sql.DB) (chunks []*ChunkRange, err error) {
-	var splitFieldArr []string
-	if len(splitFields) != 0 {
-		splitFieldArr = strings.Split(splitFields, ",")
-	}
-
-	for i := range splitFieldArr {
-		splitFieldArr[i] = strings.TrimSpace(splitFieldArr[i])
-	}
-
-	fields, err := getSplitFields(table.info, splitFieldArr)
+func SplitChunks(ctx context.Context, table *TableInstance, splitFields, limits string, chunkSize int, collation string, useTiDBStatsInfo bool, store CheckpointStore) (chunks []*ChunkRange, err error) {
+	fields, err := getSplitFields(table.info, parseSplitFields(splitFields))
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -470,7 +484,7 @@ func SplitChunks(ctx context.Context, table *TableInstance, splitFields, limits
 	ctx1, cancel1 := context.WithTimeout(ctx, time.Duration(len(chunks))*dbutil.DefaultTimeout)
 	defer cancel1()
 
-	err = initChunks(ctx1, cpDB, table.InstanceID, table.Schema, table.Table, chunks)
+	err = store.InitChunks(ctx1, table.InstanceID, table.Schema, table.Table, chunks)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
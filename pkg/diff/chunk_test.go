@@ -243,7 +243,7 @@ func (*testChunkSuite) TestRangeLimit(c *C) {
 	}
 
 	c.Assert(createCheckpointTable(ctx, conn), IsNil)
-	chunks, err := SplitChunks(ctx, tableInstance, "a,d", "a > 7", 1, "", false, conn)
+	chunks, err := SplitChunks(ctx, tableInstance, "a,d", "a > 7", 1, "", false, NewSQLCheckpointStore(conn))
 	c.Assert(err, IsNil)
 	defer conn.ExecContext(ctx, "DROP DATABASE sync_diff_inspector")
 	// a > 7 and chunkSize = 1 should return 2 chunk
@@ -251,7 +251,7 @@ func (*testChunkSuite) TestRangeLimit(c *C) {
 	count := 0
 
 	for _, chunk := range chunks {
-		rows, _, err := getChunkRows(ctx, conn, "test", "test_range", tableInfo, chunk.Where, utils.StringsToInterfaces(chunk.Args), "")
+		rows, _, err := getChunkRows(ctx, conn, dbutil.TableName("test", "test_range"), tableInfo, nil, chunk.Where, utils.StringsToInterfaces(chunk.Args), "")
 		c.Assert(err, IsNil)
 		for rows.Next() {
 			count++
@@ -0,0 +1,226 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	column "github.com/pingcap/tidb-tools/pkg/column-mapping"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb-tools/pkg/utils"
+)
+
+// columnMapper maps the column's value of a source table to the value in target by the column mapping rule,
+// so the source table can be compared with the target table migrated by the same rule.
+type columnMapper struct {
+	mapping *column.Mapping
+	rule    *column.Rule
+
+	schema string
+	table  string
+	// the columns passed to HandleRowValue, the mapping caches the column's position by table, so they must be same every time
+	columns []string
+	col     *model.ColumnInfo
+	offset  int
+	// the column's name in target, is different from col's name if the column is renamed in target
+	name string
+
+	// only valid when the expression is partition id, the mapped value is `value | partitionBits`
+	partitionBits int64
+}
+
+// newColumnMapper returns the mapper of the source table, returns nil if the table doesn't need to be mapped.
+func newColumnMapper(table *TableInstance, ignoreColumns []string) (*columnMapper, error) {
+	rule, err := table.ColumnMapping.QueryRule(table.Schema, table.Table)
+	if err != nil {
+		return nil, errors.Annotatef(err, "query column mapping rule for table %s", dbutil.TableName(table.Schema, table.Table))
+	}
+	if rule == nil {
+		return nil, nil
+	}
+
+	for _, ignoreColumn := range ignoreColumns {
+		if strings.EqualFold(ignoreColumn, rule.TargetColumn) {
+			return nil, nil
+		}
+	}
+
+	m := &columnMapper{
+		mapping: table.ColumnMapping,
+		rule:    rule,
+		schema:  table.Schema,
+		table:   table.Table,
+		columns: make([]string, 0, len(table.info.Columns)),
+		offset:  -1,
+	}
+	for i, col := range table.info.Columns {
+		m.columns = append(m.columns, col.Name.O)
+		if col.Name.O == rule.TargetColumn {
+			m.col = col
+			m.offset = i
+			m.name = col.Name.O
+		}
+	}
+	if m.col == nil {
+		return nil, errors.NotFoundf("column mapping target column %s in table %s", rule.TargetColumn, dbutil.TableName(table.Schema, table.Table))
+	}
+
+	// the source rows are merged by the order key, so the mapping should keep the order of the rows
+	orderKeys, _ := dbutil.SelectUniqueOrderKey(table.info)
+	for _, key := range orderKeys {
+		if key != m.col.Name.O {
+			continue
+		}
+		if rule.Expression == column.AddSuffix || (rule.Expression == column.PartitionID && !mysql.IsIntegerType(m.col.Tp)) {
+			return nil, errors.NotSupportedf("column mapping %s on the order key %s of table %s", rule.Expression, m.col.Name.O, dbutil.TableName(table.Schema, table.Table))
+		}
+	}
+
+	if rule.Expression == column.PartitionID {
+		// the id is combined with the instance id, schema id and table id by OR, so map 0 to get them
+		vals := make([]interface{}, len(m.columns))
+		vals[m.offset] = int64(0)
+		vals, _, err = m.mapping.HandleRowValue(m.schema, m.table, m.columns, vals)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		m.partitionBits = vals[m.offset].(int64)
+	}
+
+	return m, nil
+}
+
+// mapRow maps the column's value of the row selected from source.
+func (m *columnMapper) mapRow(row map[string]*dbutil.ColumnData) error {
+	data, ok := row[m.name]
+	if !ok || data.IsNull {
+		return nil
+	}
+
+	// the data is string, and the mapped value of string is also string
+	vals := make([]interface{}, len(m.columns))
+	vals[m.offset] = string(data.Data)
+	vals, _, err := m.mapping.HandleRowValue(m.schema, m.table, m.columns, vals)
+	if err != nil {
+		return errors.Annotatef(err, "map column %s in table %s", m.col.Name.O, dbutil.TableName(m.schema, m.table))
+	}
+	data.Data = []byte(vals[m.offset].(string))
+
+	return nil
+}
+
+// columnExprs returns the expression of the mapped column used to calculate checksum.
+func (m *columnMapper) columnExprs() map[string]string {
+	columnName := dbutil.ColumnName(m.col.Name.O)
+
+	var expr string
+	switch m.rule.Expression {
+	case column.AddPrefix:
+		expr = fmt.Sprintf("CONCAT(%s, %s)", quoteString(m.rule.Arguments[0]), columnName)
+	case column.AddSuffix:
+		expr = fmt.Sprintf("CONCAT(%s, %s)", columnName, quoteString(m.rule.Arguments[0]))
+	case column.PartitionID:
+		expr = fmt.Sprintf("(%s | %d)", columnName, m.partitionBits)
+	default:
+		return nil
+	}
+
+	return map[string]string{m.name: expr}
+}
+
+// mapChunk converts the chunk's bounds of the mapped column to the value in source, returns nil if the chunk doesn't need to be converted.
+func (m *columnMapper) mapChunk(chunk *ChunkRange) (*ChunkRange, error) {
+	offset := -1
+	for i, bound := range chunk.Bounds {
+		if bound.Column == m.name {
+			offset = i
+		}
+	}
+	if offset == -1 {
+		return nil, nil
+	}
+	if m.rule.Expression != column.PartitionID || !mysql.IsIntegerType(m.col.Tp) {
+		return nil, errors.NotSupportedf("column mapping %s on the column %s used to split chunks, please set index-fields to other columns. it's", m.rule.Expression, m.name)
+	}
+
+	// the origin id is in [0, maxOriginID), and the bits of partition are higher than it, so the mapped value is `id + partitionBits`.
+	// the bound less than partitionBits is converted to -1, which is less than all the ids, and the bound greater than the max mapped value
+	// is still greater than all the ids after subtracted partitionBits.
+	shift := func(value string) (string, error) {
+		v, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "", errors.Trace(err)
+		}
+		if v < m.partitionBits {
+			return "-1", nil
+		}
+		return strconv.FormatInt(v-m.partitionBits, 10), nil
+	}
+
+	var err error
+	newChunk := chunk.copy()
+	bound := newChunk.Bounds[offset]
+	if bound.HasLower {
+		if bound.Lower, err = shift(bound.Lower); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	if bound.HasUpper {
+		if bound.Upper, err = shift(bound.Upper); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+
+	return newChunk, nil
+}
+
+// sourceWhere returns the condition and args used to select the range of chunk from the source table.
+func (t *TableDiff) sourceWhere(table *TableInstance, chunk *ChunkRange) (string, []interface{}, error) {
+	mappedChunk := chunk
+	if table.mapper != nil {
+		newChunk, err := table.mapper.mapChunk(mappedChunk)
+		if err != nil {
+			return "", nil, errors.Trace(err)
+		}
+		if newChunk != nil {
+			mappedChunk = newChunk
+		}
+	}
+	// the bounds are converted to the value in source before renamed
+	if table.columns != nil {
+		newChunk, err := table.columns.renameChunk(mappedChunk)
+		if err != nil {
+			return "", nil, errors.Trace(err)
+		}
+		if newChunk != nil {
+			mappedChunk = newChunk
+		}
+	}
+	if mappedChunk == chunk {
+		return chunk.Where, utils.StringsToInterfaces(chunk.Args), nil
+	}
+
+	conditions, args := mappedChunk.toString(t.Collation)
+	return fmt.Sprintf("((%s) AND %s)", conditions, t.Range), utils.StringsToInterfaces(args), nil
+}
+
+// quoteString quotes the string as a string literal in sql.
+func quoteString(s string) string {
+	return fmt.Sprintf("'%s'", escapeString(s))
+}
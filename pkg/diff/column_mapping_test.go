@@ -0,0 +1,122 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"fmt"
+	"strconv"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/parser"
+	column "github.com/pingcap/tidb-tools/pkg/column-mapping"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+)
+
+var _ = Suite(&testColumnMappingSuite{})
+
+type testColumnMappingSuite struct{}
+
+func (s *testColumnMappingSuite) TestColumnMapper(c *C) {
+	tableInfo, err := dbutil.GetTableInfoBySQL("CREATE TABLE `t` (`id` bigint, `name` varchar(24), `age` int, primary key(`id`))", parser.New())
+	c.Assert(err, IsNil)
+
+	mapping, err := column.NewMapping(false, []*column.Rule{
+		{PatternSchema: "test_*", PatternTable: "t_*", TargetColumn: "id", Expression: column.PartitionID, Arguments: []string{"1", "test", "t", "_"}},
+		{PatternSchema: "prefix", PatternTable: "t", TargetColumn: "name", Expression: column.AddPrefix, Arguments: []string{"a'b\\:"}},
+		{PatternSchema: "suffix", PatternTable: "t", TargetColumn: "id", Expression: column.AddSuffix, Arguments: []string{":c"}},
+	})
+	c.Assert(err, IsNil)
+
+	// the table doesn't match any rule
+	mapper, err := newColumnMapper(&TableInstance{Schema: "test", Table: "t", info: tableInfo, ColumnMapping: mapping}, nil)
+	c.Assert(err, IsNil)
+	c.Assert(mapper, IsNil)
+
+	// the mapped column is ignored
+	mapper, err = newColumnMapper(&TableInstance{Schema: "test_1", Table: "t_2", info: tableInfo, ColumnMapping: mapping}, []string{"ID"})
+	c.Assert(err, IsNil)
+	c.Assert(mapper, IsNil)
+
+	// partition id, instance id is 1, schema id is 1 and table id is 2
+	var partitionBits int64 = 1<<59 | 1<<52 | 2<<44
+	mapper, err = newColumnMapper(&TableInstance{Schema: "test_1", Table: "t_2", info: tableInfo, ColumnMapping: mapping}, nil)
+	c.Assert(err, IsNil)
+	c.Assert(mapper.partitionBits, Equals, partitionBits)
+
+	row := map[string]*dbutil.ColumnData{
+		"id":   {Data: []byte("5")},
+		"name": {Data: []byte("a")},
+		"age":  {IsNull: true},
+	}
+	c.Assert(mapper.mapRow(row), IsNil)
+	c.Assert(string(row["id"].Data), Equals, strconv.FormatInt(partitionBits+5, 10))
+	c.Assert(string(row["name"].Data), Equals, "a")
+	c.Assert(mapper.columnExprs(), DeepEquals, map[string]string{"id": fmt.Sprintf("(`id` | %d)", partitionBits)})
+
+	// the bounds of id are converted to the value in source
+	tableDiff := &TableDiff{Range: "TRUE"}
+	sourceTable := &TableInstance{mapper: mapper}
+	chunk := NewChunkRange()
+	chunk.addBound(&Bound{Column: "id", Lower: strconv.FormatInt(partitionBits+10, 10), Upper: strconv.FormatInt(partitionBits+20, 10), HasLower: true, HasUpper: true})
+	chunk.Where, chunk.Args = chunk.toString("")
+	where, args, err := tableDiff.sourceWhere(sourceTable, chunk)
+	c.Assert(err, IsNil)
+	c.Assert(where, Equals, "((((`id` > ?)) AND ((`id` <= ?))) AND TRUE)")
+	c.Assert(args, DeepEquals, []interface{}{"10", "20"})
+	c.Assert(chunk.Bounds[0].Lower, Equals, strconv.FormatInt(partitionBits+10, 10))
+
+	// the bound less than the partition bits
+	chunk = NewChunkRange()
+	chunk.addBound(&Bound{Column: "id", Lower: "100", Upper: strconv.FormatInt(partitionBits+20, 10), HasLower: true, HasUpper: true})
+	_, args, err = tableDiff.sourceWhere(sourceTable, chunk)
+	c.Assert(err, IsNil)
+	c.Assert(args, DeepEquals, []interface{}{"-1", "20"})
+
+	// the chunk is not split by the mapped column
+	chunk = NewChunkRange()
+	chunk.addBound(&Bound{Column: "age", Lower: "1", HasLower: true})
+	chunk.Where, chunk.Args = "`age` > ?", []string{"1"}
+	where, args, err = tableDiff.sourceWhere(sourceTable, chunk)
+	c.Assert(err, IsNil)
+	c.Assert(where, Equals, "`age` > ?")
+	c.Assert(args, DeepEquals, []interface{}{"1"})
+
+	// add prefix
+	mapper, err = newColumnMapper(&TableInstance{Schema: "prefix", Table: "t", info: tableInfo, ColumnMapping: mapping}, nil)
+	c.Assert(err, IsNil)
+	row = map[string]*dbutil.ColumnData{
+		"id":   {Data: []byte("5")},
+		"name": {Data: []byte("a")},
+		"age":  {IsNull: true},
+	}
+	c.Assert(mapper.mapRow(row), IsNil)
+	c.Assert(string(row["id"].Data), Equals, "5")
+	c.Assert(string(row["name"].Data), Equals, "a'b\\:a")
+	c.Assert(mapper.columnExprs(), DeepEquals, map[string]string{"name": "CONCAT('a\\'b\\\\:', `name`)"})
+
+	// the null value is not mapped
+	row["name"] = &dbutil.ColumnData{IsNull: true}
+	c.Assert(mapper.mapRow(row), IsNil)
+	c.Assert(row["name"].IsNull, IsTrue)
+
+	// can't convert the bounds of the prefixed column
+	chunk = NewChunkRange()
+	chunk.addBound(&Bound{Column: "name", Lower: "a", HasLower: true})
+	_, _, err = tableDiff.sourceWhere(&TableInstance{mapper: mapper}, chunk)
+	c.Assert(err, ErrorMatches, ".*not supported.*")
+
+	// add suffix on the order key breaks the order of rows
+	_, err = newColumnMapper(&TableInstance{Schema: "suffix", Table: "t", info: tableInfo, ColumnMapping: mapping}, nil)
+	c.Assert(err, ErrorMatches, ".*not supported.*")
+}
@@ -0,0 +1,248 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+)
+
+// TargetOnlyColumn is the column only exists in the target table, for example the column added in target first,
+// all the rows in target are expected to have the same value in it.
+type TargetOnlyColumn struct {
+	// the expected value in the format of the value selected from database, for example "1" or "2021-01-01 00:00:00"
+	Value string `toml:"value" json:"value"`
+	// set true if the expected value is NULL
+	IsNull bool `toml:"is-null" json:"is-null"`
+}
+
+// sourceColumns maps the columns of the target table to the columns of a source table,
+// so the source table can be compared with the target table which has renamed or extra columns.
+type sourceColumns struct {
+	// target column name => source column name, only contains the renamed columns
+	names map[string]string
+	// target column name => the expression selects the column's value from source, contains the renamed and target-only columns
+	exprs map[string]string
+}
+
+// newSourceColumns returns the columns mapping of the source table, returns nil if the columns don't need to be mapped.
+func newSourceColumns(sourceInfo, targetInfo *model.TableInfo, renamedColumns map[string]string, targetOnlyColumns map[string]*TargetOnlyColumn, ignoreColumns []string) (*sourceColumns, error) {
+	if len(renamedColumns) == 0 && len(targetOnlyColumns) == 0 {
+		return nil, nil
+	}
+
+	isIgnored := func(name string) bool {
+		for _, column := range ignoreColumns {
+			if strings.EqualFold(column, name) {
+				return true
+			}
+		}
+		return false
+	}
+	orderKeys, _ := dbutil.SelectUniqueOrderKey(targetInfo)
+	isOrderKey := func(col *model.ColumnInfo) bool {
+		for _, key := range orderKeys {
+			if key == col.Name.O {
+				return true
+			}
+		}
+		return false
+	}
+
+	s := &sourceColumns{
+		names: make(map[string]string),
+		exprs: make(map[string]string),
+	}
+	for sourceName, targetName := range renamedColumns {
+		if isIgnored(targetName) {
+			continue
+		}
+		sourceCol := dbutil.FindColumnByName(sourceInfo.Columns, sourceName)
+		if sourceCol == nil {
+			return nil, errors.NotFoundf("renamed column %s in source table", sourceName)
+		}
+		targetCol := dbutil.FindColumnByName(targetInfo.Columns, targetName)
+		if targetCol == nil {
+			return nil, errors.NotFoundf("renamed column %s in target table", targetName)
+		}
+		if _, ok := s.names[targetCol.Name.O]; ok {
+			return nil, errors.AlreadyExistsf("column %s renamed from more than one source columns", targetCol.Name.O)
+		}
+		s.names[targetCol.Name.O] = sourceCol.Name.O
+		s.exprs[targetCol.Name.O] = dbutil.ColumnName(sourceCol.Name.O)
+	}
+
+	for name, column := range targetOnlyColumns {
+		if isIgnored(name) {
+			continue
+		}
+		targetCol := dbutil.FindColumnByName(targetInfo.Columns, name)
+		if targetCol == nil {
+			return nil, errors.NotFoundf("target only column %s in target table", name)
+		}
+		if _, ok := s.names[targetCol.Name.O]; ok {
+			return nil, errors.AlreadyExistsf("target only column %s in renamed columns", targetCol.Name.O)
+		}
+		if dbutil.FindColumnByName(sourceInfo.Columns, targetCol.Name.O) != nil {
+			return nil, errors.AlreadyExistsf("target only column %s in source table", targetCol.Name.O)
+		}
+		// the rows in source are merged by the order key, the constant value can't be used to order them
+		if isOrderKey(targetCol) {
+			return nil, errors.NotSupportedf("target only column %s as the order key", targetCol.Name.O)
+		}
+
+		if column == nil || column.IsNull {
+			s.exprs[targetCol.Name.O] = "NULL"
+		} else {
+			s.exprs[targetCol.Name.O] = quoteString(column.Value)
+		}
+	}
+
+	return s, nil
+}
+
+// targetName returns the column's name in target of the source column.
+func (s *sourceColumns) targetName(sourceName string) string {
+	for targetName, name := range s.names {
+		if name == sourceName {
+			return targetName
+		}
+	}
+	return sourceName
+}
+
+// checksumExprs returns the expressions of the columns used to calculate checksum in the source table.
+func (t *TableInstance) checksumExprs() map[string]string {
+	if t.columns == nil && t.mapper == nil {
+		return nil
+	}
+
+	columnExprs := make(map[string]string)
+	if t.columns != nil {
+		for name, expr := range t.columns.exprs {
+			columnExprs[name] = expr
+		}
+	}
+	// the expression of mapped column uses the column's name in source
+	if t.mapper != nil {
+		for name, expr := range t.mapper.columnExprs() {
+			columnExprs[name] = expr
+		}
+	}
+
+	return columnExprs
+}
+
+// renameChunk converts the chunk's bounds of the renamed columns to the columns in source, returns nil if the chunk doesn't need to be converted.
+func (s *sourceColumns) renameChunk(chunk *ChunkRange) (*ChunkRange, error) {
+	renamed := false
+	for _, bound := range chunk.Bounds {
+		if _, ok := s.names[bound.Column]; ok {
+			renamed = true
+			continue
+		}
+		if _, ok := s.exprs[bound.Column]; ok {
+			return nil, errors.NotSupportedf("the target only column %s used to split chunks, please set index-fields to other columns. it's", bound.Column)
+		}
+	}
+	if !renamed {
+		return nil, nil
+	}
+
+	newChunk := NewChunkRange()
+	for _, bound := range chunk.Bounds {
+		column := bound.Column
+		if sourceName, ok := s.names[column]; ok {
+			column = sourceName
+		}
+		newChunk.addBound(&Bound{
+			Column:   column,
+			Lower:    bound.Lower,
+			Upper:    bound.Upper,
+			HasLower: bound.HasLower,
+			HasUpper: bound.HasUpper,
+		})
+	}
+
+	return newChunk, nil
+}
+
+// structInfos returns the source and target table info used to check the struct, the renamed columns in source are renamed
+// to the name in target and are ordered as the columns in target, and the target only columns are removed from target.
+func (s *sourceColumns) structInfos(sourceInfo, targetInfo *model.TableInfo) (*model.TableInfo, *model.TableInfo) {
+	renameColumn := func(col *model.ColumnInfo) *model.ColumnInfo {
+		targetName := s.targetName(col.Name.O)
+		if targetName == col.Name.O {
+			return col
+		}
+		newCol := col.Clone()
+		newCol.Name = model.NewCIStr(targetName)
+		return newCol
+	}
+
+	newSourceInfo := *sourceInfo
+	newSourceInfo.Columns = make([]*model.ColumnInfo, 0, len(sourceInfo.Columns))
+	newSourceInfo.Indices = make([]*model.IndexInfo, 0, len(sourceInfo.Indices))
+	// the columns in target first, and then the columns only in source
+	for _, targetCol := range targetInfo.Columns {
+		for _, col := range sourceInfo.Columns {
+			if s.targetName(col.Name.O) == targetCol.Name.O {
+				newSourceInfo.Columns = append(newSourceInfo.Columns, renameColumn(col))
+			}
+		}
+	}
+	for _, col := range sourceInfo.Columns {
+		if dbutil.FindColumnByName(targetInfo.Columns, s.targetName(col.Name.O)) == nil {
+			newSourceInfo.Columns = append(newSourceInfo.Columns, col)
+		}
+	}
+	for _, index := range sourceInfo.Indices {
+		newIndex := index.Clone()
+		for _, col := range newIndex.Columns {
+			col.Name = model.NewCIStr(s.targetName(col.Name.O))
+		}
+		newSourceInfo.Indices = append(newSourceInfo.Indices, newIndex)
+	}
+
+	isTargetOnly := func(name string) bool {
+		_, isExpr := s.exprs[name]
+		_, isRenamed := s.names[name]
+		return isExpr && !isRenamed
+	}
+	newTargetInfo := *targetInfo
+	newTargetInfo.Columns = make([]*model.ColumnInfo, 0, len(targetInfo.Columns))
+	newTargetInfo.Indices = make([]*model.IndexInfo, 0, len(targetInfo.Indices))
+	for _, col := range targetInfo.Columns {
+		if !isTargetOnly(col.Name.O) {
+			newTargetInfo.Columns = append(newTargetInfo.Columns, col)
+		}
+	}
+	for _, index := range targetInfo.Indices {
+		hasTargetOnlyColumn := false
+		for _, col := range index.Columns {
+			if isTargetOnly(col.Name.O) {
+				hasTargetOnlyColumn = true
+				break
+			}
+		}
+		if !hasTargetOnlyColumn {
+			newTargetInfo.Indices = append(newTargetInfo.Indices, index)
+		}
+	}
+
+	return &newSourceInfo, &newTargetInfo
+}
@@ -0,0 +1,94 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	. "github.com/pingcap/check"
+	"github.com/pingcap/parser"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+)
+
+var _ = Suite(&testColumnNamesSuite{})
+
+type testColumnNamesSuite struct{}
+
+func (s *testColumnNamesSuite) TestSourceColumns(c *C) {
+	sourceInfo, err := dbutil.GetTableInfoBySQL("CREATE TABLE `t` (`id` int, `name` varchar(24), `age` int, primary key(`id`), key `k_name`(`name`))", parser.New())
+	c.Assert(err, IsNil)
+	targetInfo, err := dbutil.GetTableInfoBySQL("CREATE TABLE `t` (`id` int, `status` int, `age` int, `user_name` varchar(24), `remark` varchar(24), primary key(`id`), key `k_name`(`user_name`), key `k_status`(`status`))", parser.New())
+	c.Assert(err, IsNil)
+
+	columns, err := newSourceColumns(sourceInfo, targetInfo, nil, nil, nil)
+	c.Assert(err, IsNil)
+	c.Assert(columns, IsNil)
+
+	// the struct is not equal without column mapping
+	eq, _ := dbutil.EqualTableInfo(sourceInfo, targetInfo)
+	c.Assert(eq, IsFalse)
+
+	renamedColumns := map[string]string{"NAME": "user_name"}
+	targetOnlyColumns := map[string]*TargetOnlyColumn{
+		"status": {Value: "1"},
+		"remark": {IsNull: true},
+	}
+	columns, err = newSourceColumns(sourceInfo, targetInfo, renamedColumns, targetOnlyColumns, nil)
+	c.Assert(err, IsNil)
+	c.Assert(columns.names, DeepEquals, map[string]string{"user_name": "name"})
+	c.Assert(columns.exprs, DeepEquals, map[string]string{"user_name": "`name`", "status": "'1'", "remark": "NULL"})
+	c.Assert(columns.targetName("name"), Equals, "user_name")
+	c.Assert(columns.targetName("age"), Equals, "age")
+
+	// the columns are renamed and ordered as target, the target only columns are removed
+	structSourceInfo, structTargetInfo := columns.structInfos(sourceInfo, targetInfo)
+	eq, msg := dbutil.EqualTableInfo(structSourceInfo, structTargetInfo)
+	c.Assert(eq, IsTrue, Commentf(msg))
+	c.Assert(sourceInfo.Columns[1].Name.O, Equals, "name")
+	c.Assert(targetInfo.Columns, HasLen, 5)
+
+	// the bounds of renamed columns are converted to the columns in source
+	tableDiff := &TableDiff{Range: "TRUE"}
+	sourceTable := &TableInstance{columns: columns}
+	chunk := NewChunkRange()
+	chunk.addBound(&Bound{Column: "id", Lower: "1", HasLower: true})
+	chunk.addBound(&Bound{Column: "user_name", Lower: "a", Upper: "b", HasLower: true, HasUpper: true})
+	chunk.Where, chunk.Args = chunk.toString("")
+	where, args, err := tableDiff.sourceWhere(sourceTable, chunk)
+	c.Assert(err, IsNil)
+	c.Assert(where, Matches, ".*`name`.*")
+	c.Assert(where, Not(Matches), ".*`user_name`.*")
+	c.Assert(args, DeepEquals, []interface{}{"1", "1", "a", "b"})
+	c.Assert(chunk.Bounds[1].Column, Equals, "user_name")
+
+	chunk = NewChunkRange()
+	chunk.addBound(&Bound{Column: "status", Lower: "1", HasLower: true})
+	_, _, err = tableDiff.sourceWhere(sourceTable, chunk)
+	c.Assert(err, ErrorMatches, ".*not supported.*")
+
+	c.Assert(sourceTable.checksumExprs(), DeepEquals, columns.exprs)
+	c.Assert((&TableInstance{}).checksumExprs(), IsNil)
+
+	// the ignored columns are not mapped
+	columns, err = newSourceColumns(sourceInfo, targetInfo, renamedColumns, targetOnlyColumns, []string{"user_name", "Remark"})
+	c.Assert(err, IsNil)
+	c.Assert(columns.exprs, DeepEquals, map[string]string{"status": "'1'"})
+
+	_, err = newSourceColumns(sourceInfo, targetInfo, map[string]string{"nick": "user_name"}, nil, nil)
+	c.Assert(err, ErrorMatches, ".*not found.*")
+	_, err = newSourceColumns(sourceInfo, targetInfo, map[string]string{"name": "nick"}, nil, nil)
+	c.Assert(err, ErrorMatches, ".*not found.*")
+	_, err = newSourceColumns(sourceInfo, targetInfo, nil, map[string]*TargetOnlyColumn{"age": {}}, nil)
+	c.Assert(err, ErrorMatches, ".*already exists.*")
+	_, err = newSourceColumns(sourceInfo, targetInfo, nil, map[string]*TargetOnlyColumn{"id": {}}, nil)
+	c.Assert(err, ErrorMatches, ".*already exists.*")
+}
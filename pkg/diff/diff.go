@@ -21,6 +21,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/rand"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -31,6 +32,7 @@ import (
 	"github.com/pingcap/failpoint"
 	"github.com/pingcap/log"
 	"github.com/pingcap/parser/model"
+	column "github.com/pingcap/tidb-tools/pkg/column-mapping"
 	"github.com/pingcap/tidb-tools/pkg/dbutil"
 	"github.com/pingcap/tidb-tools/pkg/utils"
 	"go.uber.org/zap"
@@ -41,13 +43,31 @@ var (
 	cancelEqualFunc context.CancelFunc
 )
 
+const (
+	// CRC32ChecksumStrategy calculates chunk's checksum by BIT_XOR the rows' CRC32, duplicate rows will cancel out
+	CRC32ChecksumStrategy = "crc32"
+	// MD5ChecksumStrategy calculates chunk's checksum by summing up the rows' MD5 and counting the rows, can detect duplicate rows
+	MD5ChecksumStrategy = "md5"
+)
+
 // TableInstance record a table instance
 type TableInstance struct {
 	Conn       *sql.DB `json:"-"`
 	Schema     string  `json:"schema"`
 	Table      string  `json:"table"`
 	InstanceID string  `json:"instance-id"`
-	info       *model.TableInfo
+	// the query compared as a table, the schema and table are only used to identify it if is set, only valid for source tables
+	Query string `json:"query,omitempty"`
+	info  *model.TableInfo
+
+	// the column mapping rules used to migrate the source table, only valid for source tables
+	ColumnMapping *column.Mapping `json:"-"`
+	mapper        *columnMapper
+	// maps the renamed and target only columns in target to source, only valid for source tables
+	columns *sourceColumns
+
+	// limits the load of checking data on the instance, can be shared by the tables in the same instance, no limit if is nil
+	Throttler *Throttler `json:"-"`
 }
 
 // TableDiff saves config for diff table
@@ -60,6 +80,20 @@ type TableDiff struct {
 	// columns be ignored
 	IgnoreColumns []string `json:"-"`
 
+	// column name => the difference allowed when compare the column, AllColumnsTolerance applies to all the FLOAT, DOUBLE, DECIMAL and time columns
+	ColumnTolerances map[string]*ColumnTolerance `json:"-"`
+	columnTolerances map[string]*columnTolerance
+
+	// source column name => target column name, for the columns renamed in target
+	RenamedColumns map[string]string `json:"-"`
+
+	// target column name => the expected value, for the columns only exist in target
+	TargetOnlyColumns map[string]*TargetOnlyColumn `json:"-"`
+
+	// the columns identify a row, used as the primary key to order and split the rows, must be set when compare with the query without a unique key in target.
+	// they are only used by the query and the tables without primary key or unique key, can't be set if target has primary key or unique key
+	KeyColumns []string `json:"-"`
+
 	// field should be the primary key, unique key or field with index
 	Fields string `json:"fields"`
 
@@ -78,18 +112,51 @@ type TableDiff struct {
 	// how many goroutines are created to check data
 	CheckThreadCount int `json:"-"`
 
+	// limits the num of chunks checked concurrently, can be shared by tables checked concurrently, no limit if is nil
+	WorkerPool *WorkerPool `json:"-"`
+
+	// pauses checking chunks out of the allowed time windows or when paused manually, can be shared by tables checked concurrently, never pause if is nil
+	Pauser *Pauser `json:"-"`
+
+	// the num of chunks being checked, used to wait them finished before pause
+	runningChunks int
+	runningMu     sync.Mutex
+	runningCond   *sync.Cond
+
 	// set false if want to comapre the data directly
 	UseChecksum bool `json:"-"`
 
 	// set true if just want compare data by checksum, will skip select data when checksum is not equal
 	OnlyUseChecksum bool `json:"-"`
 
+	// set true will compare the row count before checksum, will skip checksum and select data directly when row count is not equal
+	UseRowCount bool `json:"-"`
+
+	// set true if just want to compare the row count, used to check append-only tables quickly
+	OnlyUseRowCount bool `json:"-"`
+
+	// set true will split the chunk whose checksum is not equal into sub ranges recursively, and only compare rows in the mismatching sub ranges
+	UseBisect bool `json:"-"`
+
+	// the max times to split a mismatching chunk, only valid when UseBisect is true
+	BisectMaxDepth int `json:"-"`
+
+	// the column saves the row's update time, will only check the rows updated since the last check if is set
+	UpdateTimeColumn string `json:"-"`
+
 	// collation config in mysql/tidb, should corresponding to charset.
 	Collation string `json:"collation"`
 
+	// the strategy used to calculate chunk's checksum, support crc32 and md5, default is crc32
+	ChecksumStrategy string `json:"-"`
+
 	// ignore check table's struct
 	IgnoreStructCheck bool `json:"-"`
 
+	// set true will write the DDLs converge the target table's struct to the source tables by writeFixSQL as comments,
+	// they should be reviewed and uncommented before executed
+	WriteStructDDLs bool `json:"-"`
+
 	// ignore check table's data
 	IgnoreDataCheck bool `json:"-"`
 
@@ -99,23 +166,198 @@ type TableDiff struct {
 	// get tidb statistics information from which table instance. if is nil, will split chunk by random.
 	TiDBStatsSource *TableInstance `json:"tidb-stats-source"`
 
-	sqlCh chan string
+	// saves the different rows found when comparing rows, will not save them if is nil
+	DiffSink DiffSink `json:"-"`
+
+	// set true will execute the fix sqls on target table after every chunk is checked, and then check the chunk again
+	ApplyFixSQL bool `json:"-"`
+
+	// set true will only log the fix sqls instead of executing them, only valid when ApplyFixSQL is true
+	FixSQLDryRun bool `json:"-"`
+
+	// will stop applying the fix sqls if the num of different rows (inserted, deleted and updated) in the table is greater than it, 0 means no limit
+	MaxFixRowNum int64 `json:"-"`
+
+	// the num of fix sqls executed in one transaction
+	FixSQLBatchSize int `json:"-"`
+
+	// saves the fix sqls by chunk, only used when ApplyFixSQL is true
+	fixSQLs *fixSQLCollector
+
+	// saves the sqls used to revert the fix sqls, will not generate them if is nil
+	WriteRevertSQL func(string) error `json:"-"`
+
+	// aggregates the check progress of tables, will not report the progress if is nil
+	Progress *Progress `json:"-"`
+
+	sqlCh chan *fixSQL
 
 	wg sync.WaitGroup
 
 	configHash string
 
+	// the database saves the checkpoint, only used when CheckpointStore is nil
 	CpDB *sql.DB `json:"-"`
 
+	// saves the check progress, will save it in CpDB if is nil
+	CheckpointStore CheckpointStore `json:"-"`
+
 	// 1 means true, 0 means false
 	checkpointLoaded int32
 
 	// create after all chunks is splited, or load from checkpoint
 	summaryInfo *tableSummaryInfo
+
+	// the differences between the struct of the source tables and the target table
+	structDiffs []*TableStructDiff
+}
+
+// TableStats saves the statistics of a table's data check.
+type TableStats struct {
+	ChunkNum        int64 `json:"chunk-num"`
+	SuccessChunkNum int64 `json:"success-chunk-num"`
+	FailedChunkNum  int64 `json:"failed-chunk-num"`
+	IgnoreChunkNum  int64 `json:"ignore-chunk-num"`
+
+	// chunks' data is not equal but is equal after applied fix sqls, they are not counted as success or failed chunks
+	RepairedChunkNum int64 `json:"repaired-chunk-num"`
+
+	// different rows fixed by applying fix sqls
+	FixedRowNum int64 `json:"fixed-row-num"`
+
+	// rows need to be inserted, deleted or updated in target table
+	InsertRowNum int64 `json:"insert-row-num"`
+	DeleteRowNum int64 `json:"delete-row-num"`
+	UpdateRowNum int64 `json:"update-row-num"`
+
+	// column name => the num of updated rows which have different value in this column
+	DiffColumnNum map[string]int64 `json:"diff-column-num,omitempty"`
+
+	// chunks whose row count is different, only valid when compare row count
+	CountMismatchChunkNum int64 `json:"count-mismatch-chunk-num"`
+	// the row count of the chunks compared by row count
+	SourceRowCount int64 `json:"source-row-count"`
+	TargetRowCount int64 `json:"target-row-count"`
+}
+
+// Stats returns the statistics of the latest data check, should be called after `Equal`.
+func (t *TableDiff) Stats() TableStats {
+	if t.summaryInfo == nil {
+		return TableStats{}
+	}
+
+	stats := TableStats{}
+	stats.ChunkNum, stats.SuccessChunkNum, stats.FailedChunkNum, stats.IgnoreChunkNum = t.summaryInfo.get()
+	stats.InsertRowNum, stats.DeleteRowNum, stats.UpdateRowNum = t.summaryInfo.getRowNum()
+	stats.DiffColumnNum = t.summaryInfo.getDiffColumnNum()
+	stats.RepairedChunkNum, stats.FixedRowNum = t.summaryInfo.getRepairNum()
+	stats.CountMismatchChunkNum, stats.SourceRowCount, stats.TargetRowCount = t.summaryInfo.getRowCount()
+
+	return stats
+}
+
+// checkpointConfig is the config which affects the check result of chunks, the checkpoint can only be used when it is not changed.
+// the config only affects how to split chunks or how fast to check, like chunk size and thread count, is not included.
+type checkpointConfig struct {
+	SourceTables     []*TableInstance `json:"source-tables"`
+	TargetTable      *TableInstance   `json:"target-table"`
+	Fields           string           `json:"fields"`
+	Range            string           `json:"range"`
+	Sample           int              `json:"sample"`
+	Collation        string           `json:"collation"`
+	IgnoreColumns    []string         `json:"ignore-columns,omitempty"`
+	ChecksumStrategy string           `json:"checksum-strategy,omitempty"`
+	OnlyUseRowCount  bool             `json:"only-use-row-count,omitempty"`
+	// source table => the column mapping rule
+	ColumnMappingRules map[string]*column.Rule `json:"column-mapping-rules,omitempty"`
+	// lower case column name => tolerance
+	ColumnTolerances map[string]*ColumnTolerance `json:"column-tolerances,omitempty"`
+	// lower case source column name => lower case target column name
+	RenamedColumns map[string]string `json:"renamed-columns,omitempty"`
+	// lower case column name => the expected value
+	TargetOnlyColumns map[string]*TargetOnlyColumn `json:"target-only-columns,omitempty"`
+	// lower case key column names
+	KeyColumns []string `json:"key-columns,omitempty"`
+}
+
+func (t *TableDiff) newCheckpointConfig() *checkpointConfig {
+	// the order of sharding source tables and ignore columns doesn't matter
+	sourceTables := append(make([]*TableInstance, 0, len(t.SourceTables)), t.SourceTables...)
+	sort.Slice(sourceTables, func(i, j int) bool {
+		if sourceTables[i].InstanceID != sourceTables[j].InstanceID {
+			return sourceTables[i].InstanceID < sourceTables[j].InstanceID
+		}
+		return dbutil.TableName(sourceTables[i].Schema, sourceTables[i].Table) < dbutil.TableName(sourceTables[j].Schema, sourceTables[j].Table)
+	})
+
+	var ignoreColumns []string
+	for _, column := range t.IgnoreColumns {
+		ignoreColumns = append(ignoreColumns, strings.ToLower(column))
+	}
+	sort.Strings(ignoreColumns)
+
+	var columnMappingRules map[string]*column.Rule
+	for _, sourceTable := range sourceTables {
+		// the error will be returned when check the table
+		rule, _ := sourceTable.ColumnMapping.QueryRule(sourceTable.Schema, sourceTable.Table)
+		if rule == nil || len(sourceTable.Query) != 0 {
+			continue
+		}
+		if columnMappingRules == nil {
+			columnMappingRules = make(map[string]*column.Rule)
+		}
+		columnMappingRules[fmt.Sprintf("%s.%s", sourceTable.InstanceID, dbutil.TableName(sourceTable.Schema, sourceTable.Table))] = rule
+	}
+
+	var columnTolerances map[string]*ColumnTolerance
+	for name, tolerance := range t.ColumnTolerances {
+		if columnTolerances == nil {
+			columnTolerances = make(map[string]*ColumnTolerance)
+		}
+		columnTolerances[strings.ToLower(name)] = tolerance
+	}
+
+	var renamedColumns map[string]string
+	for sourceName, targetName := range t.RenamedColumns {
+		if renamedColumns == nil {
+			renamedColumns = make(map[string]string)
+		}
+		renamedColumns[strings.ToLower(sourceName)] = strings.ToLower(targetName)
+	}
+
+	var targetOnlyColumns map[string]*TargetOnlyColumn
+	for name, column := range t.TargetOnlyColumns {
+		if targetOnlyColumns == nil {
+			targetOnlyColumns = make(map[string]*TargetOnlyColumn)
+		}
+		targetOnlyColumns[strings.ToLower(name)] = column
+	}
+
+	var keyColumns []string
+	for _, column := range t.KeyColumns {
+		keyColumns = append(keyColumns, strings.ToLower(column))
+	}
+
+	return &checkpointConfig{
+		SourceTables:       sourceTables,
+		TargetTable:        t.TargetTable,
+		Fields:             t.Fields,
+		Range:              t.Range,
+		Sample:             t.Sample,
+		Collation:          t.Collation,
+		IgnoreColumns:      ignoreColumns,
+		ChecksumStrategy:   t.ChecksumStrategy,
+		OnlyUseRowCount:    t.OnlyUseRowCount,
+		ColumnMappingRules: columnMappingRules,
+		ColumnTolerances:   columnTolerances,
+		RenamedColumns:     renamedColumns,
+		TargetOnlyColumns:  targetOnlyColumns,
+		KeyColumns:         keyColumns,
+	}
 }
 
 func (t *TableDiff) setConfigHash() error {
-	jsonBytes, err := json.Marshal(t)
+	jsonBytes, err := json.Marshal(t.newCheckpointConfig())
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -129,7 +371,13 @@ func (t *TableDiff) setConfigHash() error {
 // Equal tests whether two database have same data and schema.
 func (t *TableDiff) Equal(ctx context.Context, writeFixSQL func(string) error) (bool, bool, error) {
 	t.adjustConfig()
-	t.sqlCh = make(chan string)
+	if t.ChecksumStrategy != CRC32ChecksumStrategy && t.ChecksumStrategy != MD5ChecksumStrategy {
+		return false, false, errors.NotSupportedf("checksum strategy %s", t.ChecksumStrategy)
+	}
+	t.sqlCh = make(chan *fixSQL)
+	if t.ApplyFixSQL {
+		t.fixSQLs = newFixSQLCollector()
+	}
 
 	err := t.getTableInfo(ctx)
 	if err != nil {
@@ -145,12 +393,29 @@ func (t *TableDiff) Equal(ctx context.Context, writeFixSQL func(string) error) (
 			return false, false, errors.Trace(err)
 		}
 
+		if t.WriteStructDDLs {
+			if err = t.writeStructDDLs(writeFixSQL); err != nil {
+				log.Error("write struct ddls failed", zap.String("table", dbutil.TableName(t.TargetTable.Schema, t.TargetTable.Table)), zap.Error(err))
+			}
+		}
 		if !structEqual {
 			return false, false, nil
 		}
 	}
 
 	if !t.IgnoreDataCheck {
+		var highWatermark string
+		if len(t.UpdateTimeColumn) != 0 {
+			highWatermark, err = t.adjustIncrementalRange(ctx)
+			if err != nil {
+				return structEqual, false, errors.Trace(err)
+			}
+			if len(highWatermark) == 0 {
+				log.Info("no data is updated since the last check", zap.String("table", dbutil.TableName(t.TargetTable.Schema, t.TargetTable.Table)))
+				return structEqual, true, nil
+			}
+		}
+
 		stopWriteSqlsCh := t.WriteSqls(ctx, writeFixSQL)
 		stopUpdateSummaryCh := t.UpdateSummaryInfo(ctx)
 
@@ -168,24 +433,98 @@ func (t *TableDiff) Equal(ctx context.Context, writeFixSQL func(string) error) (
 		case <-ctx.Done():
 		case stopUpdateSummaryCh <- true:
 		}
+
+		var applyErr error
+		if t.fixSQLs != nil {
+			applyErr = t.fixSQLs.getErr()
+		}
+
+		if applyErr != nil {
+			t.wg.Wait()
+			return structEqual, false, errors.Trace(applyErr)
+		}
+
+		// the different rows need to be checked again, so only move the watermark forward when the data is equal
+		if len(highWatermark) != 0 && dataEqual {
+			if err = t.saveHighWatermark(ctx, highWatermark); err != nil {
+				t.wg.Wait()
+				return structEqual, dataEqual, errors.Trace(err)
+			}
+		}
 	}
 
 	t.wg.Wait()
 	return structEqual, dataEqual, nil
 }
 
-// CheckTableStruct checks table's struct
+// CheckTableStruct checks table's struct, the struct is equal if the columns and indices can be compared. the differences
+// with every source table are saved and can be got by StructDiffs, the struct may be equal even if there are differences.
 func (t *TableDiff) CheckTableStruct(ctx context.Context) (bool, error) {
+	t.structDiffs = nil
+	structEqual := true
 	for _, sourceTable := range t.SourceTables {
-		eq, msg := dbutil.EqualTableInfo(sourceTable.info, t.TargetTable.info)
+		if len(sourceTable.Query) != 0 {
+			log.Info("the struct of query can't be checked, skip it", zap.String("query", sourceTable.Query))
+			continue
+		}
+
+		sourceInfo, targetInfo := sourceTable.info, t.TargetTable.info
+		if sourceTable.columns != nil {
+			sourceInfo, targetInfo = sourceTable.columns.structInfos(sourceInfo, targetInfo)
+		}
+
+		structDiff := DiffTableStruct(t.TargetTable.Schema, t.TargetTable.Table, sourceInfo, targetInfo)
+		if len(structDiff.Diffs) != 0 {
+			structDiff.SourceTable = dbutil.TableName(sourceTable.Schema, sourceTable.Table)
+			log.Warn("table struct is different", zap.String("table", dbutil.TableName(t.TargetTable.Schema, t.TargetTable.Table)), zap.Stringer("diff", structDiff))
+			t.structDiffs = append(t.structDiffs, structDiff)
+		}
+
+		// only the differences of columns and indices make the data can't be compared
+		eq, msg := dbutil.EqualTableInfo(sourceInfo, targetInfo)
 		if !eq {
 			log.Warn("table struct is not equal", zap.String("reason", msg))
-			return false, nil
+			structEqual = false
+			continue
 		}
-		log.Info("table struct is equal", zap.Reflect("source", sourceTable.info), zap.Reflect("target", t.TargetTable.info))
+		log.Info("table struct is equal", zap.Reflect("source", sourceInfo), zap.Reflect("target", targetInfo))
 	}
 
-	return true, nil
+	return structEqual, nil
+}
+
+// StructDiffs returns the differences between the struct of the source tables and the target table, only valid after the struct is checked.
+func (t *TableDiff) StructDiffs() []*TableStructDiff {
+	return t.structDiffs
+}
+
+// writeStructDDLs writes the DDLs converge the target table's struct to the source tables as comments. the shards
+// usually have the same struct, so the same DDLs are only written once for the table.
+func (t *TableDiff) writeStructDDLs(writeFixSQL func(string) error) error {
+	written := make(map[string]struct{})
+	for _, structDiff := range t.structDiffs {
+		ddls := make([]string, 0, len(structDiff.DDLs))
+		for _, ddl := range structDiff.DDLs {
+			if _, ok := written[ddl]; !ok {
+				written[ddl] = struct{}{}
+				ddls = append(ddls, ddl)
+			}
+		}
+		if len(ddls) == 0 {
+			continue
+		}
+
+		if err := writeFixSQL(fmt.Sprintf("-- converge the struct to source table %s, review and uncomment the DDLs before execute them\n", structDiff.SourceTable)); err != nil {
+			return errors.Trace(err)
+		}
+		for _, ddl := range ddls {
+			if err := writeFixSQL(CommentDDL(ddl) + "\n"); err != nil {
+				return errors.Trace(err)
+			}
+		}
+	}
+
+	return nil
 }
 
 func (t *TableDiff) adjustConfig() {
@@ -208,21 +547,90 @@ func (t *TableDiff) adjustConfig() {
 	if t.CheckThreadCount <= 0 {
 		t.CheckThreadCount = 4
 	}
+
+	if len(t.ChecksumStrategy) == 0 {
+		t.ChecksumStrategy = CRC32ChecksumStrategy
+	}
+
+	if t.BisectMaxDepth <= 0 {
+		t.BisectMaxDepth = defaultBisectMaxDepth
+	}
+
+	if t.CheckpointStore == nil {
+		t.CheckpointStore = NewSQLCheckpointStore(t.CpDB)
+	}
 }
 
 func (t *TableDiff) getTableInfo(ctx context.Context) error {
+	if len(t.TargetTable.Query) != 0 {
+		return errors.NotSupportedf("query in target table")
+	}
 	tableInfo, err := dbutil.GetTableInfo(ctx, t.TargetTable.Conn, t.TargetTable.Schema, t.TargetTable.Table)
 	if err != nil {
 		return errors.Trace(err)
 	}
-	t.TargetTable.info = ignoreColumns(tableInfo, t.IgnoreColumns)
+	t.TargetTable.info, err = withKeyColumns(ignoreColumns(tableInfo, t.IgnoreColumns), t.KeyColumns)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	t.columnTolerances, err = getColumnTolerances(t.TargetTable.info, t.ColumnTolerances)
+	if err != nil {
+		return errors.Annotatef(err, "table %s", dbutil.TableName(t.TargetTable.Schema, t.TargetTable.Table))
+	}
+	// the columns with tolerance can't be compared by checksum, they can only be compared by rows
+	if len(t.columnTolerances) != 0 && t.UseChecksum && t.OnlyUseChecksum {
+		return errors.NotSupportedf("only use checksum to check table %s with column tolerances", dbutil.TableName(t.TargetTable.Schema, t.TargetTable.Table))
+	}
+
+	// the renamed columns are ignored in source by the origin name
+	sourceIgnoreColumns := t.IgnoreColumns
+	for sourceName, targetName := range t.RenamedColumns {
+		for _, column := range t.IgnoreColumns {
+			if strings.EqualFold(column, targetName) {
+				sourceIgnoreColumns = append(sourceIgnoreColumns, sourceName)
+				break
+			}
+		}
+	}
 
 	for _, sourceTable := range t.SourceTables {
+		// the query has no table info, its columns should be same as the target table
+		if len(sourceTable.Query) != 0 {
+			if err = checkQueryColumns(ctx, sourceTable, t.TargetTable.info, t.IgnoreColumns); err != nil {
+				return errors.Trace(err)
+			}
+			sourceTable.info = t.TargetTable.info
+			continue
+		}
+
 		tableInfo, err := dbutil.GetTableInfo(ctx, sourceTable.Conn, sourceTable.Schema, sourceTable.Table)
 		if err != nil {
 			return errors.Trace(err)
 		}
-		sourceTable.info = ignoreColumns(tableInfo, t.IgnoreColumns)
+		sourceTable.info = ignoreColumns(tableInfo, sourceIgnoreColumns)
+		// the source table is ordered by its own key, only order it by the key columns as the target if it has no key
+		if !hasUniqueIndex(sourceTable.info) {
+			sourceTable.info, err = withKeyColumns(sourceTable.info, t.KeyColumns)
+			if err != nil {
+				return errors.Trace(err)
+			}
+		}
+
+		sourceTable.columns, err = newSourceColumns(sourceTable.info, t.TargetTable.info, t.RenamedColumns, t.TargetOnlyColumns, t.IgnoreColumns)
+		if err != nil {
+			return errors.Annotatef(err, "source table %s", dbutil.TableName(sourceTable.Schema, sourceTable.Table))
+		}
+
+		if sourceTable.ColumnMapping != nil {
+			sourceTable.mapper, err = newColumnMapper(sourceTable, sourceIgnoreColumns)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			if sourceTable.mapper != nil && sourceTable.columns != nil {
+				sourceTable.mapper.name = sourceTable.columns.targetName(sourceTable.mapper.col.Name.O)
+			}
+		}
 	}
 
 	return nil
@@ -234,8 +642,13 @@ func (t *TableDiff) CheckTableData(ctx context.Context) (equal bool, err error)
 
 	useTiDB := false
 	if t.TiDBStatsSource != nil {
-		table = t.TiDBStatsSource
-		useTiDB = true
+		// the chunks are split by the value in target, the value in source table is different if it's mapped
+		if t.TiDBStatsSource.mapper != nil || t.TiDBStatsSource.columns != nil || len(t.TiDBStatsSource.Query) != 0 {
+			log.Warn("the column in source table is mapped, will not use its stats to split chunks", zap.String("table", dbutil.TableName(t.TiDBStatsSource.Schema, t.TiDBStatsSource.Table)))
+		} else {
+			table = t.TiDBStatsSource
+			useTiDB = true
+		}
 	}
 
 	fromCheckpoint := true
@@ -248,7 +661,7 @@ func (t *TableDiff) CheckTableData(ctx context.Context) (equal bool, err error)
 		log.Info("don't have checkpoint info, or the last check success, or config changed, will split chunks")
 
 		fromCheckpoint = false
-		chunks, err = SplitChunks(ctx, table, t.Fields, t.Range, t.ChunkSize, t.Collation, useTiDB, t.CpDB)
+		chunks, err = SplitChunks(ctx, table, t.Fields, t.Range, t.ChunkSize, t.Collation, useTiDB, t.CheckpointStore)
 		if err != nil {
 			return false, errors.Trace(err)
 		}
@@ -260,6 +673,7 @@ func (t *TableDiff) CheckTableData(ctx context.Context) (equal bool, err error)
 	}
 
 	t.summaryInfo = newTableSummaryInfo(int64(len(chunks)))
+	t.Progress.addChunks(int64(len(chunks)))
 
 	checkResultCh := make(chan bool, t.CheckThreadCount)
 	defer close(checkResultCh)
@@ -325,20 +739,20 @@ func (t *TableDiff) LoadCheckpoint(ctx context.Context) ([]*ChunkRange, error) {
 		return nil, errors.Trace(err)
 	}
 
-	err = createCheckpointTable(ctx1, t.CpDB)
+	err = t.CheckpointStore.Init(ctx1)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
 
 	if t.UseCheckpoint {
-		useCheckpoint, err := loadFromCheckPoint(ctx1, t.CpDB, t.TargetTable.Schema, t.TargetTable.Table, t.configHash)
+		useCheckpoint, err := t.CheckpointStore.UseCheckpoint(ctx1, t.TargetTable.Schema, t.TargetTable.Table, t.configHash)
 		if err != nil {
 			return nil, errors.Trace(err)
 		}
 
 		if useCheckpoint {
 			log.Info("use checkpoint to load chunks")
-			chunks, err := loadChunks(ctx1, t.CpDB, t.TargetTable.InstanceID, t.TargetTable.Schema, t.TargetTable.Table)
+			chunks, err := t.CheckpointStore.LoadChunks(ctx1, t.TargetTable.InstanceID, t.TargetTable.Schema, t.TargetTable.Table)
 			if err != nil {
 				log.Error("load chunks info", zap.Error(err))
 				return nil, errors.Trace(err)
@@ -350,12 +764,12 @@ func (t *TableDiff) LoadCheckpoint(ctx context.Context) ([]*ChunkRange, error) {
 	}
 
 	// clean old checkpoint infomation, and initial table summary
-	err = cleanCheckpoint(ctx1, t.CpDB, t.TargetTable.Schema, t.TargetTable.Table)
+	err = t.CheckpointStore.Clean(ctx1, t.TargetTable.Schema, t.TargetTable.Table)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
 
-	err = initTableSummary(ctx1, t.CpDB, t.TargetTable.Schema, t.TargetTable.Table, t.configHash)
+	err = t.CheckpointStore.InitTableSummary(ctx1, t.TargetTable.Schema, t.TargetTable.Table, t.configHash)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -364,6 +778,82 @@ func (t *TableDiff) LoadCheckpoint(ctx context.Context) ([]*ChunkRange, error) {
 	return nil, nil
 }
 
+// CheckpointInspection describes how the check of a table will continue from the checkpoint.
+type CheckpointInspection struct {
+	// Resume is true if the check will continue from the checkpoint
+	Resume bool `json:"resume"`
+	// Reason is why the table will be checked from the beginning, empty if Resume is true
+	Reason string `json:"reason,omitempty"`
+
+	ChunkNum int `json:"chunk-num"`
+	// chunks checked finished in the checkpoint, they will not be checked again
+	SkipChunkNum int `json:"skip-chunk-num"`
+}
+
+// InspectCheckpoint returns how the check will continue from the checkpoint without checking the data,
+// it doesn't write the checkpoint.
+func (t *TableDiff) InspectCheckpoint(ctx context.Context) (*CheckpointInspection, error) {
+	t.adjustConfig()
+	if !t.UseCheckpoint {
+		return &CheckpointInspection{Reason: "checkpoint is disabled"}, nil
+	}
+	if len(t.UpdateTimeColumn) != 0 {
+		return &CheckpointInspection{Reason: "the range is changed by update time column in every check"}, nil
+	}
+
+	ctx1, cancel1 := context.WithTimeout(ctx, 5*dbutil.DefaultTimeout)
+	defer cancel1()
+
+	err := t.setConfigHash()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	err = t.CheckpointStore.Init(ctx1)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	summary, err := t.CheckpointStore.GetTableSummary(ctx1, t.TargetTable.Schema, t.TargetTable.Table)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return &CheckpointInspection{Reason: "no checkpoint"}, nil
+		}
+		return nil, errors.Trace(err)
+	}
+
+	useCheckpoint, err := t.CheckpointStore.UseCheckpoint(ctx1, t.TargetTable.Schema, t.TargetTable.Table, t.configHash)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	if !useCheckpoint {
+		switch summary.State {
+		case successState:
+			return &CheckpointInspection{Reason: "checked finished last time"}, nil
+		case notCheckedState:
+			return &CheckpointInspection{Reason: "not checked last time"}, nil
+		default:
+			return &CheckpointInspection{Reason: "config is changed"}, nil
+		}
+	}
+
+	chunks, err := t.CheckpointStore.LoadChunks(ctx1, t.TargetTable.InstanceID, t.TargetTable.Schema, t.TargetTable.Table)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	inspection := &CheckpointInspection{Resume: true, ChunkNum: len(chunks)}
+	for _, chunk := range chunks {
+		// same as checkChunksDataEqual, only skip the success, ignored and repaired chunks
+		if chunk.State == successState || chunk.State == ignoreState || chunk.State == repairedState {
+			inspection.SkipChunkNum++
+		}
+	}
+
+	return inspection, nil
+}
+
 func (t *TableDiff) checkChunksDataEqual(ctx context.Context, filterByRand bool, chunks chan *ChunkRange, resultCh chan bool) {
 	var err error
 	for {
@@ -373,10 +863,16 @@ func (t *TableDiff) checkChunksDataEqual(ctx context.Context, filterByRand bool,
 				return
 			}
 			eq := false
-			if chunk.State == successState || chunk.State == ignoreState {
-				eq = true
+			if chunk.State == successState || chunk.State == ignoreState || chunk.State == repairedState {
+				// already checked before, load from checkpoint. the repaired chunk's data was not equal
+				eq = chunk.State != repairedState
+				t.Progress.doneChunk(0)
 			} else {
+				if err = t.acquireWorker(ctx); err != nil {
+					return
+				}
 				eq, err = t.checkChunkDataEqual(ctx, filterByRand, chunk)
+				t.releaseWorker()
 				if err != nil {
 					log.Error("check chunk data equal failed", zap.String("chunk", chunk.String()), zap.Error(err))
 					eq = false
@@ -411,16 +907,23 @@ func (t *TableDiff) checkChunkDataEqual(ctx context.Context, filterByRand bool,
 		ctx1, cancel1 := context.WithTimeout(ctx, dbutil.DefaultTimeout)
 		defer cancel1()
 
-		err1 := saveChunk(ctx1, t.CpDB, chunk.ID, t.TargetTable.InstanceID, t.TargetTable.Schema, t.TargetTable.Table, "", chunk)
+		err1 := t.CheckpointStore.SaveChunk(ctx1, t.TargetTable.InstanceID, t.TargetTable.Schema, t.TargetTable.Table, chunk)
 		if err1 != nil {
 			log.Warn("update chunk info", zap.Error(err1))
 		}
 	}
 
+	// the num of rows checked in the chunk, and whether the chunk's data is equal after applied the fix sqls
+	var (
+		rowNum   int64
+		repaired bool
+	)
 	defer func() {
 		if chunk.State == ignoreState {
 			t.summaryInfo.addIgnoreNum()
+			t.Progress.doneChunk(0)
 		} else {
+			t.Progress.doneChunk(rowNum)
 			if err != nil {
 				chunk.State = errorState
 				t.summaryInfo.addFailedNum()
@@ -428,12 +931,16 @@ func (t *TableDiff) checkChunkDataEqual(ctx context.Context, filterByRand bool,
 				if equal {
 					chunk.State = successState
 					t.summaryInfo.addSuccessNum()
+				} else if repaired {
+					chunk.State = repairedState
+					t.summaryInfo.addRepairedNum()
 				} else {
 					chunk.State = failedState
 					t.summaryInfo.addFailedNum()
 				}
 			}
 		}
+		chunkCounter.WithLabelValues(chunk.State).Inc()
 		update()
 	}()
 
@@ -446,75 +953,252 @@ func (t *TableDiff) checkChunkDataEqual(ctx context.Context, filterByRand bool,
 		}
 	}
 
+	if err = t.waitChunk(ctx); err != nil {
+		return false, errors.Trace(err)
+	}
+
 	chunk.State = checkingState
 	update()
 
-	if t.UseChecksum {
+	equal, rowNum, err = t.compareChunk(ctx, chunk)
+	if err == nil {
+		err = t.waitRows(ctx, rowNum)
+	}
+	if t.fixSQLs == nil || equal {
+		return equal, errors.Trace(err)
+	}
+	if err != nil {
+		// the fix sqls are not complete, so don't apply them
+		t.fixSQLs.take(chunk)
+		return false, errors.Trace(err)
+	}
+
+	// the repaired chunk is not equal, it's counted as repaired instead of success
+	repaired, err = t.repairChunk(ctx, chunk)
+	return false, errors.Trace(err)
+}
+
+// waitChunk pauses checking the chunk until all the instances are not overloaded.
+func (t *TableDiff) waitChunk(ctx context.Context) error {
+	for _, table := range append([]*TableInstance{t.TargetTable}, t.SourceTables...) {
+		if err := table.Throttler.WaitChunk(ctx); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	return nil
+}
+
+// waitRows takes the rows read from all the instances after checked a chunk, the source tables have the same
+// num of rows as the target in total if the data is equal, so the num of target's rows is taken from every instance.
+func (t *TableDiff) waitRows(ctx context.Context, rowNum int64) error {
+	for _, table := range append([]*TableInstance{t.TargetTable}, t.SourceTables...) {
+		if err := table.Throttler.WaitRows(ctx, rowNum); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	return nil
+}
+
+// compareChunk compares the chunk's data in source and target by the configured way, like row count, checksum and rows.
+// returns the num of rows in target's chunk, it's got by the first way which reads all the rows in the chunk.
+func (t *TableDiff) compareChunk(ctx context.Context, chunk *ChunkRange) (equal bool, rowNum int64, err error) {
+	countEqual := true
+	if t.UseRowCount || t.OnlyUseRowCount {
+		countEqual, rowNum, err = t.compareRowCount(ctx, chunk)
+		if err != nil {
+			return false, 0, errors.Trace(err)
+		}
+		if t.OnlyUseRowCount {
+			return countEqual, rowNum, nil
+		}
+	}
+
+	// the data must be different if row count is not equal, don't need to compare checksum.
+	// the columns with tolerance can't be compared by checksum, so the rows are always compared
+	if t.UseChecksum && countEqual && len(t.columnTolerances) == 0 {
 		// first check the checksum is equal or not
-		equal, err = t.compareChecksum(ctx, chunk)
+		var checksumRowNum int64
+		equal, checksumRowNum, err = t.compareChecksum(ctx, chunk)
 		if err != nil {
-			return false, errors.Trace(err)
+			return false, 0, errors.Trace(err)
+		}
+		if !t.UseRowCount {
+			rowNum = checksumRowNum
 		}
 		if equal {
-			return true, nil
+			return true, rowNum, nil
 		}
 	}
 
 	if t.UseChecksum && t.OnlyUseChecksum {
-		return false, nil
+		return false, rowNum, nil
 	}
 
 	// if checksum is not equal or don't need compare checksum, compare the data
-	log.Info("select data and then check data", zap.String("table", dbutil.TableName(t.TargetTable.Schema, t.TargetTable.Table)), zap.String("where", dbutil.ReplacePlaceholder(chunk.Where, chunk.Args)))
+	ranges := []*ChunkRange{chunk}
+	// the rows only different in the columns with tolerance can't be found by bisect, so compare the whole chunk
+	if t.UseChecksum && t.UseBisect && len(t.columnTolerances) == 0 {
+		ranges, err = t.getMismatchRanges(ctx, chunk)
+		if err != nil {
+			return false, 0, errors.Trace(err)
+		}
+	}
 
-	equal, err = t.compareRows(ctx, chunk)
-	if err != nil {
-		return false, errors.Trace(err)
+	equal = true
+	for _, r := range ranges {
+		log.Info("select data and then check data", zap.String("table", dbutil.TableName(t.TargetTable.Schema, t.TargetTable.Table)), zap.String("where", dbutil.ReplacePlaceholder(r.Where, r.Args)))
+
+		rangeEqual, rangeRowNum, err := t.compareRows(ctx, chunk, r)
+		if err != nil {
+			return false, 0, errors.Trace(err)
+		}
+		equal = equal && rangeEqual
+		// the rows are not counted by row count or checksum, the only range is the whole chunk
+		if !t.UseRowCount && !t.UseChecksum {
+			rowNum += rangeRowNum
+		}
 	}
 
-	return equal, nil
+	return equal, rowNum, nil
+}
+
+// compareRowCount checks the chunk's row count in source and target is equal or not, and returns the row count in target.
+func (t *TableDiff) compareRowCount(ctx context.Context, chunk *ChunkRange) (bool, int64, error) {
+	ctx1, cancel1 := context.WithCancel(ctx)
+	defer cancel1()
+
+	tables := append(make([]*TableInstance, 0, len(t.SourceTables)+1), t.SourceTables...)
+	tables = append(tables, t.TargetTable)
+
+	wheres := make([]string, 0, len(tables))
+	args := make([][]interface{}, 0, len(tables))
+	for _, sourceTable := range t.SourceTables {
+		where, whereArgs, err := t.sourceWhere(sourceTable, chunk)
+		if err != nil {
+			return false, 0, errors.Trace(err)
+		}
+		wheres = append(wheres, where)
+		args = append(args, whereArgs)
+	}
+	wheres = append(wheres, chunk.Where)
+	args = append(args, utils.StringsToInterfaces(chunk.Args))
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+		counts   = make([]int64, len(tables))
+	)
+	for i, table := range tables {
+		wg.Add(1)
+		go func(i int, table *TableInstance) {
+			defer wg.Done()
+			err := table.Throttler.WaitQuery(ctx1)
+			var count int64
+			if err == nil {
+				count, err = dbutil.GetRowCountFrom(ctx1, table.Conn, table.from(), wheres[i], args[i])
+			}
+			if err != nil {
+				// only need to return the first error, others are context cancel error
+				errOnce.Do(func() {
+					firstErr = err
+					cancel1()
+				})
+				return
+			}
+			counts[i] = count
+		}(i, table)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return false, 0, errors.Trace(firstErr)
+	}
+
+	var sourceCount int64
+	for _, count := range counts[:len(t.SourceTables)] {
+		sourceCount += count
+	}
+	targetCount := counts[len(t.SourceTables)]
+	// the row count is counted when the chunk is checked first time
+	if chunk.State != repairingState {
+		t.summaryInfo.addRowCount(sourceCount, targetCount)
+	}
+
+	if sourceCount == targetCount {
+		log.Info("row count is equal", zap.String("table", dbutil.TableName(t.TargetTable.Schema, t.TargetTable.Table)), zap.String("where", dbutil.ReplacePlaceholder(chunk.Where, chunk.Args)), zap.Int64("row count", sourceCount))
+		return true, targetCount, nil
+	}
+
+	log.Warn("row count is not equal", zap.String("table", dbutil.TableName(t.TargetTable.Schema, t.TargetTable.Table)), zap.String("where", dbutil.ReplacePlaceholder(chunk.Where, chunk.Args)), zap.Int64("source row count", sourceCount), zap.Int64("target row count", targetCount))
+	return false, targetCount, nil
 }
 
 // checksumInfo save some information about checksum
 type checksumInfo struct {
 	checksum int64
-	err      error
-	cost     time.Duration
-	tp       string
+	// only valid when use md5 checksum strategy
+	rowsChecksum *dbutil.RowsChecksum
+	// the num of rows, only valid when use crc32 checksum strategy
+	rowNum int64
+	err    error
+	cost   time.Duration
+	tp     string
 }
 
-// check the checksum is equal or not
-func (t *TableDiff) compareChecksum(ctx context.Context, chunk *ChunkRange) (bool, error) {
+// check the checksum is equal or not, and returns the row count in target.
+func (t *TableDiff) compareChecksum(ctx context.Context, chunk *ChunkRange) (bool, int64, error) {
 	ctx1, cancel1 := context.WithCancel(ctx)
 	defer cancel1()
 
 	var (
 		getSourceChecksumDuration, getTargetChecksumDuration time.Duration
 		sourceChecksum, targetChecksum                       int64
+		targetRowNum                                         int64
+		sourceRowsChecksum, targetRowsChecksum               = &dbutil.RowsChecksum{}, &dbutil.RowsChecksum{}
 		checksumInfoCh                                       = make(chan checksumInfo)
 		firstErr                                             error
 	)
 	defer close(checksumInfoCh)
 
-	getChecksum := func(db *sql.DB, schema, table, limitRange string, tbInfo *model.TableInfo, args []interface{}, tp string) {
+	// the same rows offset each other in BIT_XOR, so the table without unique key uses the sum of MD5
+	useMD5 := t.ChecksumStrategy == MD5ChecksumStrategy || t.isMultiset()
+	getChecksum := func(table *TableInstance, limitRange string, tbInfo *model.TableInfo, columnExprs map[string]string, args []interface{}, tp string) {
+		info := checksumInfo{tp: tp}
+		// the time waited by the throttler is not the cost of the query
+		info.err = table.Throttler.WaitQuery(ctx1)
 		beginTime := time.Now()
-		checksum, err := dbutil.GetCRC32Checksum(ctx1, db, schema, table, tbInfo, limitRange, args)
-		cost := time.Since(beginTime)
-
-		checksumInfoCh <- checksumInfo{
-			checksum: checksum,
-			err:      err,
-			cost:     cost,
-			tp:       tp,
+		if info.err == nil {
+			if useMD5 {
+				info.rowsChecksum, info.err = dbutil.GetMD5ChecksumFrom(ctx1, table.Conn, table.from(), tbInfo, columnExprs, limitRange, args)
+			} else {
+				info.checksum, info.rowNum, info.err = dbutil.GetCRC32ChecksumFrom(ctx1, table.Conn, table.from(), tbInfo, columnExprs, limitRange, args)
+			}
 		}
+		info.cost = time.Since(beginTime)
+		checksumDurationHistogram.WithLabelValues(tp).Observe(info.cost.Seconds())
+
+		checksumInfoCh <- info
 	}
 
-	args := utils.StringsToInterfaces(chunk.Args)
+	sourceWheres := make([]string, 0, len(t.SourceTables))
+	sourceArgs := make([][]interface{}, 0, len(t.SourceTables))
 	for _, sourceTable := range t.SourceTables {
-		go getChecksum(sourceTable.Conn, sourceTable.Schema, sourceTable.Table, chunk.Where, t.TargetTable.info, args, "source")
+		where, args, err := t.sourceWhere(sourceTable, chunk)
+		if err != nil {
+			return false, 0, errors.Trace(err)
+		}
+		sourceWheres = append(sourceWheres, where)
+		sourceArgs = append(sourceArgs, args)
+	}
+
+	for i, sourceTable := range t.SourceTables {
+		go getChecksum(sourceTable, sourceWheres[i], t.TargetTable.info, sourceTable.checksumExprs(), sourceArgs[i], "source")
 	}
 
-	go getChecksum(t.TargetTable.Conn, t.TargetTable.Schema, t.TargetTable.Table, chunk.Where, t.TargetTable.info, args, "target")
+	go getChecksum(t.TargetTable, chunk.Where, t.TargetTable.info, nil, utils.StringsToInterfaces(chunk.Args), "target")
 
 	for i := 0; i < len(t.SourceTables)+1; i++ {
 		checksumInfo := <-checksumInfoCh
@@ -529,47 +1213,91 @@ func (t *TableDiff) compareChecksum(ctx context.Context, chunk *ChunkRange) (boo
 		}
 
 		if checksumInfo.tp == "source" {
-			sourceChecksum ^= checksumInfo.checksum
+			// the checksum of sharding tables can be merged, because they are order-independent
+			if useMD5 {
+				sourceRowsChecksum.Add(checksumInfo.rowsChecksum)
+			} else {
+				sourceChecksum ^= checksumInfo.checksum
+			}
 			if checksumInfo.cost > getSourceChecksumDuration {
 				getSourceChecksumDuration = checksumInfo.cost
 			}
 		} else {
-			targetChecksum = checksumInfo.checksum
+			if useMD5 {
+				targetRowsChecksum = checksumInfo.rowsChecksum
+				targetRowNum = checksumInfo.rowsChecksum.Count
+			} else {
+				targetChecksum, targetRowNum = checksumInfo.checksum, checksumInfo.rowNum
+			}
 			getTargetChecksumDuration = checksumInfo.cost
 		}
 	}
 
 	if firstErr != nil {
-		return false, errors.Trace(firstErr)
+		return false, 0, errors.Trace(firstErr)
 	}
 
-	if sourceChecksum == targetChecksum {
-		log.Info("checksum is equal", zap.String("table", dbutil.TableName(t.TargetTable.Schema, t.TargetTable.Table)), zap.String("where", dbutil.ReplacePlaceholder(chunk.Where, chunk.Args)), zap.Int64("checksum", sourceChecksum), zap.Duration("get source checksum cost", getSourceChecksumDuration), zap.Duration("get target checksum cost", getTargetChecksumDuration))
-		return true, nil
+	var (
+		equal                                bool
+		sourceChecksumStr, targetChecksumStr string
+	)
+	if useMD5 {
+		equal = sourceRowsChecksum.Equal(targetRowsChecksum)
+		sourceChecksumStr, targetChecksumStr = sourceRowsChecksum.String(), targetRowsChecksum.String()
+	} else {
+		equal = sourceChecksum == targetChecksum
+		sourceChecksumStr, targetChecksumStr = strconv.FormatInt(sourceChecksum, 10), strconv.FormatInt(targetChecksum, 10)
+	}
+
+	if equal {
+		log.Info("checksum is equal", zap.String("table", dbutil.TableName(t.TargetTable.Schema, t.TargetTable.Table)), zap.String("where", dbutil.ReplacePlaceholder(chunk.Where, chunk.Args)), zap.String("checksum", sourceChecksumStr), zap.Duration("get source checksum cost", getSourceChecksumDuration), zap.Duration("get target checksum cost", getTargetChecksumDuration))
+		return true, targetRowNum, nil
 	}
 
-	log.Warn("checksum is not equal", zap.String("table", dbutil.TableName(t.TargetTable.Schema, t.TargetTable.Table)), zap.String("where", dbutil.ReplacePlaceholder(chunk.Where, chunk.Args)), zap.Int64("source checksum", sourceChecksum), zap.Int64("target checksum", targetChecksum), zap.Duration("get source checksum cost", getSourceChecksumDuration), zap.Duration("get target checksum cost", getTargetChecksumDuration))
+	log.Warn("checksum is not equal", zap.String("table", dbutil.TableName(t.TargetTable.Schema, t.TargetTable.Table)), zap.String("where", dbutil.ReplacePlaceholder(chunk.Where, chunk.Args)), zap.String("source checksum", sourceChecksumStr), zap.String("target checksum", targetChecksumStr), zap.Duration("get source checksum cost", getSourceChecksumDuration), zap.Duration("get target checksum cost", getTargetChecksumDuration))
 
-	return false, nil
+	return false, targetRowNum, nil
 }
 
-func (t *TableDiff) compareRows(ctx context.Context, chunk *ChunkRange) (bool, error) {
+// compareRows compares the rows in the range of the chunk, the range can be the whole chunk or a sub range of it.
+// returns the num of rows in target's range.
+func (t *TableDiff) compareRows(ctx context.Context, chunk *ChunkRange, r *ChunkRange) (bool, int64, error) {
+	if t.isMultiset() {
+		return t.compareMultisetRows(ctx, chunk, r)
+	}
+
 	beginTime := time.Now()
 
 	sourceRows := make(map[int]*sql.Rows)
 	sourceHaveData := make(map[int]bool)
-	args := utils.StringsToInterfaces(chunk.Args)
+	where, whereArgs := r.Where, r.Args
+	args := utils.StringsToInterfaces(whereArgs)
 
-	targetRows, orderKeyCols, err := getChunkRows(ctx, t.TargetTable.Conn, t.TargetTable.Schema, t.TargetTable.Table, t.TargetTable.info, chunk.Where, args, t.Collation)
+	if err := t.TargetTable.Throttler.WaitQuery(ctx); err != nil {
+		return false, 0, errors.Trace(err)
+	}
+	targetRows, orderKeyCols, err := getChunkRows(ctx, t.TargetTable.Conn, t.TargetTable.from(), t.TargetTable.info, nil, where, args, t.Collation)
 	if err != nil {
-		return false, errors.Trace(err)
+		return false, 0, errors.Trace(err)
 	}
 	defer targetRows.Close()
 
 	for i, sourceTable := range t.SourceTables {
-		rows, _, err := getChunkRows(ctx, sourceTable.Conn, sourceTable.Schema, sourceTable.Table, sourceTable.info, chunk.Where, args, t.Collation)
+		sourceWhere, sourceArgs, err := t.sourceWhere(sourceTable, r)
 		if err != nil {
-			return false, errors.Trace(err)
+			return false, 0, errors.Trace(err)
+		}
+		// select the renamed and target only columns by the name in target
+		tableInfo, columnExprs := sourceTable.info, map[string]string(nil)
+		if sourceTable.columns != nil {
+			tableInfo, columnExprs = t.TargetTable.info, sourceTable.columns.exprs
+		}
+		if err = sourceTable.Throttler.WaitQuery(ctx); err != nil {
+			return false, 0, errors.Trace(err)
+		}
+		rows, _, err := getChunkRows(ctx, sourceTable.Conn, sourceTable.from(), tableInfo, columnExprs, sourceWhere, sourceArgs, t.Collation)
+		if err != nil {
+			return false, 0, errors.Trace(err)
 		}
 		defer rows.Close()
 
@@ -591,6 +1319,16 @@ func (t *TableDiff) compareRows(ctx context.Context, chunk *ChunkRange) (bool, e
 		return
 	}
 
+	var targetRowNum int64
+	getTargetRow := func() (map[string]*dbutil.ColumnData, error) {
+		rowData, err := getRowData(targetRows)
+		if rowData != nil {
+			targetRowNum++
+			comparedRowCounter.WithLabelValues("target").Inc()
+		}
+		return rowData, err
+	}
+
 	// getSourceRow gets one row from all the sources, it should be the smallest.
 	// first get rows from every source, and then push them to the heap, and then pop to get the smallest one
 	getSourceRow := func() (map[string]*dbutil.ColumnData, error) {
@@ -605,6 +1343,11 @@ func (t *TableDiff) compareRows(ctx context.Context, chunk *ChunkRange) (bool, e
 				if err != nil {
 					return nil, err
 				}
+				if rowData != nil && t.SourceTables[i].mapper != nil {
+					if err = t.SourceTables[i].mapper.mapRow(rowData); err != nil {
+						return nil, err
+					}
+				}
 
 				if rowData != nil {
 					sourceHaveData[i] = true
@@ -636,6 +1379,7 @@ func (t *TableDiff) compareRows(ctx context.Context, chunk *ChunkRange) (bool, e
 
 		rowData := heap.Pop(sourceRowDatas).(RowData)
 		sourceHaveData[rowData.Source] = false
+		comparedRowCounter.WithLabelValues("source").Inc()
 
 		return rowData.Data, nil
 	}
@@ -647,14 +1391,14 @@ func (t *TableDiff) compareRows(ctx context.Context, chunk *ChunkRange) (bool, e
 		if lastSourceData == nil {
 			lastSourceData, err = getSourceRow()
 			if err != nil {
-				return false, err
+				return false, 0, err
 			}
 		}
 
 		if lastTargetData == nil {
-			lastTargetData, err = getRowData(targetRows)
+			lastTargetData, err = getTargetRow()
 			if err != nil {
-				return false, err
+				return false, 0, err
 			}
 		}
 
@@ -663,17 +1407,16 @@ func (t *TableDiff) compareRows(ctx context.Context, chunk *ChunkRange) (bool, e
 			for lastTargetData != nil {
 				sql := generateDML("delete", lastTargetData, t.TargetTable.info, t.TargetTable.Schema)
 				log.Info("[delete]", zap.String("sql", sql))
+				t.writeRowDiff(DiffTypeDelete, chunk, nil, lastTargetData, orderKeyCols, nil)
 
-				select {
-				case t.sqlCh <- sql:
-				case <-ctx.Done():
-					return false, nil
+				if !t.sendFixSQL(ctx, chunk, sql, t.generateRevertDML("replace", lastTargetData, nil, nil)) {
+					return false, 0, nil
 				}
 				equal = false
 
-				lastTargetData, err = getRowData(targetRows)
+				lastTargetData, err = getTargetRow()
 				if err != nil {
-					return false, err
+					return false, 0, err
 				}
 			}
 			break
@@ -684,25 +1427,24 @@ func (t *TableDiff) compareRows(ctx context.Context, chunk *ChunkRange) (bool, e
 			for lastSourceData != nil {
 				sql := generateDML("replace", lastSourceData, t.TargetTable.info, t.TargetTable.Schema)
 				log.Info("[insert]", zap.String("sql", sql))
+				t.writeRowDiff(DiffTypeInsert, chunk, lastSourceData, nil, orderKeyCols, nil)
 
-				select {
-				case t.sqlCh <- sql:
-				case <-ctx.Done():
-					return false, nil
+				if !t.sendFixSQL(ctx, chunk, sql, t.generateRevertDML("delete", lastSourceData, nil, nil)) {
+					return false, 0, nil
 				}
 				equal = false
 
 				lastSourceData, err = getSourceRow()
 				if err != nil {
-					return false, err
+					return false, 0, err
 				}
 			}
 			break
 		}
 
-		eq, cmp, err := compareData(lastSourceData, lastTargetData, orderKeyCols)
+		eq, cmp, diffColumns, err := compareData(lastSourceData, lastTargetData, orderKeyCols, t.columnTolerances)
 		if err != nil {
-			return false, errors.Trace(err)
+			return false, 0, errors.Trace(err)
 		}
 		if eq {
 			lastSourceData = nil
@@ -712,40 +1454,121 @@ func (t *TableDiff) compareRows(ctx context.Context, chunk *ChunkRange) (bool, e
 
 		equal = false
 		sql := ""
+		revertSQL := ""
 
 		switch cmp {
 		case 1:
 			// delete
 			sql = generateDML("delete", lastTargetData, t.TargetTable.info, t.TargetTable.Schema)
 			log.Info("[delete]", zap.String("sql", sql))
+			t.writeRowDiff(DiffTypeDelete, chunk, nil, lastTargetData, orderKeyCols, nil)
+			revertSQL = t.generateRevertDML("replace", lastTargetData, nil, nil)
 			lastTargetData = nil
 		case -1:
 			// insert
 			sql = generateDML("replace", lastSourceData, t.TargetTable.info, t.TargetTable.Schema)
 			log.Info("[insert]", zap.String("sql", sql))
+			t.writeRowDiff(DiffTypeInsert, chunk, lastSourceData, nil, orderKeyCols, nil)
+			revertSQL = t.generateRevertDML("delete", lastSourceData, nil, nil)
 			lastSourceData = nil
 		case 0:
 			// update
-			sql = generateDML("replace", lastSourceData, t.TargetTable.info, t.TargetTable.Schema)
-			log.Info("[update]", zap.String("sql", sql))
+			keyCols := rowKeyColumns(t.TargetTable.info, lastTargetData)
+			if keyCols != nil {
+				sql = generateUpdateDML(lastSourceData, diffColumns, keyCols, t.TargetTable.info, t.TargetTable.Schema)
+				revertSQL = t.generateRevertDML("update", lastTargetData, diffColumns, keyCols)
+			} else {
+				// the row can't be located by primary key or unique key, delete it by all the columns and then insert the source row
+				deleteSQL := generateDML("delete", lastTargetData, t.TargetTable.info, t.TargetTable.Schema)
+				if !t.sendFixSQL(ctx, chunk, deleteSQL, t.generateRevertDML("replace", lastTargetData, nil, nil)) {
+					return false, 0, nil
+				}
+				sql = generateDML("replace", lastSourceData, t.TargetTable.info, t.TargetTable.Schema)
+				revertSQL = t.generateRevertDML("delete", lastSourceData, nil, nil)
+			}
+			log.Info("[update]", zap.String("sql", sql), zap.Strings("columns", diffColumns))
+			t.writeRowDiff(DiffTypeUpdate, chunk, lastSourceData, lastTargetData, orderKeyCols, diffColumns)
 			lastSourceData = nil
 			lastTargetData = nil
 		}
 
-		select {
-		case t.sqlCh <- sql:
-		case <-ctx.Done():
-			return false, nil
+		if !t.sendFixSQL(ctx, chunk, sql, revertSQL) {
+			return false, 0, nil
 		}
 	}
 
 	if equal {
-		log.Info("rows is equal", zap.String("table", dbutil.TableName(t.TargetTable.Schema, t.TargetTable.Table)), zap.String("where", dbutil.ReplacePlaceholder(chunk.Where, chunk.Args)), zap.Duration("cost", time.Since(beginTime)))
+		log.Info("rows is equal", zap.String("table", dbutil.TableName(t.TargetTable.Schema, t.TargetTable.Table)), zap.String("where", dbutil.ReplacePlaceholder(where, whereArgs)), zap.Duration("cost", time.Since(beginTime)))
 	} else {
-		log.Warn("rows is not equal", zap.String("table", dbutil.TableName(t.TargetTable.Schema, t.TargetTable.Table)), zap.String("where", dbutil.ReplacePlaceholder(chunk.Where, chunk.Args)), zap.Duration("cost", time.Since(beginTime)))
+		log.Warn("rows is not equal", zap.String("table", dbutil.TableName(t.TargetTable.Schema, t.TargetTable.Table)), zap.String("where", dbutil.ReplacePlaceholder(where, whereArgs)), zap.Duration("cost", time.Since(beginTime)))
 	}
 
-	return equal, nil
+	return equal, targetRowNum, nil
+}
+
+// fixSQL saves the sql used to fix a different row, and the sql used to revert the fix.
+type fixSQL struct {
+	sql       string
+	revertSQL string
+}
+
+// sendFixSQL sends the fix sql and its revert sql to the writer, returns false if the context is done.
+// the differences found when check the repaired chunk again are not fixed again, so ignore the fix sql.
+func (t *TableDiff) sendFixSQL(ctx context.Context, chunk *ChunkRange, sql, revertSQL string) bool {
+	if chunk.State == repairingState {
+		return true
+	}
+
+	t.collectFixSQL(chunk, sql)
+	fixSQLCounter.Inc()
+
+	select {
+	case t.sqlCh <- &fixSQL{sql: sql, revertSQL: revertSQL}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// generateRevertDML generates the sql used to revert the fix sql by the origin row in target,
+// returns empty string if don't need to save revert sqls.
+func (t *TableDiff) generateRevertDML(tp string, data map[string]*dbutil.ColumnData, diffColumns []string, keyCols []*model.ColumnInfo) string {
+	if t.WriteRevertSQL == nil {
+		return ""
+	}
+
+	if tp == "update" {
+		return generateUpdateDML(data, diffColumns, keyCols, t.TargetTable.info, t.TargetTable.Schema)
+	}
+
+	return generateDML(tp, data, t.TargetTable.info, t.TargetTable.Schema)
+}
+
+// writeRowDiff counts the different row and saves it to DiffSink, the differences found when check the repaired chunk
+// again are already counted.
+func (t *TableDiff) writeRowDiff(tp string, chunk *ChunkRange, sourceRow, targetRow map[string]*dbutil.ColumnData, keyCols []*model.ColumnInfo, diffColumns []string) {
+	if chunk.State == repairingState {
+		return
+	}
+
+	t.collectDiffRow(chunk)
+	switch tp {
+	case DiffTypeInsert:
+		t.summaryInfo.addInsertRowNum()
+	case DiffTypeDelete:
+		t.summaryInfo.addDeleteRowNum()
+	case DiffTypeUpdate:
+		t.summaryInfo.addUpdateRowNum(diffColumns)
+	}
+
+	if t.DiffSink == nil {
+		return
+	}
+
+	rowDiff := newRowDiff(tp, t.TargetTable.Schema, t.TargetTable.Table, chunk.ID, sourceRow, targetRow, t.TargetTable.info, keyCols, diffColumns)
+	if err := t.DiffSink.Write(rowDiff); err != nil {
+		log.Error("write row diff failed", zap.String("table", dbutil.TableName(t.TargetTable.Schema, t.TargetTable.Table)), zap.String("type", tp), zap.Error(err))
+	}
 }
 
 // WriteSqls write sqls to file
@@ -764,9 +1587,16 @@ func (t *TableDiff) WriteSqls(ctx context.Context, writeFixSQL func(string) erro
 					return
 				}
 
-				err := writeFixSQL(fmt.Sprintf("%s\n", dml))
+				err := writeFixSQL(fmt.Sprintf("%s\n", dml.sql))
 				if err != nil {
-					log.Error("write sql failed", zap.String("sql", dml), zap.Error(err))
+					log.Error("write sql failed", zap.String("sql", dml.sql), zap.Error(err))
+				}
+
+				if t.WriteRevertSQL != nil && dml.revertSQL != "" {
+					err = t.WriteRevertSQL(fmt.Sprintf("%s\n", dml.revertSQL))
+					if err != nil {
+						log.Error("write revert sql failed", zap.String("sql", dml.revertSQL), zap.Error(err))
+					}
 				}
 
 			case <-stopWriteCh:
@@ -791,17 +1621,8 @@ func (t *TableDiff) UpdateSummaryInfo(ctx context.Context) chan bool {
 	stopUpdateCh := make(chan bool)
 
 	go func() {
-		update := func() {
-			ctx1, cancel1 := context.WithTimeout(ctx, dbutil.DefaultTimeout)
-			defer cancel1()
-
-			err := updateTableSummary(ctx1, t.CpDB, t.TargetTable.InstanceID, t.TargetTable.Schema, t.TargetTable.Table, t.summaryInfo)
-			if err != nil {
-				log.Warn("save table summary info failed", zap.String("schema", t.TargetTable.Schema), zap.String("table", t.TargetTable.Table), zap.Error(err))
-			}
-		}
 		defer func() {
-			update()
+			t.updateSummary(ctx)
 			t.wg.Done()
 		}()
 
@@ -816,7 +1637,7 @@ func (t *TableDiff) UpdateSummaryInfo(ctx context.Context) chan bool {
 				return
 			case <-ticker.C:
 				if atomic.LoadInt32(&t.checkpointLoaded) == 1 {
-					update()
+					t.updateSummary(ctx)
 				}
 			}
 		}
@@ -825,6 +1646,27 @@ func (t *TableDiff) UpdateSummaryInfo(ctx context.Context) chan bool {
 	return stopUpdateCh
 }
 
+// updateSummary saves the table's summary in checkpoint.
+func (t *TableDiff) updateSummary(ctx context.Context) {
+	ctx1, cancel1 := context.WithTimeout(ctx, dbutil.DefaultTimeout)
+	defer cancel1()
+
+	if t.summaryInfo == nil {
+		return
+	}
+	summary := t.summaryInfo.toTableSummary()
+	if summary.ChunkNum == 0 {
+		// don't need to update summary info
+		return
+	}
+
+	log.Info("summary info", zap.String("instance_id", t.TargetTable.InstanceID), zap.String("schema", t.TargetTable.Schema), zap.String("table", t.TargetTable.Table), zap.Int64("chunk num", summary.ChunkNum), zap.Int64("success num", summary.SuccessNum), zap.Int64("failed num", summary.FailedNum), zap.Int64("ignore num", summary.IgnoreNum))
+	err := t.CheckpointStore.UpdateTableSummary(ctx1, t.TargetTable.Schema, t.TargetTable.Table, summary)
+	if err != nil {
+		log.Warn("save table summary info failed", zap.String("schema", t.TargetTable.Schema), zap.String("table", t.TargetTable.Table), zap.Error(err))
+	}
+}
+
 func generateDML(tp string, data map[string]*dbutil.ColumnData, table *model.TableInfo, schema string) (sql string) {
 	switch tp {
 	case "replace":
@@ -836,16 +1678,7 @@ func generateDML(tp string, data map[string]*dbutil.ColumnData, table *model.Tab
 			}
 
 			colNames = append(colNames, dbutil.ColumnName(col.Name.O))
-			if data[col.Name.O].IsNull {
-				values = append(values, "NULL")
-				continue
-			}
-
-			if needQuotes(col.FieldType) {
-				values = append(values, fmt.Sprintf("'%s'", strings.Replace(string(data[col.Name.O].Data), "'", "\\'", -1)))
-			} else {
-				values = append(values, string(data[col.Name.O].Data))
-			}
+			values = append(values, columnValue(col, data[col.Name.O]))
 		}
 
 		sql = fmt.Sprintf("REPLACE INTO %s(%s) VALUES (%s);", dbutil.TableName(schema, table.Name.O), strings.Join(colNames, ","), strings.Join(values, ","))
@@ -856,16 +1689,7 @@ func generateDML(tp string, data map[string]*dbutil.ColumnData, table *model.Tab
 				continue
 			}
 
-			if data[col.Name.O].IsNull {
-				kvs = append(kvs, fmt.Sprintf("%s is NULL", dbutil.ColumnName(col.Name.O)))
-				continue
-			}
-
-			if needQuotes(col.FieldType) {
-				kvs = append(kvs, fmt.Sprintf("%s = '%s'", dbutil.ColumnName(col.Name.O), strings.Replace(string(data[col.Name.O].Data), "'", "\\'", -1)))
-			} else {
-				kvs = append(kvs, fmt.Sprintf("%s = %s", dbutil.ColumnName(col.Name.O), string(data[col.Name.O].Data)))
-			}
+			kvs = append(kvs, columnCondition(col, data[col.Name.O]))
 		}
 		sql = fmt.Sprintf("DELETE FROM %s WHERE %s;", dbutil.TableName(schema, table.Name.O), strings.Join(kvs, " AND "))
 	default:
@@ -875,10 +1699,56 @@ func generateDML(tp string, data map[string]*dbutil.ColumnData, table *model.Tab
 	return
 }
 
-func compareData(map1, map2 map[string]*dbutil.ColumnData, orderKeyCols []*model.ColumnInfo) (equal bool, cmp int32, err error) {
+// generateUpdateDML generates sql to update the different columns of the row, the row is located by the key columns.
+func generateUpdateDML(data map[string]*dbutil.ColumnData, diffColumns []string, keyCols []*model.ColumnInfo, table *model.TableInfo, schema string) string {
+	diffColumnMap := utils.SliceToMap(diffColumns)
+
+	sets := make([]string, 0, len(diffColumns))
+	for _, col := range table.Columns {
+		if col.IsGenerated() {
+			continue
+		}
+
+		if _, ok := diffColumnMap[col.Name.O]; !ok {
+			continue
+		}
+		sets = append(sets, fmt.Sprintf("%s = %s", dbutil.ColumnName(col.Name.O), columnValue(col, data[col.Name.O])))
+	}
+
+	kvs := make([]string, 0, len(keyCols))
+	for _, col := range keyCols {
+		kvs = append(kvs, columnCondition(col, data[col.Name.O]))
+	}
+
+	return fmt.Sprintf("UPDATE %s SET %s WHERE %s;", dbutil.TableName(schema, table.Name.O), strings.Join(sets, ","), strings.Join(kvs, " AND "))
+}
+
+// columnValue returns the column's value used in sql.
+func columnValue(col *model.ColumnInfo, data *dbutil.ColumnData) string {
+	if data.IsNull {
+		return "NULL"
+	}
+
+	if needQuotes(col.FieldType) {
+		return fmt.Sprintf("'%s'", escapeString(string(data.Data)))
+	}
+
+	return string(data.Data)
+}
+
+// columnCondition returns the condition used in sql's where clause to match the column's value.
+func columnCondition(col *model.ColumnInfo, data *dbutil.ColumnData) string {
+	if data.IsNull {
+		return fmt.Sprintf("%s is NULL", dbutil.ColumnName(col.Name.O))
+	}
+
+	return fmt.Sprintf("%s = %s", dbutil.ColumnName(col.Name.O), columnValue(col, data))
+}
+
+// compareData compares two rows, returns the columns with different value if not equal.
+func compareData(map1, map2 map[string]*dbutil.ColumnData, orderKeyCols []*model.ColumnInfo, tolerances map[string]*columnTolerance) (equal bool, cmp int32, diffColumns []string, err error) {
 	var (
 		data1, data2 *dbutil.ColumnData
-		key          string
 		ok           bool
 	)
 
@@ -890,7 +1760,7 @@ func compareData(map1, map2 map[string]*dbutil.ColumnData, orderKeyCols []*model
 		}
 
 		if cmp == 0 {
-			log.Warn("find different row", zap.String("column", key), zap.String("row1", rowToString(map1)), zap.String("row2", rowToString(map2)))
+			log.Warn("find different row", zap.Strings("columns", diffColumns), zap.String("row1", rowToString(map1)), zap.String("row2", rowToString(map2)))
 		} else if cmp > 0 {
 			log.Warn("target had superfluous data", zap.String("row", rowToString(map2)))
 		} else {
@@ -898,20 +1768,24 @@ func compareData(map1, map2 map[string]*dbutil.ColumnData, orderKeyCols []*model
 		}
 	}()
 
-	for key, data1 = range map1 {
+	for key, data1 := range map1 {
 		if data2, ok = map2[key]; !ok {
-			return false, 0, errors.Errorf("don't have key %s", key)
+			return false, 0, nil, errors.Errorf("don't have key %s", key)
 		}
 		if (string(data1.Data) == string(data2.Data)) && (data1.IsNull == data2.IsNull) {
 			continue
 		}
-		equal = false
+		if tolerance, ok := tolerances[key]; ok && tolerance.equal(data1, data2) {
+			continue
+		}
 
-		break
+		diffColumns = append(diffColumns, key)
 	}
-	if equal {
+	if len(diffColumns) == 0 {
 		return
 	}
+	equal = false
+	sort.Strings(diffColumns)
 
 	for _, col := range orderKeyCols {
 		if data1, ok = map1[col.Name.O]; !ok {
@@ -971,15 +1845,11 @@ func compareData(map1, map2 map[string]*dbutil.ColumnData, orderKeyCols []*model
 	return
 }
 
-func getChunkRows(ctx context.Context, db *sql.DB, schema, table string, tableInfo *model.TableInfo, where string,
+// getChunkRows selects the rows ordered by the order key, the column in columnExprs is selected by the expression and named as the column.
+func getChunkRows(ctx context.Context, db *sql.DB, from string, tableInfo *model.TableInfo, columnExprs map[string]string, where string,
 	args []interface{}, collation string) (*sql.Rows, []*model.ColumnInfo, error) {
 	orderKeys, orderKeyCols := dbutil.SelectUniqueOrderKey(tableInfo)
-
-	columnNames := make([]string, 0, len(tableInfo.Columns))
-	for _, col := range tableInfo.Columns {
-		columnNames = append(columnNames, dbutil.ColumnName(col.Name.O))
-	}
-	columns := strings.Join(columnNames, ", ")
+	columns := selectColumns(tableInfo, columnExprs)
 
 	if collation != "" {
 		collation = fmt.Sprintf(" COLLATE \"%s\"", collation)
@@ -990,7 +1860,7 @@ func getChunkRows(ctx context.Context, db *sql.DB, schema, table string, tableIn
 	}
 
 	query := fmt.Sprintf("SELECT /*!40001 SQL_NO_CACHE */ %s FROM %s WHERE %s ORDER BY %s%s",
-		columns, dbutil.TableName(schema, table), where, strings.Join(orderKeys, ","), collation)
+		columns, from, where, strings.Join(orderKeys, ","), collation)
 
 	log.Debug("select data", zap.String("sql", query), zap.Reflect("args", args))
 	rows, err := db.QueryContext(ctx, query, args...)
@@ -1000,3 +1870,17 @@ func getChunkRows(ctx context.Context, db *sql.DB, schema, table string, tableIn
 
 	return rows, orderKeyCols, nil
 }
+
+// selectColumns returns the columns in select, the column in columnExprs is selected by the expression and named as the column.
+func selectColumns(tableInfo *model.TableInfo, columnExprs map[string]string) string {
+	columnNames := make([]string, 0, len(tableInfo.Columns))
+	for _, col := range tableInfo.Columns {
+		if expr, ok := columnExprs[col.Name.O]; ok {
+			columnNames = append(columnNames, fmt.Sprintf("%s AS %s", expr, dbutil.ColumnName(col.Name.O)))
+			continue
+		}
+		columnNames = append(columnNames, dbutil.ColumnName(col.Name.O))
+	}
+
+	return strings.Join(columnNames, ", ")
+}
@@ -20,6 +20,7 @@ import (
 	"testing"
 	"time"
 
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
 	_ "github.com/go-sql-driver/mysql"
 	. "github.com/pingcap/check"
 	"github.com/pingcap/failpoint"
@@ -83,6 +84,173 @@ func (*testDiffSuite) TestGenerateSQLs(c *C) {
 	deleteSQL = generateDML("delete", rowsData, tableInfo, "diff_test")
 	c.Assert(replaceSQL, Equals, "REPLACE INTO `diff_test`.`atest`(`id`,`name`,`birthday`,`update_time`,`money`) VALUES (NULL,'a\\'a','2018-01-01 00:00:00','10:10:10',11.1111);")
 	c.Assert(deleteSQL, Equals, "DELETE FROM `diff_test`.`atest` WHERE `id` is NULL AND `name` = 'a\\'a' AND `birthday` = '2018-01-01 00:00:00' AND `update_time` = '10:10:10' AND `money` = 11.1111;")
+
+	// test value with the special characters, the backslash can't escape the quote
+	rowsData["name"] = &dbutil.ColumnData{Data: []byte("a\\'; DROP TABLE t;\n\x00\""), IsNull: false}
+	replaceSQL = generateDML("replace", rowsData, tableInfo, "diff_test")
+	c.Assert(replaceSQL, Equals, "REPLACE INTO `diff_test`.`atest`(`id`,`name`,`birthday`,`update_time`,`money`) VALUES (NULL,'a\\\\\\'; DROP TABLE t;\\n\\0\\\"','2018-01-01 00:00:00','10:10:10',11.1111);")
+	rowsData["name"] = &dbutil.ColumnData{Data: []byte("a'a"), IsNull: false}
+
+	// test update the different columns
+	_, keyCols := dbutil.SelectUniqueOrderKey(tableInfo)
+	rowsData["id"] = &dbutil.ColumnData{Data: []byte("1"), IsNull: false}
+	updateSQL := generateUpdateDML(rowsData, []string{"money", "name", "id_gen"}, keyCols, tableInfo, "diff_test")
+	c.Assert(updateSQL, Equals, "UPDATE `diff_test`.`atest` SET `name` = 'a\\'a',`money` = 11.1111 WHERE `id` = 1 AND `name` = 'a\\'a';")
+}
+
+func (*testDiffSuite) TestGenerateRevertDML(c *C) {
+	createTableSQL := "CREATE TABLE `diff_test`.`atest` (`id` int(24), `name` varchar(24), primary key(`id`))"
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	c.Assert(err, IsNil)
+	_, keyCols := dbutil.SelectUniqueOrderKey(tableInfo)
+
+	targetRow := map[string]*dbutil.ColumnData{
+		"id":   {Data: []byte("1"), IsNull: false},
+		"name": {Data: []byte("xxx"), IsNull: false},
+	}
+
+	td := &TableDiff{
+		TargetTable: &TableInstance{Schema: "diff_test", Table: "atest", info: tableInfo},
+	}
+	// don't generate revert sqls if don't need to save them
+	c.Assert(td.generateRevertDML("replace", targetRow, nil, nil), Equals, "")
+
+	td.WriteRevertSQL = func(string) error { return nil }
+	c.Assert(td.generateRevertDML("replace", targetRow, nil, nil), Equals, "REPLACE INTO `diff_test`.`atest`(`id`,`name`) VALUES (1,'xxx');")
+	c.Assert(td.generateRevertDML("delete", targetRow, nil, nil), Equals, "DELETE FROM `diff_test`.`atest` WHERE `id` = 1 AND `name` = 'xxx';")
+	c.Assert(td.generateRevertDML("update", targetRow, []string{"name"}, keyCols), Equals, "UPDATE `diff_test`.`atest` SET `name` = 'xxx' WHERE `id` = 1;")
+}
+
+func (*testDiffSuite) TestCompareMD5Checksum(c *C) {
+	createTableSQL := "CREATE TABLE `test`.`atest` (`id` int(24), `name` varchar(24), primary key(`id`))"
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	c.Assert(err, IsNil)
+
+	sourceDB1, sourceMock1, err := sqlmock.New()
+	c.Assert(err, IsNil)
+	sourceDB2, sourceMock2, err := sqlmock.New()
+	c.Assert(err, IsNil)
+	targetDB, targetMock, err := sqlmock.New()
+	c.Assert(err, IsNil)
+
+	tableDiff := &TableDiff{
+		SourceTables: []*TableInstance{
+			{Conn: sourceDB1, Schema: "test", Table: "atest1", info: tableInfo},
+			{Conn: sourceDB2, Schema: "test", Table: "atest2", info: tableInfo},
+		},
+		TargetTable:      &TableInstance{Conn: targetDB, Schema: "test", Table: "atest", info: tableInfo},
+		ChecksumStrategy: MD5ChecksumStrategy,
+	}
+	chunk := &ChunkRange{ID: 1, Where: "TRUE"}
+	checksumRows := func(count int64, high, low string) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"cnt", "high", "low"}).AddRow(count, high, low)
+	}
+
+	// the checksums of sharding tables are summed up
+	sourceMock1.ExpectQuery("SELECT COUNT.*MD5").WillReturnRows(checksumRows(1, "18446744073709551615", "10"))
+	sourceMock2.ExpectQuery("SELECT COUNT.*MD5").WillReturnRows(checksumRows(2, "2", "20"))
+	targetMock.ExpectQuery("SELECT COUNT.*MD5").WillReturnRows(checksumRows(3, "1", "30"))
+	equal, rowNum, err := tableDiff.compareChecksum(context.Background(), chunk)
+	c.Assert(err, IsNil)
+	c.Assert(equal, IsTrue)
+	c.Assert(rowNum, Equals, int64(3))
+
+	// a duplicated row in target makes the row count different
+	sourceMock1.ExpectQuery("SELECT COUNT.*MD5").WillReturnRows(checksumRows(1, "5", "10"))
+	sourceMock2.ExpectQuery("SELECT COUNT.*MD5").WillReturnRows(checksumRows(0, "0", "0"))
+	targetMock.ExpectQuery("SELECT COUNT.*MD5").WillReturnRows(checksumRows(2, "10", "20"))
+	equal, _, err = tableDiff.compareChecksum(context.Background(), chunk)
+	c.Assert(err, IsNil)
+	c.Assert(equal, IsFalse)
+
+	for _, mock := range []sqlmock.Sqlmock{sourceMock1, sourceMock2, targetMock} {
+		c.Assert(mock.ExpectationsWereMet(), IsNil)
+	}
+}
+
+func (*testDiffSuite) TestCompareRowCount(c *C) {
+	sourceDB1, sourceMock1, err := sqlmock.New()
+	c.Assert(err, IsNil)
+	sourceDB2, sourceMock2, err := sqlmock.New()
+	c.Assert(err, IsNil)
+	targetDB, targetMock, err := sqlmock.New()
+	c.Assert(err, IsNil)
+
+	tableDiff := &TableDiff{
+		SourceTables: []*TableInstance{
+			{Conn: sourceDB1, Schema: "test", Table: "atest1"},
+			{Conn: sourceDB2, Schema: "test", Table: "atest2"},
+		},
+		TargetTable: &TableInstance{Conn: targetDB, Schema: "test", Table: "atest"},
+		summaryInfo: newTableSummaryInfo(2),
+	}
+	chunk := &ChunkRange{ID: 1, Where: "`id` > ?", Args: []string{"1"}}
+	countRows := func(count int64) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"cnt"}).AddRow(count)
+	}
+
+	// the row count of sharding tables are summed up
+	sourceMock1.ExpectQuery("SELECT COUNT\\(1\\) cnt FROM `test`.`atest1` WHERE `id` > \\?").WithArgs("1").WillReturnRows(countRows(10))
+	sourceMock2.ExpectQuery("SELECT COUNT\\(1\\) cnt FROM `test`.`atest2` WHERE `id` > \\?").WithArgs("1").WillReturnRows(countRows(20))
+	targetMock.ExpectQuery("SELECT COUNT\\(1\\) cnt FROM `test`.`atest` WHERE `id` > \\?").WithArgs("1").WillReturnRows(countRows(30))
+	equal, rowNum, err := tableDiff.compareRowCount(context.Background(), chunk)
+	c.Assert(err, IsNil)
+	c.Assert(equal, IsTrue)
+	c.Assert(rowNum, Equals, int64(30))
+
+	sourceMock1.ExpectQuery("SELECT COUNT").WillReturnRows(countRows(10))
+	sourceMock2.ExpectQuery("SELECT COUNT").WillReturnRows(countRows(20))
+	targetMock.ExpectQuery("SELECT COUNT").WillReturnRows(countRows(31))
+	equal, rowNum, err = tableDiff.compareRowCount(context.Background(), chunk)
+	c.Assert(err, IsNil)
+	c.Assert(equal, IsFalse)
+	c.Assert(rowNum, Equals, int64(31))
+
+	stats := tableDiff.Stats()
+	c.Assert(stats.CountMismatchChunkNum, Equals, int64(1))
+	c.Assert(stats.SourceRowCount, Equals, int64(60))
+	c.Assert(stats.TargetRowCount, Equals, int64(61))
+
+	for _, mock := range []sqlmock.Sqlmock{sourceMock1, sourceMock2, targetMock} {
+		c.Assert(mock.ExpectationsWereMet(), IsNil)
+	}
+}
+
+func (*testDiffSuite) TestCompareData(c *C) {
+	createTableSQL := "CREATE TABLE `diff_test`.`atest` (`id` int(24), `name` varchar(24), `age` int(11), `blob` blob, primary key(`id`))"
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	c.Assert(err, IsNil)
+	_, keyCols := dbutil.SelectUniqueOrderKey(tableInfo)
+
+	newRow := func(id, name, age, blob string) map[string]*dbutil.ColumnData {
+		row := make(map[string]*dbutil.ColumnData)
+		for col, value := range map[string]string{"id": id, "name": name, "age": age, "blob": blob} {
+			row[col] = &dbutil.ColumnData{Data: []byte(value), IsNull: value == "NULL"}
+		}
+		return row
+	}
+
+	equal, cmp, diffColumns, err := compareData(newRow("1", "a", "10", "xx"), newRow("1", "a", "10", "xx"), keyCols, nil)
+	c.Assert(err, IsNil)
+	c.Assert(equal, IsTrue)
+	c.Assert(cmp, Equals, int32(0))
+	c.Assert(diffColumns, HasLen, 0)
+
+	equal, cmp, diffColumns, err = compareData(newRow("1", "a", "10", "xx"), newRow("1", "b", "NULL", "xx"), keyCols, nil)
+	c.Assert(err, IsNil)
+	c.Assert(equal, IsFalse)
+	c.Assert(cmp, Equals, int32(0))
+	c.Assert(diffColumns, DeepEquals, []string{"age", "name"})
+
+	equal, cmp, _, err = compareData(newRow("1", "a", "10", "xx"), newRow("2", "a", "10", "xx"), keyCols, nil)
+	c.Assert(err, IsNil)
+	c.Assert(equal, IsFalse)
+	c.Assert(cmp, Equals, int32(-1))
+
+	equal, cmp, _, err = compareData(newRow("3", "a", "10", "xx"), newRow("2", "a", "10", "xx"), keyCols, nil)
+	c.Assert(err, IsNil)
+	c.Assert(equal, IsFalse)
+	c.Assert(cmp, Equals, int32(1))
 }
 
 func (t *testDiffSuite) TestDiff(c *C) {
@@ -395,4 +563,38 @@ func (*testDiffSuite) TestConfigHash(c *C) {
 	tbDiff.setConfigHash()
 	hash3 := tbDiff.configHash
 	c.Assert(hash1 == hash3, Equals, false)
+
+	// chunk size, stats source and the order of sharding source tables don't affect the checkpoint
+	tbDiff.SourceTables = []*TableInstance{
+		{InstanceID: "source-2", Schema: "test", Table: "t1"},
+		{InstanceID: "source-1", Schema: "test", Table: "t2"},
+		{InstanceID: "source-1", Schema: "test", Table: "t1"},
+	}
+	tbDiff.IgnoreColumns = []string{"b", "A"}
+	tbDiff.setConfigHash()
+	hash4 := tbDiff.configHash
+
+	tbDiff.ChunkSize = 2000
+	tbDiff.TiDBStatsSource = &TableInstance{InstanceID: "target", Schema: "test", Table: "t"}
+	tbDiff.SourceTables = []*TableInstance{tbDiff.SourceTables[2], tbDiff.SourceTables[0], tbDiff.SourceTables[1]}
+	tbDiff.IgnoreColumns = []string{"a", "b"}
+	tbDiff.setConfigHash()
+	c.Assert(tbDiff.configHash, Equals, hash4)
+	c.Assert(tbDiff.SourceTables[0].InstanceID, Equals, "source-1")
+	c.Assert(tbDiff.SourceTables[0].Table, Equals, "t1")
+
+	// add a sharding source table
+	tbDiff.SourceTables = append(tbDiff.SourceTables, &TableInstance{InstanceID: "source-3", Schema: "test", Table: "t1"})
+	tbDiff.setConfigHash()
+	c.Assert(tbDiff.configHash == hash4, Equals, false)
+	hash5 := tbDiff.configHash
+
+	// the tolerance affects the check result
+	tbDiff.ColumnTolerances = map[string]*ColumnTolerance{"Price": {AbsoluteEpsilon: 0.01}}
+	tbDiff.setConfigHash()
+	c.Assert(tbDiff.configHash == hash5, Equals, false)
+	hash6 := tbDiff.configHash
+	tbDiff.ColumnTolerances = map[string]*ColumnTolerance{"price": {AbsoluteEpsilon: 0.01}}
+	tbDiff.setConfigHash()
+	c.Assert(tbDiff.configHash, Equals, hash6)
 }
@@ -0,0 +1,79 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"go.uber.org/zap"
+)
+
+// adjustIncrementalRange limits the range to the rows updated since the last check by the update time column,
+// returns the new high watermark, and returns empty string if there is no data need to check.
+func (t *TableDiff) adjustIncrementalRange(ctx context.Context) (string, error) {
+	col := dbutil.FindColumnByName(t.TargetTable.info.Columns, t.UpdateTimeColumn)
+	if col == nil {
+		return "", errors.NotFoundf("update time column %s in table %s", t.UpdateTimeColumn, dbutil.TableName(t.TargetTable.Schema, t.TargetTable.Table))
+	}
+
+	ctx1, cancel1 := context.WithTimeout(ctx, dbutil.DefaultTimeout)
+	defer cancel1()
+
+	err := t.CheckpointStore.Init(ctx1)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+
+	lowWatermark, err := t.CheckpointStore.LoadWatermark(ctx1, t.TargetTable.Schema, t.TargetTable.Table, col.Name.O)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+
+	// the rows updated after the target's max update time may be not replicated yet, only check the rows before it
+	_, highWatermark, err := dbutil.GetMinMaxValue(ctx1, t.TargetTable.Conn, t.TargetTable.Schema, t.TargetTable.Table, col.Name.O, t.Range, nil, "")
+	if err != nil {
+		if errors.Cause(err) == dbutil.ErrNoData {
+			return "", nil
+		}
+		return "", errors.Trace(err)
+	}
+
+	colName := dbutil.ColumnName(col.Name.O)
+	incrementalRange := fmt.Sprintf("%s <= %s", colName, columnValue(col, &dbutil.ColumnData{Data: []byte(highWatermark)}))
+	if len(lowWatermark) != 0 {
+		// the rows may be updated with the same time as the last high watermark after the last check, so check them again
+		incrementalRange = fmt.Sprintf("%s >= %s AND %s", colName, columnValue(col, &dbutil.ColumnData{Data: []byte(lowWatermark)}), incrementalRange)
+	}
+	t.Range = fmt.Sprintf("(%s) AND %s", t.Range, incrementalRange)
+
+	log.Info("check data incrementally", zap.String("table", dbutil.TableName(t.TargetTable.Schema, t.TargetTable.Table)), zap.String("low watermark", lowWatermark), zap.String("high watermark", highWatermark), zap.String("range", t.Range))
+	return highWatermark, nil
+}
+
+// saveHighWatermark saves the high watermark after checked the data, the rows before it will not be checked next time.
+func (t *TableDiff) saveHighWatermark(ctx context.Context, highWatermark string) error {
+	col := dbutil.FindColumnByName(t.TargetTable.info.Columns, t.UpdateTimeColumn)
+	if col == nil {
+		return errors.NotFoundf("update time column %s in table %s", t.UpdateTimeColumn, dbutil.TableName(t.TargetTable.Schema, t.TargetTable.Table))
+	}
+
+	ctx1, cancel1 := context.WithTimeout(ctx, dbutil.DefaultTimeout)
+	defer cancel1()
+
+	return errors.Trace(t.CheckpointStore.SaveWatermark(ctx1, t.TargetTable.Schema, t.TargetTable.Table, col.Name.O, highWatermark))
+}
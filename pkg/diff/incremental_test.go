@@ -0,0 +1,112 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	. "github.com/pingcap/check"
+	"github.com/pingcap/parser"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+)
+
+var _ = Suite(&testIncrementalSuite{})
+
+type testIncrementalSuite struct{}
+
+func (s *testIncrementalSuite) TestAdjustIncrementalRange(c *C) {
+	createTableSQL := "CREATE TABLE `test`.`atest` (`id` int(24), `update_time` datetime, primary key(`id`))"
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	c.Assert(err, IsNil)
+
+	targetDB, targetMock, err := sqlmock.New()
+	c.Assert(err, IsNil)
+	cpDB, cpMock, err := sqlmock.New()
+	c.Assert(err, IsNil)
+
+	newTableDiff := func() *TableDiff {
+		return &TableDiff{
+			TargetTable:      &TableInstance{Conn: targetDB, Schema: "test", Table: "atest", info: tableInfo},
+			CheckpointStore:  NewSQLCheckpointStore(cpDB),
+			Range:            "TRUE",
+			UpdateTimeColumn: "UPDATE_TIME",
+		}
+	}
+	expectCreateCheckpointTable := func() {
+		cpMock.ExpectExec("CREATE DATABASE IF NOT EXISTS").WillReturnResult(sqlmock.NewResult(0, 0))
+		for i := 0; i < 3; i++ {
+			cpMock.ExpectExec("CREATE TABLE IF NOT EXISTS").WillReturnResult(sqlmock.NewResult(0, 0))
+		}
+	}
+	watermarkRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"column", "high_watermark"})
+	}
+	minMaxRows := func(max string) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"MIN", "MAX"}).AddRow("2021-01-01 00:00:00", max)
+	}
+
+	// never checked, check all the rows before the high watermark
+	tableDiff := newTableDiff()
+	expectCreateCheckpointTable()
+	cpMock.ExpectQuery("SELECT `column`, `high_watermark` FROM `sync_diff_inspector`.`watermark`").WithArgs("test", "atest").WillReturnRows(watermarkRows())
+	targetMock.ExpectQuery("SELECT .* MAX\\(`update_time`\\) as MAX FROM `test`.`atest` WHERE TRUE").WillReturnRows(minMaxRows("2021-01-02 00:00:00"))
+	highWatermark, err := tableDiff.adjustIncrementalRange(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(highWatermark, Equals, "2021-01-02 00:00:00")
+	c.Assert(tableDiff.Range, Equals, "(TRUE) AND `update_time` <= '2021-01-02 00:00:00'")
+
+	// only check the rows updated since the last check
+	tableDiff = newTableDiff()
+	expectCreateCheckpointTable()
+	cpMock.ExpectQuery("SELECT `column`, `high_watermark`").WillReturnRows(watermarkRows().AddRow("update_time", "2021-01-02 00:00:00"))
+	targetMock.ExpectQuery("SELECT .* MAX").WillReturnRows(minMaxRows("2021-01-03 00:00:00"))
+	highWatermark, err = tableDiff.adjustIncrementalRange(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(highWatermark, Equals, "2021-01-03 00:00:00")
+	c.Assert(tableDiff.Range, Equals, "(TRUE) AND `update_time` >= '2021-01-02 00:00:00' AND `update_time` <= '2021-01-03 00:00:00'")
+
+	// the max update time is not changed, the rows with the same update time may be updated after the last check
+	tableDiff = newTableDiff()
+	expectCreateCheckpointTable()
+	cpMock.ExpectQuery("SELECT `column`, `high_watermark`").WillReturnRows(watermarkRows().AddRow("update_time", "2021-01-03 00:00:00"))
+	targetMock.ExpectQuery("SELECT .* MAX").WillReturnRows(minMaxRows("2021-01-03 00:00:00"))
+	highWatermark, err = tableDiff.adjustIncrementalRange(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(highWatermark, Equals, "2021-01-03 00:00:00")
+	c.Assert(tableDiff.Range, Equals, "(TRUE) AND `update_time` >= '2021-01-03 00:00:00' AND `update_time` <= '2021-01-03 00:00:00'")
+
+	// no data in the table
+	tableDiff = newTableDiff()
+	expectCreateCheckpointTable()
+	cpMock.ExpectQuery("SELECT `column`, `high_watermark`").WillReturnRows(watermarkRows())
+	targetMock.ExpectQuery("SELECT .* MAX").WillReturnRows(sqlmock.NewRows([]string{"MIN", "MAX"}).AddRow(nil, nil))
+	highWatermark, err = tableDiff.adjustIncrementalRange(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(highWatermark, Equals, "")
+	c.Assert(tableDiff.Range, Equals, "TRUE")
+
+	// save the high watermark
+	cpMock.ExpectExec("REPLACE INTO `sync_diff_inspector`.`watermark`").WithArgs("test", "atest", "update_time", "2021-01-03 00:00:00", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	c.Assert(tableDiff.saveHighWatermark(context.Background(), "2021-01-03 00:00:00"), IsNil)
+
+	// the column doesn't exist
+	tableDiff = newTableDiff()
+	tableDiff.UpdateTimeColumn = "modify_time"
+	_, err = tableDiff.adjustIncrementalRange(context.Background())
+	c.Assert(err, ErrorMatches, ".*update time column modify_time.*not found")
+
+	c.Assert(cpMock.ExpectationsWereMet(), IsNil)
+	c.Assert(targetMock.ExpectationsWereMet(), IsNil)
+}
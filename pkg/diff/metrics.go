@@ -0,0 +1,96 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	chunkCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "sync_diff_inspector",
+			Subsystem: "diff",
+			Name:      "chunk_total",
+			Help:      "the num of chunks checked by state",
+		}, []string{"state"})
+
+	checksumDurationHistogram = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "sync_diff_inspector",
+			Subsystem: "diff",
+			Name:      "checksum_duration_seconds",
+			Help:      "the cost of getting a chunk's checksum in source or target",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 18),
+		}, []string{"side"})
+
+	comparedRowCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "sync_diff_inspector",
+			Subsystem: "diff",
+			Name:      "compared_row_total",
+			Help:      "the num of rows selected from source or target and compared",
+		}, []string{"side"})
+
+	fixSQLCounter = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "sync_diff_inspector",
+			Subsystem: "diff",
+			Name:      "fix_sql_total",
+			Help:      "the num of fix sqls generated",
+		})
+
+	throttleCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "sync_diff_inspector",
+			Subsystem: "diff",
+			Name:      "throttle_total",
+			Help:      "the num of waits to limit the rate of queries and rows, or to pause when the instance is overloaded",
+		}, []string{"type"})
+
+	retryCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "sync_diff_inspector",
+			Subsystem: "diff",
+			Name:      "retry_total",
+			Help:      "the num of retryable errors met when write checkpoint or apply fix sqls by type, these sqls will be retried",
+		}, []string{"type"})
+)
+
+// RegisterMetrics registers the metrics of diff to the registry.
+func RegisterMetrics(registry *prometheus.Registry) {
+	registry.MustRegister(chunkCounter)
+	registry.MustRegister(checksumDurationHistogram)
+	registry.MustRegister(comparedRowCounter)
+	registry.MustRegister(fixSQLCounter)
+	registry.MustRegister(throttleCounter)
+	registry.MustRegister(retryCounter)
+}
+
+// retryCountingDB counts the retryable errors when write checkpoint by dbutil.ExecSQLWithRetry.
+type retryCountingDB struct {
+	*sql.DB
+}
+
+func (db retryCountingDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	result, err := db.DB.ExecContext(ctx, query, args...)
+	if err != nil && dbutil.IsRetryableError(err) {
+		retryCounter.WithLabelValues("checkpoint").Inc()
+	}
+	return result, err
+}
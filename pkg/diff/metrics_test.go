@@ -0,0 +1,99 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	. "github.com/pingcap/check"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var _ = Suite(&testMetricsSuite{})
+
+type testMetricsSuite struct{}
+
+func (s *testMetricsSuite) TestRegisterMetrics(c *C) {
+	registry := prometheus.NewRegistry()
+	RegisterMetrics(registry)
+
+	chunkCounter.WithLabelValues(successState).Inc()
+	checksumDurationHistogram.WithLabelValues("source").Observe(0.1)
+	comparedRowCounter.WithLabelValues("target").Inc()
+	fixSQLCounter.Inc()
+	retryCounter.WithLabelValues("checkpoint").Inc()
+
+	metrics, err := registry.Gather()
+	c.Assert(err, IsNil)
+	names := make([]string, 0, len(metrics))
+	for _, metric := range metrics {
+		names = append(names, metric.GetName())
+	}
+	c.Assert(names, DeepEquals, []string{
+		"sync_diff_inspector_diff_checksum_duration_seconds",
+		"sync_diff_inspector_diff_chunk_total",
+		"sync_diff_inspector_diff_compared_row_total",
+		"sync_diff_inspector_diff_fix_sql_total",
+		"sync_diff_inspector_diff_retry_total",
+	})
+}
+
+func (s *testMetricsSuite) TestRetryCountingDB(c *C) {
+	db, mock, err := sqlmock.New()
+	c.Assert(err, IsNil)
+
+	before := testutil.ToFloat64(retryCounter.WithLabelValues("checkpoint"))
+	// the first execution meets a retryable error, and the second one succeeds
+	mock.ExpectExec("REPLACE INTO").WillReturnError(&mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"})
+	mock.ExpectExec("REPLACE INTO").WillReturnResult(sqlmock.NewResult(0, 1))
+	err = dbutil.ExecSQLWithRetry(context.Background(), retryCountingDB{db}, "REPLACE INTO `test`.`t` VALUES(1)")
+	c.Assert(err, IsNil)
+	c.Assert(testutil.ToFloat64(retryCounter.WithLabelValues("checkpoint"))-before, Equals, float64(1))
+
+	c.Assert(mock.ExpectationsWereMet(), IsNil)
+}
+
+func (s *testMetricsSuite) TestRetryFixSQLs(c *C) {
+	db, mock, err := sqlmock.New()
+	c.Assert(err, IsNil)
+	tableDiff := &TableDiff{TargetTable: &TableInstance{Conn: db, Schema: "test", Table: "t"}}
+
+	before := testutil.ToFloat64(retryCounter.WithLabelValues("fix_sql"))
+	// the transaction meets a retryable error and is rolled back, and then the whole transaction is retried
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("REPLACE INTO").WillReturnError(&mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"})
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("REPLACE INTO").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	err = tableDiff.executeFixSQLs(context.Background(), []string{"DELETE FROM `test`.`t` WHERE `id` = 1;", "REPLACE INTO `test`.`t`(`id`) VALUES (1);"})
+	c.Assert(err, IsNil)
+	c.Assert(testutil.ToFloat64(retryCounter.WithLabelValues("fix_sql"))-before, Equals, float64(1))
+
+	// the error is not retryable
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM").WillReturnError(&mysql.MySQLError{Number: 1146, Message: "Table 'test.t' doesn't exist"})
+	mock.ExpectRollback()
+	err = tableDiff.executeFixSQLs(context.Background(), []string{"DELETE FROM `test`.`t` WHERE `id` = 1;"})
+	c.Assert(err, ErrorMatches, ".*doesn't exist")
+	c.Assert(testutil.ToFloat64(retryCounter.WithLabelValues("fix_sql"))-before, Equals, float64(1))
+
+	c.Assert(mock.ExpectationsWereMet(), IsNil)
+}
@@ -0,0 +1,401 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"container/heap"
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb-tools/pkg/utils"
+	"github.com/pingcap/tidb/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// multisetCountColumn is the alias of the number of copies of a row selected from the table without unique key.
+	multisetCountColumn = "_sync_diff_row_count"
+	// multisetHashColumn is the alias of the hash of a row selected from the table without unique key, the rows are
+	// grouped and ordered by it.
+	multisetHashColumn = "_sync_diff_row_hash"
+)
+
+// isMultiset returns true if the rows are compared as multiset, the table has no primary key or unique key
+// to identify a row, so the same rows are counted and compared by the number of copies.
+func (t *TableDiff) isMultiset() bool {
+	return !hasUniqueIndex(t.TargetTable.info)
+}
+
+// rowCount is a distinct row and the number of its copies.
+type rowCount struct {
+	// the row's data with its hash, the hash is used to order the rows
+	data  map[string]*dbutil.ColumnData
+	count int64
+}
+
+// row returns the row's data without the hash.
+func (r *rowCount) row() map[string]*dbutil.ColumnData {
+	if _, ok := r.data[multisetHashColumn]; !ok {
+		return r.data
+	}
+
+	row := make(map[string]*dbutil.ColumnData, len(r.data)-1)
+	for name, data := range r.data {
+		if name != multisetHashColumn {
+			row[name] = data
+		}
+	}
+	return row
+}
+
+// rowCountReader reads the distinct rows and their counts selected by getChunkRowCounts.
+type rowCountReader struct {
+	rows   *sql.Rows
+	mapper *columnMapper
+}
+
+// next returns the next row, returns nil if all the rows are read.
+func (r *rowCountReader) next() (*rowCount, error) {
+	if !r.rows.Next() {
+		return nil, errors.Trace(r.rows.Err())
+	}
+
+	data, err := dbutil.ScanRow(r.rows)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	countData, ok := data[multisetCountColumn]
+	if !ok {
+		return nil, errors.NotFoundf("column %s in row", multisetCountColumn)
+	}
+	delete(data, multisetCountColumn)
+	count, err := strconv.ParseInt(string(countData.Data), 10, 64)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	if r.mapper != nil {
+		if err = r.mapper.mapRow(data); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+
+	return &rowCount{data: data, count: count}, nil
+}
+
+// mergedRowCountReader merges the rows of the sources in order, and sums the counts of the same rows in different sources.
+type mergedRowCountReader struct {
+	readers []*rowCountReader
+	// source index => the count of the source's row in heap
+	counts map[int]int64
+	rows   *RowDatas
+	inited bool
+}
+
+func newMergedRowCountReader(readers []*rowCountReader, orderKeyCols []*model.ColumnInfo) *mergedRowCountReader {
+	rows := &RowDatas{
+		Rows:         make([]RowData, 0, len(readers)),
+		OrderKeyCols: orderKeyCols,
+	}
+	heap.Init(rows)
+
+	return &mergedRowCountReader{
+		readers: readers,
+		counts:  make(map[int]int64, len(readers)),
+		rows:    rows,
+	}
+}
+
+// next returns the smallest row of all the sources, returns nil if all the rows are read.
+func (m *mergedRowCountReader) next() (*rowCount, error) {
+	if !m.inited {
+		m.inited = true
+		for i := range m.readers {
+			if err := m.push(i); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var row *rowCount
+	for m.rows.Len() != 0 {
+		if row != nil && !equalRowData(row.data, m.rows.Rows[0].Data) {
+			break
+		}
+
+		rowData := heap.Pop(m.rows).(RowData)
+		if row == nil {
+			row = &rowCount{data: rowData.Data}
+		}
+		row.count += m.counts[rowData.Source]
+
+		if err := m.push(rowData.Source); err != nil {
+			return nil, err
+		}
+	}
+
+	return row, nil
+}
+
+// push reads the next row of the source and pushes it to the heap.
+func (m *mergedRowCountReader) push(source int) error {
+	row, err := m.readers[source].next()
+	if err != nil || row == nil {
+		return err
+	}
+
+	m.counts[source] = row.count
+	heap.Push(m.rows, RowData{Data: row.data, Source: source})
+	return nil
+}
+
+// equalRowData returns true if all the columns' values are same.
+func equalRowData(row1, row2 map[string]*dbutil.ColumnData) bool {
+	if len(row1) != len(row2) {
+		return false
+	}
+
+	for key, data1 := range row1 {
+		data2, ok := row2[key]
+		if !ok || data1.IsNull != data2.IsNull || string(data1.Data) != string(data2.Data) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// compareMultisetRows compares the rows of the table without unique key in the range, the same rows are grouped and
+// compared by the number of copies, and the fix sqls delete the redundant copies and insert the lacked copies in target.
+// returns the num of rows in target's range.
+func (t *TableDiff) compareMultisetRows(ctx context.Context, chunk *ChunkRange, r *ChunkRange) (bool, int64, error) {
+	beginTime := time.Now()
+
+	where, whereArgs := r.Where, r.Args
+	args := utils.StringsToInterfaces(whereArgs)
+
+	if err := t.TargetTable.Throttler.WaitQuery(ctx); err != nil {
+		return false, 0, errors.Trace(err)
+	}
+	targetRows, orderKeyCols, err := getChunkRowCounts(ctx, t.TargetTable.Conn, t.TargetTable.from(), t.TargetTable.info, nil, nil, where, args)
+	if err != nil {
+		return false, 0, errors.Trace(err)
+	}
+	defer targetRows.Close()
+	targetReader := &rowCountReader{rows: targetRows}
+
+	sourceReaders := make([]*rowCountReader, 0, len(t.SourceTables))
+	for _, sourceTable := range t.SourceTables {
+		sourceWhere, sourceArgs, err := t.sourceWhere(sourceTable, r)
+		if err != nil {
+			return false, 0, errors.Trace(err)
+		}
+		tableInfo, columnExprs := sourceTable.info, map[string]string(nil)
+		if sourceTable.columns != nil {
+			tableInfo, columnExprs = t.TargetTable.info, sourceTable.columns.exprs
+		}
+		if err = sourceTable.Throttler.WaitQuery(ctx); err != nil {
+			return false, 0, errors.Trace(err)
+		}
+		// the hash is calculated by the mapped value, so it's same as the hash of the row in target
+		rows, _, err := getChunkRowCounts(ctx, sourceTable.Conn, sourceTable.from(), tableInfo, columnExprs, sourceTable.checksumExprs(), sourceWhere, sourceArgs)
+		if err != nil {
+			return false, 0, errors.Trace(err)
+		}
+		defer rows.Close()
+
+		sourceReaders = append(sourceReaders, &rowCountReader{rows: rows, mapper: sourceTable.mapper})
+	}
+	sourceReader := newMergedRowCountReader(sourceReaders, orderKeyCols)
+
+	var sourceRow, targetRow *rowCount
+	var targetRowNum int64
+	equal := true
+	for {
+		if sourceRow == nil {
+			if sourceRow, err = sourceReader.next(); err != nil {
+				return false, 0, errors.Trace(err)
+			}
+			if sourceRow != nil {
+				comparedRowCounter.WithLabelValues("source").Add(float64(sourceRow.count))
+			}
+		}
+		if targetRow == nil {
+			if targetRow, err = targetReader.next(); err != nil {
+				return false, 0, errors.Trace(err)
+			}
+			if targetRow != nil {
+				targetRowNum += targetRow.count
+				comparedRowCounter.WithLabelValues("target").Add(float64(targetRow.count))
+			}
+		}
+		if sourceRow == nil && targetRow == nil {
+			break
+		}
+
+		var insertNum, deleteNum int64
+		insertRow, deleteRow := sourceRow, targetRow
+		switch {
+		case sourceRow == nil:
+			// don't have source data, so all the copies in target are redundant
+			deleteNum = targetRow.count
+			targetRow = nil
+		case targetRow == nil:
+			// target lack all the copies in source
+			insertNum = sourceRow.count
+			sourceRow = nil
+		default:
+			eq, cmp, _, err := compareData(sourceRow.data, targetRow.data, orderKeyCols, t.columnTolerances)
+			if err != nil {
+				return false, 0, errors.Trace(err)
+			}
+
+			switch {
+			case eq:
+				if sourceRow.count > targetRow.count {
+					insertNum = sourceRow.count - targetRow.count
+				} else if sourceRow.count < targetRow.count {
+					deleteNum = targetRow.count - sourceRow.count
+				}
+				if insertNum != 0 || deleteNum != 0 {
+					log.Warn("the number of copies is different", zap.String("row", rowToString(sourceRow.row())), zap.Int64("source count", sourceRow.count), zap.Int64("target count", targetRow.count))
+				}
+				sourceRow, targetRow = nil, nil
+			case cmp > 0:
+				deleteNum = targetRow.count
+				targetRow = nil
+			case cmp < 0:
+				insertNum = sourceRow.count
+				sourceRow = nil
+			default:
+				// the values are equal but in different format, replace all the copies in target by the copies in source
+				insertNum, deleteNum = sourceRow.count, targetRow.count
+				sourceRow, targetRow = nil, nil
+			}
+		}
+
+		if insertNum == 0 && deleteNum == 0 {
+			continue
+		}
+		equal = false
+
+		// all the columns identify the row in the table without unique key
+		for i := int64(0); i < deleteNum; i++ {
+			t.writeRowDiff(DiffTypeDelete, chunk, nil, deleteRow.row(), t.TargetTable.info.Columns, nil)
+		}
+		for i := int64(0); i < insertNum; i++ {
+			t.writeRowDiff(DiffTypeInsert, chunk, insertRow.row(), nil, t.TargetTable.info.Columns, nil)
+		}
+		if !t.sendMultisetFixSQLs(ctx, chunk, insertRow, insertNum, deleteRow, deleteNum) {
+			return false, 0, nil
+		}
+	}
+
+	if equal {
+		log.Info("rows is equal", zap.String("table", dbutil.TableName(t.TargetTable.Schema, t.TargetTable.Table)), zap.String("where", dbutil.ReplacePlaceholder(where, whereArgs)), zap.Duration("cost", time.Since(beginTime)))
+	} else {
+		log.Warn("rows is not equal", zap.String("table", dbutil.TableName(t.TargetTable.Schema, t.TargetTable.Table)), zap.String("where", dbutil.ReplacePlaceholder(where, whereArgs)), zap.Duration("cost", time.Since(beginTime)))
+	}
+
+	return equal, targetRowNum, nil
+}
+
+// sendMultisetFixSQLs sends the sqls used to fix the copies of a row in the table without unique key. the same copies
+// can't be distinguished, so deleteNum copies of deleteRow in target are deleted by one sql with limit, and then
+// insertNum copies of insertRow are inserted. every sql is sent with the revert sql of the copies it changes.
+func (t *TableDiff) sendMultisetFixSQLs(ctx context.Context, chunk *ChunkRange, insertRow *rowCount, insertNum int64, deleteRow *rowCount, deleteNum int64) bool {
+	if deleteNum != 0 {
+		var revertSQLs []string
+		for i := int64(0); t.WriteRevertSQL != nil && i < deleteNum; i++ {
+			revertSQLs = append(revertSQLs, t.generateRevertDML("replace", deleteRow.row(), nil, nil))
+		}
+
+		sql := generateMultisetDeleteDML(deleteRow.row(), deleteNum, t.TargetTable.info, t.TargetTable.Schema)
+		log.Info("[delete]", zap.String("sql", sql), zap.Int64("copies", deleteRow.count))
+		if !t.sendFixSQL(ctx, chunk, sql, strings.Join(revertSQLs, "\n")) {
+			return false
+		}
+	}
+
+	for i := int64(0); i < insertNum; i++ {
+		var revertSQL string
+		if t.WriteRevertSQL != nil {
+			revertSQL = generateMultisetDeleteDML(insertRow.row(), 1, t.TargetTable.info, t.TargetTable.Schema)
+		}
+
+		sql := generateDML("replace", insertRow.row(), t.TargetTable.info, t.TargetTable.Schema)
+		log.Info("[insert]", zap.String("sql", sql))
+		if !t.sendFixSQL(ctx, chunk, sql, revertSQL) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// generateMultisetDeleteDML generates sql to delete num copies of the row in the table without unique key.
+func generateMultisetDeleteDML(data map[string]*dbutil.ColumnData, num int64, table *model.TableInfo, schema string) string {
+	sql := generateDML("delete", data, table, schema)
+	return fmt.Sprintf("%s LIMIT %d;", strings.TrimSuffix(sql, ";"), num)
+}
+
+// getChunkRowCounts selects the distinct rows and the number of copies of each row. the rows are grouped and ordered by
+// the hash of the columns' raw bytes, so the rows are distinguished by the binary value whatever the columns' collation
+// is, and the long BLOB and TEXT values are not truncated by max_sort_length. the columns are hashed by the expression in
+// hashExprs if exists, it's the mapped value of the source's column. returns the hash column as the order key.
+func getChunkRowCounts(ctx context.Context, db *sql.DB, from string, tableInfo *model.TableInfo, columnExprs, hashExprs map[string]string,
+	where string, args []interface{}) (*sql.Rows, []*model.ColumnInfo, error) {
+	columns := make([]string, 0, len(tableInfo.Columns)+1)
+	hashes := make([]string, 0, len(tableInfo.Columns))
+	isNulls := make([]string, 0, len(tableInfo.Columns))
+	for _, col := range tableInfo.Columns {
+		name := dbutil.ColumnName(col.Name.O)
+		expr := name
+		if columnExpr, ok := columnExprs[col.Name.O]; ok {
+			expr = columnExpr
+		}
+		// all the rows in a group have the same value, MIN is used instead of ANY_VALUE which is not supported by MySQL 5.6 and MariaDB
+		columns = append(columns, fmt.Sprintf("MIN(%s) AS %s", expr, name))
+
+		if hashExpr, ok := hashExprs[col.Name.O]; ok {
+			expr = hashExpr
+		}
+		hashes = append(hashes, fmt.Sprintf("MD5(%s)", expr))
+		isNulls = append(isNulls, fmt.Sprintf("ISNULL(%s)", expr))
+	}
+	// the md5 of every column has the same length, and the null columns are marked, so the row's hash is unique
+	hashColumn := dbutil.ColumnName(multisetHashColumn)
+	columns = append(columns, fmt.Sprintf("MD5(CONCAT_WS(',', %s, CONCAT(%s))) AS %s", strings.Join(hashes, ", "), strings.Join(isNulls, ", "), hashColumn))
+
+	query := fmt.Sprintf("SELECT /*!40001 SQL_NO_CACHE */ %s, COUNT(*) AS %s FROM %s WHERE %s GROUP BY %s ORDER BY %s",
+		strings.Join(columns, ", "), dbutil.ColumnName(multisetCountColumn), from, where, hashColumn, hashColumn)
+
+	log.Debug("select data", zap.String("sql", query), zap.Reflect("args", args))
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+
+	hashCol := &model.ColumnInfo{Name: model.NewCIStr(multisetHashColumn), FieldType: *types.NewFieldType(mysql.TypeVarString)}
+	return rows, []*model.ColumnInfo{hashCol}, nil
+}
@@ -0,0 +1,137 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"fmt"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	. "github.com/pingcap/check"
+	"github.com/pingcap/parser"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+)
+
+var _ = Suite(&testMultisetSuite{})
+
+type testMultisetSuite struct{}
+
+func (s *testMultisetSuite) TestGenerateDML(c *C) {
+	tableInfo, err := dbutil.GetTableInfoBySQL("CREATE TABLE `test`.`log` (`level` varchar(8), `msg` varchar(64))", parser.New())
+	c.Assert(err, IsNil)
+
+	row := map[string]*dbutil.ColumnData{
+		"level": {Data: []byte("info")},
+		"msg":   {IsNull: true},
+	}
+	// all the copies of the same rows are deleted without limit
+	c.Assert(generateDML("delete", row, tableInfo, "test"), Equals, "DELETE FROM `test`.`log` WHERE `level` = 'info' AND `msg` is NULL;")
+	c.Assert(generateDML("replace", row, tableInfo, "test"), Equals, "REPLACE INTO `test`.`log`(`level`,`msg`) VALUES ('info',NULL);")
+	c.Assert(generateMultisetDeleteDML(row, 2, tableInfo, "test"), Equals, "DELETE FROM `test`.`log` WHERE `level` = 'info' AND `msg` is NULL LIMIT 2;")
+}
+
+func (s *testMultisetSuite) TestCompareMultisetRows(c *C) {
+	tableInfo, err := dbutil.GetTableInfoBySQL("CREATE TABLE `test`.`log` (`id` int, `msg` varchar(64))", parser.New())
+	c.Assert(err, IsNil)
+
+	source1DB, source1Mock, err := sqlmock.New()
+	c.Assert(err, IsNil)
+	source2DB, source2Mock, err := sqlmock.New()
+	c.Assert(err, IsNil)
+	targetDB, targetMock, err := sqlmock.New()
+	c.Assert(err, IsNil)
+
+	tableDiff := &TableDiff{
+		SourceTables: []*TableInstance{
+			{Conn: source1DB, Schema: "test", Table: "log_1", info: tableInfo},
+			{Conn: source2DB, Schema: "test", Table: "log_2", info: tableInfo},
+		},
+		TargetTable:    &TableInstance{Conn: targetDB, Schema: "test", Table: "log", info: tableInfo},
+		Range:          "TRUE",
+		summaryInfo:    newTableSummaryInfo(1),
+		sqlCh:          make(chan *fixSQL, 10),
+		WriteRevertSQL: func(string) error { return nil },
+	}
+	c.Assert(tableDiff.isMultiset(), IsTrue)
+
+	chunk := NewChunkRange()
+	chunk.Where, chunk.Args = "((TRUE) AND TRUE)", nil
+
+	columns := []string{"id", "msg", multisetHashColumn, multisetCountColumn}
+	// the rows are grouped and ordered by the hash of the raw bytes
+	query := "SELECT .* MIN\\(`id`\\) AS `id`, MIN\\(`msg`\\) AS `msg`, MD5\\(CONCAT_WS\\(',', MD5\\(`id`\\), MD5\\(`msg`\\), CONCAT\\(ISNULL\\(`id`\\), ISNULL\\(`msg`\\)\\)\\)\\) AS `_sync_diff_row_hash`, " +
+		"COUNT\\(\\*\\) AS `_sync_diff_row_count` FROM `test`.`%s` WHERE .* GROUP BY `_sync_diff_row_hash` ORDER BY `_sync_diff_row_hash`$"
+	// the source has (1, a) * 3, (2, b) * 1, (3, c) * 2 in total
+	source1Mock.ExpectQuery(fmt.Sprintf(query, "log_1")).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(1, "a", "h1", 2).AddRow(3, "c", "h3", 2))
+	source2Mock.ExpectQuery(fmt.Sprintf(query, "log_2")).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(1, "a", "h1", 1).AddRow(2, "b", "h2", 1))
+	// the target has (1, a) * 1, (2, b) * 1, (3, c) * 3, (4, d) * 1
+	targetMock.ExpectQuery(fmt.Sprintf(query, "log")).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(1, "a", "h1", 1).AddRow(2, "b", "h2", 1).AddRow(3, "c", "h3", 3).AddRow(4, "d", "h4", 1))
+
+	equal, rowNum, err := tableDiff.compareRows(context.Background(), chunk, chunk)
+	c.Assert(err, IsNil)
+	c.Assert(equal, IsFalse)
+	c.Assert(rowNum, Equals, int64(6))
+
+	close(tableDiff.sqlCh)
+	sqls := make([]string, 0, 6)
+	revertSQLs := make([]string, 0, 6)
+	for fixSQL := range tableDiff.sqlCh {
+		sqls = append(sqls, fixSQL.sql)
+		revertSQLs = append(revertSQLs, fixSQL.revertSQL)
+	}
+	// the copies of (3, c) can't be distinguished, so only the redundant copy is deleted by limit
+	c.Assert(sqls, DeepEquals, []string{
+		"REPLACE INTO `test`.`log`(`id`,`msg`) VALUES (1,'a');",
+		"REPLACE INTO `test`.`log`(`id`,`msg`) VALUES (1,'a');",
+		"DELETE FROM `test`.`log` WHERE `id` = 3 AND `msg` = 'c' LIMIT 1;",
+		"DELETE FROM `test`.`log` WHERE `id` = 4 AND `msg` = 'd' LIMIT 1;",
+	})
+	// the revert sqls only restore the copies changed by the fix sqls
+	c.Assert(revertSQLs, DeepEquals, []string{
+		"DELETE FROM `test`.`log` WHERE `id` = 1 AND `msg` = 'a' LIMIT 1;",
+		"DELETE FROM `test`.`log` WHERE `id` = 1 AND `msg` = 'a' LIMIT 1;",
+		"REPLACE INTO `test`.`log`(`id`,`msg`) VALUES (3,'c');",
+		"REPLACE INTO `test`.`log`(`id`,`msg`) VALUES (4,'d');",
+	})
+	insertNum, deleteNum, updateNum := tableDiff.summaryInfo.getRowNum()
+	c.Assert(insertNum, Equals, int64(2))
+	c.Assert(deleteNum, Equals, int64(2))
+	c.Assert(updateNum, Equals, int64(0))
+
+	for _, mock := range []sqlmock.Sqlmock{source1Mock, source2Mock, targetMock} {
+		c.Assert(mock.ExpectationsWereMet(), IsNil)
+	}
+}
+
+func (s *testMultisetSuite) TestGetChunkRowCounts(c *C) {
+	tableInfo, err := dbutil.GetTableInfoBySQL("CREATE TABLE `test`.`log` (`msg` text, `price` double)", parser.New())
+	c.Assert(err, IsNil)
+	db, mock, err := sqlmock.New()
+	c.Assert(err, IsNil)
+
+	// the rows are selected by the column expressions, and the hash uses the mapped value
+	mock.ExpectQuery("SELECT .* MIN\\(`msg`\\) AS `msg`, MIN\\(0\\) AS `price`, MD5\\(CONCAT_WS\\(',', MD5\\(CONCAT\\('a:', `msg`\\)\\), MD5\\(0\\), CONCAT\\(ISNULL\\(CONCAT\\('a:', `msg`\\)\\), ISNULL\\(0\\)\\)\\)\\) AS `_sync_diff_row_hash`, " +
+		"COUNT\\(\\*\\) AS `_sync_diff_row_count` FROM `test`.`log_1` WHERE TRUE GROUP BY `_sync_diff_row_hash` ORDER BY `_sync_diff_row_hash`$").
+		WillReturnRows(sqlmock.NewRows([]string{"msg", "price", multisetHashColumn, multisetCountColumn}))
+	rows, orderKeyCols, err := getChunkRowCounts(context.Background(), db, "`test`.`log_1`", tableInfo, map[string]string{"price": "0"}, map[string]string{"msg": "CONCAT('a:', `msg`)", "price": "0"}, "TRUE", nil)
+	c.Assert(err, IsNil)
+	c.Assert(rows.Close(), IsNil)
+	c.Assert(orderKeyCols, HasLen, 1)
+	c.Assert(orderKeyCols[0].Name.O, Equals, multisetHashColumn)
+
+	c.Assert(mock.ExpectationsWereMet(), IsNil)
+}
@@ -0,0 +1,273 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"go.uber.org/zap"
+)
+
+const (
+	day              = 24 * time.Hour
+	timeWindowLayout = "15:04"
+)
+
+// TimeWindow is a time range of every day in local time.
+type TimeWindow struct {
+	// the offset since midnight
+	start, end time.Duration
+}
+
+// ParseTimeWindow parses the time window in the format of "HH:MM-HH:MM", for example "01:00-06:00",
+// the window crosses midnight if the end is less than the start, for example "22:00-02:00".
+func ParseTimeWindow(s string) (*TimeWindow, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return nil, errors.NotValidf("time window %s, should be in the format of HH:MM-HH:MM", s)
+	}
+
+	offsets := make([]time.Duration, 0, 2)
+	for _, part := range parts {
+		t, err := time.Parse(timeWindowLayout, strings.TrimSpace(part))
+		if err != nil {
+			return nil, errors.Annotatef(err, "parse time window %s", s)
+		}
+		offsets = append(offsets, sinceMidnight(t))
+	}
+	if offsets[0] == offsets[1] {
+		return nil, errors.NotValidf("time window %s with the same start and end", s)
+	}
+
+	return &TimeWindow{start: offsets[0], end: offsets[1]}, nil
+}
+
+func (w *TimeWindow) String() string {
+	midnight := time.Time{}
+	return fmt.Sprintf("%s-%s", midnight.Add(w.start).Format(timeWindowLayout), midnight.Add(w.end).Format(timeWindowLayout))
+}
+
+// contains returns true if the time is in the window.
+func (w *TimeWindow) contains(t time.Time) bool {
+	offset := sinceMidnight(t)
+	if w.start < w.end {
+		return offset >= w.start && offset < w.end
+	}
+	return offset >= w.start || offset < w.end
+}
+
+// untilStart returns the duration from the time to the next start of the window.
+func (w *TimeWindow) untilStart(t time.Time) time.Duration {
+	d := w.start - sinceMidnight(t)
+	if d <= 0 {
+		d += day
+	}
+	return d
+}
+
+func sinceMidnight(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond())
+}
+
+// Pauser pauses checking chunks out of the allowed time windows or when it is paused manually. the chunks being checked
+// are finished and the tables' summary are saved before pause, so the check can continue from the checkpoint even if
+// the process exits when paused. it can be shared by the tables checked concurrently.
+type Pauser struct {
+	// checking chunks is only allowed in these windows, always allowed if is empty
+	windows []*TimeWindow
+
+	mu     sync.Mutex
+	paused bool
+	// closed when resumed manually
+	resumeCh chan struct{}
+
+	// returns the current time, only changed in test
+	now func() time.Time
+}
+
+// NewPauser returns a Pauser only allows checking chunks in the time windows.
+func NewPauser(windows []*TimeWindow) *Pauser {
+	return &Pauser{
+		windows: windows,
+		now:     time.Now,
+	}
+}
+
+// Pause pauses checking new chunks until Resume is called.
+func (p *Pauser) Pause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.paused {
+		return
+	}
+	p.paused = true
+	p.resumeCh = make(chan struct{})
+	log.Info("pause checking chunks manually")
+}
+
+// Resume resumes checking chunks paused by Pause, the chunks are still only checked in the time windows.
+func (p *Pauser) Resume() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.paused {
+		return
+	}
+	p.paused = false
+	close(p.resumeCh)
+	log.Info("resume checking chunks manually")
+}
+
+// PauseReason returns the reason if checking chunks is paused now, returns empty string if not paused.
+func (p *Pauser) PauseReason() string {
+	if p == nil {
+		return ""
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.pauseReason()
+}
+
+func (p *Pauser) pauseReason() string {
+	if p.paused {
+		return "paused manually"
+	}
+	if len(p.windows) == 0 {
+		return ""
+	}
+
+	now := p.now()
+	windows := make([]string, 0, len(p.windows))
+	for _, window := range p.windows {
+		if window.contains(now) {
+			return ""
+		}
+		windows = append(windows, window.String())
+	}
+	return fmt.Sprintf("out of the time windows %s", strings.Join(windows, ", "))
+}
+
+// wait blocks until checking chunks is not paused, returns error if the context is done.
+func (p *Pauser) wait(ctx context.Context) error {
+	for {
+		p.mu.Lock()
+		reason, paused, resumeCh := p.pauseReason(), p.paused, p.resumeCh
+		now := p.now()
+		p.mu.Unlock()
+
+		if len(reason) == 0 {
+			return nil
+		}
+
+		if paused {
+			select {
+			case <-resumeCh:
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		// wait until the nearest time window starts
+		d := day
+		for _, window := range p.windows {
+			if untilStart := window.untilStart(now); untilStart < d {
+				d = untilStart
+			}
+		}
+		if err := sleep(ctx, d); err != nil {
+			return err
+		}
+	}
+}
+
+// waitResume blocks until checking chunks is resumed if it is paused, the table's chunks being checked
+// are finished and the table's summary is saved before wait.
+func (t *TableDiff) waitResume(ctx context.Context) error {
+	reason := t.Pauser.PauseReason()
+	if len(reason) == 0 {
+		return nil
+	}
+
+	table := dbutil.TableName(t.TargetTable.Schema, t.TargetTable.Table)
+	t.waitRunningChunks()
+	t.updateSummary(ctx)
+	log.Info("pause checking table", zap.String("table", table), zap.String("reason", reason))
+
+	if err := t.Pauser.wait(ctx); err != nil {
+		return errors.Trace(err)
+	}
+	log.Info("resume checking table", zap.String("table", table))
+	return nil
+}
+
+// acquireWorker blocks until checking chunks is not paused and gets a worker from the pool, and adds a running chunk.
+// it may be paused while waiting for the worker, so the pause state is checked again after got the worker.
+func (t *TableDiff) acquireWorker(ctx context.Context) error {
+	for {
+		if err := t.waitResume(ctx); err != nil {
+			return errors.Trace(err)
+		}
+		if t.WorkerPool != nil && !t.WorkerPool.Acquire(ctx) {
+			return errors.Trace(ctx.Err())
+		}
+		// add the running chunk before check, so it's waited by the pause happens after the check
+		t.addRunningChunk(1)
+		if len(t.Pauser.PauseReason()) == 0 {
+			return nil
+		}
+		t.releaseWorker()
+	}
+}
+
+// releaseWorker puts back the worker got by acquireWorker, and removes the running chunk.
+func (t *TableDiff) releaseWorker() {
+	t.addRunningChunk(-1)
+	if t.WorkerPool != nil {
+		t.WorkerPool.Release()
+	}
+}
+
+// addRunningChunk changes the num of the table's chunks being checked.
+func (t *TableDiff) addRunningChunk(delta int) {
+	t.runningMu.Lock()
+	defer t.runningMu.Unlock()
+
+	t.runningChunks += delta
+	if t.runningChunks == 0 && t.runningCond != nil {
+		t.runningCond.Broadcast()
+	}
+}
+
+// waitRunningChunks blocks until all the table's chunks being checked are finished.
+func (t *TableDiff) waitRunningChunks() {
+	t.runningMu.Lock()
+	defer t.runningMu.Unlock()
+
+	if t.runningCond == nil {
+		t.runningCond = sync.NewCond(&t.runningMu)
+	}
+	for t.runningChunks != 0 {
+		t.runningCond.Wait()
+	}
+}
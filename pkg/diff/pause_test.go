@@ -0,0 +1,174 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	. "github.com/pingcap/check"
+)
+
+var _ = Suite(&testPauseSuite{})
+
+type testPauseSuite struct{}
+
+func (s *testPauseSuite) TestTimeWindow(c *C) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2021, 6, 1, hour, minute, 0, 0, time.Local)
+	}
+
+	window, err := ParseTimeWindow("01:00-06:00")
+	c.Assert(err, IsNil)
+	c.Assert(window.String(), Equals, "01:00-06:00")
+	c.Assert(window.contains(at(0, 59)), IsFalse)
+	c.Assert(window.contains(at(1, 0)), IsTrue)
+	c.Assert(window.contains(at(5, 59)), IsTrue)
+	c.Assert(window.contains(at(6, 0)), IsFalse)
+	c.Assert(window.untilStart(at(0, 30)), Equals, 30*time.Minute)
+	c.Assert(window.untilStart(at(7, 0)), Equals, 18*time.Hour)
+
+	// crosses midnight
+	window, err = ParseTimeWindow("22:00 - 02:00")
+	c.Assert(err, IsNil)
+	c.Assert(window.contains(at(23, 0)), IsTrue)
+	c.Assert(window.contains(at(1, 0)), IsTrue)
+	c.Assert(window.contains(at(12, 0)), IsFalse)
+
+	for _, s := range []string{"01:00", "01:00-25:00", "01:00-01:00", "1am-6am"} {
+		_, err = ParseTimeWindow(s)
+		c.Assert(err, NotNil, Commentf("time window %s", s))
+	}
+}
+
+func (s *testPauseSuite) TestPauser(c *C) {
+	c.Assert((*Pauser)(nil).PauseReason(), Equals, "")
+
+	window, err := ParseTimeWindow("01:00-06:00")
+	c.Assert(err, IsNil)
+	pauser := NewPauser([]*TimeWindow{window})
+	pauser.now = func() time.Time { return time.Date(2021, 6, 1, 2, 0, 0, 0, time.Local) }
+	c.Assert(pauser.PauseReason(), Equals, "")
+	c.Assert(pauser.wait(context.Background()), IsNil)
+
+	pauser.now = func() time.Time { return time.Date(2021, 6, 1, 7, 0, 0, 0, time.Local) }
+	c.Assert(pauser.PauseReason(), Equals, "out of the time windows 01:00-06:00")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	c.Assert(pauser.wait(ctx), ErrorMatches, ".*deadline exceeded.*")
+
+	// paused manually in the time window
+	pauser.now = time.Now
+	pauser.windows = nil
+	pauser.Pause()
+	c.Assert(pauser.PauseReason(), Equals, "paused manually")
+	waitCh := make(chan error)
+	go func() {
+		waitCh <- pauser.wait(context.Background())
+	}()
+	select {
+	case <-waitCh:
+		c.Fatal("should wait until resumed")
+	case <-time.After(10 * time.Millisecond):
+	}
+	pauser.Resume()
+	c.Assert(<-waitCh, IsNil)
+	c.Assert(pauser.PauseReason(), Equals, "")
+}
+
+func (s *testPauseSuite) TestWaitResume(c *C) {
+	ctx := context.Background()
+	store := NewFileCheckpointStore(filepath.Join(c.MkDir(), "checkpoint.json"))
+	c.Assert(store.Init(ctx), IsNil)
+	c.Assert(store.InitTableSummary(ctx, "test", "t", "123"), IsNil)
+
+	tableDiff := &TableDiff{
+		TargetTable:     &TableInstance{Schema: "test", Table: "t"},
+		Pauser:          NewPauser(nil),
+		CheckpointStore: store,
+		summaryInfo:     newTableSummaryInfo(2),
+	}
+	c.Assert(tableDiff.waitResume(ctx), IsNil)
+
+	tableDiff.Pauser.Pause()
+	tableDiff.addRunningChunk(1)
+	waitCh := make(chan error)
+	go func() {
+		waitCh <- tableDiff.waitResume(ctx)
+	}()
+
+	// the running chunk is finished before pause, and then the summary is saved
+	time.Sleep(10 * time.Millisecond)
+	tableDiff.summaryInfo.addSuccessNum()
+	tableDiff.addRunningChunk(-1)
+	for i := 0; ; i++ {
+		summary, err := store.GetTableSummary(ctx, "test", "t")
+		c.Assert(err, IsNil)
+		if summary.SuccessNum == 1 {
+			break
+		}
+		c.Assert(i, Less, 100)
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	select {
+	case <-waitCh:
+		c.Fatal("should wait until resumed")
+	default:
+	}
+	tableDiff.Pauser.Resume()
+	c.Assert(<-waitCh, IsNil)
+}
+
+func (s *testPauseSuite) TestAcquireWorker(c *C) {
+	ctx := context.Background()
+	store := NewFileCheckpointStore(filepath.Join(c.MkDir(), "checkpoint.json"))
+	c.Assert(store.Init(ctx), IsNil)
+	c.Assert(store.InitTableSummary(ctx, "test", "t", "123"), IsNil)
+
+	tableDiff := &TableDiff{
+		TargetTable:     &TableInstance{Schema: "test", Table: "t"},
+		Pauser:          NewPauser(nil),
+		WorkerPool:      NewWorkerPool(1),
+		CheckpointStore: store,
+		summaryInfo:     newTableSummaryInfo(2),
+	}
+	c.Assert(tableDiff.WorkerPool.Acquire(ctx), IsTrue)
+	waitCh := make(chan error)
+	go func() {
+		waitCh <- tableDiff.acquireWorker(ctx)
+	}()
+
+	// paused while waiting for the worker, the worker is put back and waits until resumed
+	time.Sleep(10 * time.Millisecond)
+	tableDiff.Pauser.Pause()
+	tableDiff.WorkerPool.Release()
+	time.Sleep(10 * time.Millisecond)
+	select {
+	case <-waitCh:
+		c.Fatal("should wait until resumed")
+	default:
+	}
+	ctx1, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	c.Assert(tableDiff.WorkerPool.Acquire(ctx1), IsTrue)
+	tableDiff.WorkerPool.Release()
+
+	tableDiff.Pauser.Resume()
+	c.Assert(<-waitCh, IsNil)
+	c.Assert(tableDiff.runningChunks, Equals, 1)
+	tableDiff.releaseWorker()
+	c.Assert(tableDiff.runningChunks, Equals, 0)
+}
@@ -0,0 +1,55 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+)
+
+// WorkerPool limits the num of chunks checked concurrently. it can be shared by the TableDiffs
+// checked concurrently, so the total num of checking chunks of all the tables will not exceed the pool's size.
+type WorkerPool struct {
+	workers chan struct{}
+}
+
+// NewWorkerPool returns a WorkerPool allows size chunks checked concurrently.
+func NewWorkerPool(size int) *WorkerPool {
+	if size <= 0 {
+		size = 1
+	}
+
+	return &WorkerPool{
+		workers: make(chan struct{}, size),
+	}
+}
+
+// Size returns the num of chunks can be checked concurrently.
+func (p *WorkerPool) Size() int {
+	return cap(p.workers)
+}
+
+// Acquire blocks until get a worker, returns false if the context is done.
+func (p *WorkerPool) Acquire(ctx context.Context) bool {
+	select {
+	case p.workers <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Release puts back the worker got by Acquire.
+func (p *WorkerPool) Release() {
+	<-p.workers
+}
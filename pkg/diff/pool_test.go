@@ -0,0 +1,52 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"time"
+
+	. "github.com/pingcap/check"
+)
+
+var _ = Suite(&testPoolSuite{})
+
+type testPoolSuite struct{}
+
+func (s *testPoolSuite) TestWorkerPool(c *C) {
+	pool := NewWorkerPool(0)
+	c.Assert(pool.Size(), Equals, 1)
+
+	pool = NewWorkerPool(2)
+	c.Assert(pool.Size(), Equals, 2)
+
+	ctx := context.Background()
+	c.Assert(pool.Acquire(ctx), IsTrue)
+	c.Assert(pool.Acquire(ctx), IsTrue)
+
+	// no worker left, will return false when the context is done
+	ctx1, cancel1 := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel1()
+	c.Assert(pool.Acquire(ctx1), IsFalse)
+
+	acquired := make(chan bool)
+	go func() {
+		acquired <- pool.Acquire(ctx)
+	}()
+	pool.Release()
+	c.Assert(<-acquired, IsTrue)
+
+	pool.Release()
+	pool.Release()
+}
@@ -0,0 +1,143 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Progress aggregates the check progress of tables, can be shared by the TableDiffs checking concurrently.
+// all the methods can be called on a nil Progress.
+type Progress struct {
+	sync.RWMutex
+
+	startTime time.Time
+
+	totalTableNum int
+	// tables whose chunks are split or loaded from checkpoint
+	startedTableNum int
+	doneTableNum    int
+
+	totalChunkNum int64
+	doneChunkNum  int64
+
+	// the num of rows checked in target
+	rowNum int64
+}
+
+// ProgressInfo is the snapshot of the check progress.
+type ProgressInfo struct {
+	TotalTableNum int   `json:"total-table-num"`
+	DoneTableNum  int   `json:"done-table-num"`
+	TotalChunkNum int64 `json:"total-chunk-num"`
+	DoneChunkNum  int64 `json:"done-chunk-num"`
+	RowNum        int64 `json:"row-num"`
+
+	Elapsed       time.Duration `json:"elapsed"`
+	RowsPerSecond float64       `json:"rows-per-second"`
+	// the estimated time to finish the check, is 0 if can't estimate it
+	ETA time.Duration `json:"eta"`
+}
+
+// NewProgress returns a Progress to check totalTableNum tables.
+func NewProgress(totalTableNum int) *Progress {
+	return &Progress{
+		startTime:     time.Now(),
+		totalTableNum: totalTableNum,
+	}
+}
+
+// addChunks is called after the table's chunks are split or loaded from checkpoint.
+func (p *Progress) addChunks(num int64) {
+	if p == nil {
+		return
+	}
+
+	p.Lock()
+	p.startedTableNum++
+	p.totalChunkNum += num
+	p.Unlock()
+}
+
+// doneChunk is called after a chunk is checked, ignored or skipped.
+func (p *Progress) doneChunk(rowNum int64) {
+	if p == nil {
+		return
+	}
+
+	p.Lock()
+	p.doneChunkNum++
+	p.rowNum += rowNum
+	p.Unlock()
+}
+
+// DoneTable is called after the table is checked, no matter it is equal or not.
+func (p *Progress) DoneTable() {
+	if p == nil {
+		return
+	}
+
+	p.Lock()
+	p.doneTableNum++
+	p.Unlock()
+}
+
+// Info returns the snapshot of the progress.
+func (p *Progress) Info() ProgressInfo {
+	if p == nil {
+		return ProgressInfo{}
+	}
+
+	p.RLock()
+	defer p.RUnlock()
+
+	info := ProgressInfo{
+		TotalTableNum: p.totalTableNum,
+		DoneTableNum:  p.doneTableNum,
+		TotalChunkNum: p.totalChunkNum,
+		DoneChunkNum:  p.doneChunkNum,
+		RowNum:        p.rowNum,
+		Elapsed:       time.Since(p.startTime),
+	}
+
+	if info.Elapsed > 0 {
+		info.RowsPerSecond = float64(info.RowNum) / info.Elapsed.Seconds()
+	}
+
+	// the chunks of the tables not started yet are unknown, estimate them by the average chunk num of the started tables
+	estimatedChunkNum := p.totalChunkNum
+	if p.startedTableNum > 0 && p.startedTableNum < p.totalTableNum {
+		estimatedChunkNum = p.totalChunkNum * int64(p.totalTableNum) / int64(p.startedTableNum)
+	}
+	if p.doneChunkNum > 0 && estimatedChunkNum > p.doneChunkNum {
+		info.ETA = time.Duration(float64(info.Elapsed) * float64(estimatedChunkNum-p.doneChunkNum) / float64(p.doneChunkNum))
+	}
+
+	return info
+}
+
+// String returns the summary of the progress.
+func (info ProgressInfo) String() string {
+	eta := "unknown"
+	if info.ETA > 0 {
+		eta = info.ETA.Round(time.Second).String()
+	} else if info.TotalTableNum == info.DoneTableNum {
+		eta = "0s"
+	}
+
+	return fmt.Sprintf("tables: %d/%d, chunks: %d/%d, rows: %d, rows/sec: %.2f, elapsed: %s, eta: %s",
+		info.DoneTableNum, info.TotalTableNum, info.DoneChunkNum, info.TotalChunkNum, info.RowNum, info.RowsPerSecond, info.Elapsed.Round(time.Second), eta)
+}
@@ -0,0 +1,71 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"time"
+
+	. "github.com/pingcap/check"
+)
+
+var _ = Suite(&testProgressSuite{})
+
+type testProgressSuite struct{}
+
+func (s *testProgressSuite) TestProgress(c *C) {
+	// nil progress is valid
+	var nilProgress *Progress
+	nilProgress.addChunks(1)
+	nilProgress.doneChunk(1)
+	nilProgress.DoneTable()
+	c.Assert(nilProgress.Info(), DeepEquals, ProgressInfo{})
+
+	progress := NewProgress(4)
+	progress.startTime = time.Now().Add(-10 * time.Second)
+	info := progress.Info()
+	c.Assert(info.ETA, Equals, time.Duration(0))
+	c.Assert(info.String(), Matches, "tables: 0/4, chunks: 0/0, rows: 0, .* eta: unknown")
+
+	// two tables are started, and one of them is done
+	progress.addChunks(6)
+	progress.addChunks(4)
+	for i := 0; i < 5; i++ {
+		progress.doneChunk(1000)
+	}
+	progress.DoneTable()
+
+	info = progress.Info()
+	c.Assert(info.TotalTableNum, Equals, 4)
+	c.Assert(info.DoneTableNum, Equals, 1)
+	c.Assert(info.TotalChunkNum, Equals, int64(10))
+	c.Assert(info.DoneChunkNum, Equals, int64(5))
+	c.Assert(info.RowNum, Equals, int64(5000))
+	c.Assert(info.RowsPerSecond > 400 && info.RowsPerSecond <= 500, IsTrue)
+	// estimate 20 chunks in 4 tables, 15 chunks left, and need 2s to check a chunk
+	c.Assert(info.ETA > 29*time.Second && info.ETA < 31*time.Second, IsTrue)
+	c.Assert(info.String(), Matches, "tables: 1/4, chunks: 5/10, rows: 5000, rows/sec: .*, elapsed: 10s, eta: 30s")
+
+	// all the tables are done
+	progress.addChunks(0)
+	progress.addChunks(0)
+	for i := 0; i < 5; i++ {
+		progress.doneChunk(0)
+	}
+	for i := 0; i < 3; i++ {
+		progress.DoneTable()
+	}
+	info = progress.Info()
+	c.Assert(info.ETA, Equals, time.Duration(0))
+	c.Assert(info.String(), Matches, "tables: 4/4, chunks: 10/10, .* eta: 0s")
+}
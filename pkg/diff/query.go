@@ -0,0 +1,110 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+)
+
+// keyIndexName is the name of the index made up of the key columns.
+const keyIndexName = "PRIMARY"
+
+// from returns the table expression used in the FROM clause, is the derived table of the query if the query is set.
+func (t *TableInstance) from() string {
+	if len(t.Query) != 0 {
+		return dbutil.DerivedTable(t.Query)
+	}
+	return dbutil.TableName(t.Schema, t.Table)
+}
+
+// withKeyColumns returns a copy of the table info whose primary key is the key columns. the rows are ordered and identified
+// by the primary key, so the key columns are used when the table has no primary key or unique key, and the query shares the
+// target's table info. the key of the table is never overridden, returns error if the table has primary key or unique key.
+func withKeyColumns(tableInfo *model.TableInfo, keyColumns []string) (*model.TableInfo, error) {
+	if len(keyColumns) == 0 {
+		return tableInfo, nil
+	}
+	if hasUniqueIndex(tableInfo) {
+		return nil, errors.NotSupportedf("key columns on table %s with primary key or unique key, the rows are identified by the table's key. It's", tableInfo.Name.O)
+	}
+
+	keyIndex := &model.IndexInfo{
+		Name:    model.NewCIStr(keyIndexName),
+		Primary: true,
+		State:   model.StatePublic,
+		Unique:  true,
+	}
+	for _, name := range keyColumns {
+		col := dbutil.FindColumnByName(tableInfo.Columns, name)
+		if col == nil {
+			return nil, errors.NotFoundf("key column %s in table %s", name, tableInfo.Name.O)
+		}
+		keyIndex.Columns = append(keyIndex.Columns, &model.IndexColumn{
+			Name:   col.Name,
+			Offset: col.Offset,
+		})
+	}
+
+	newTableInfo := *tableInfo
+	newTableInfo.Indices = append(append(make([]*model.IndexInfo, 0, len(tableInfo.Indices)+1), keyIndex), tableInfo.Indices...)
+
+	return &newTableInfo, nil
+}
+
+// checkQueryColumns checks the columns of the query are same as the target table's, the ignored columns are not checked.
+// the query has no table info, its rows are selected by the target table's columns.
+func checkQueryColumns(ctx context.Context, table *TableInstance, targetInfo *model.TableInfo, ignoreColumns []string) error {
+	columns, err := dbutil.GetColumnsFrom(ctx, table.Conn, table.from())
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	isIgnored := func(name string) bool {
+		for _, column := range ignoreColumns {
+			if strings.EqualFold(column, name) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var lackColumns, extraColumns []string
+	for _, col := range targetInfo.Columns {
+		found := false
+		for _, name := range columns {
+			if strings.EqualFold(name, col.Name.O) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			lackColumns = append(lackColumns, col.Name.O)
+		}
+	}
+	for _, name := range columns {
+		if dbutil.FindColumnByName(targetInfo.Columns, name) == nil && !isIgnored(name) {
+			extraColumns = append(extraColumns, name)
+		}
+	}
+	if len(lackColumns) != 0 || len(extraColumns) != 0 {
+		return errors.NotValidf("columns of query %s, lack columns %v and extra columns %v compared with target table %s, the", table.Query, lackColumns, extraColumns, targetInfo.Name.O)
+	}
+
+	return nil
+}
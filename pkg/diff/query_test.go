@@ -0,0 +1,124 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	. "github.com/pingcap/check"
+	"github.com/pingcap/parser"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+)
+
+var _ = Suite(&testQuerySuite{})
+
+type testQuerySuite struct{}
+
+func (s *testQuerySuite) TestWithKeyColumns(c *C) {
+	tableInfo, err := dbutil.GetTableInfoBySQL("CREATE TABLE `t` (`id` int, `day` date, `cnt` bigint, key `k_day`(`day`))", parser.New())
+	c.Assert(err, IsNil)
+
+	newTableInfo, err := withKeyColumns(tableInfo, nil)
+	c.Assert(err, IsNil)
+	c.Assert(newTableInfo, Equals, tableInfo)
+
+	newTableInfo, err = withKeyColumns(tableInfo, []string{"DAY", "cnt"})
+	c.Assert(err, IsNil)
+	keys, _ := dbutil.SelectUniqueOrderKey(newTableInfo)
+	c.Assert(keys, DeepEquals, []string{"day", "cnt"})
+	c.Assert(newTableInfo.Indices, HasLen, 2)
+	c.Assert(newTableInfo.Indices[1].Name.O, Equals, "k_day")
+	// the origin table info is not changed
+	c.Assert(hasUniqueIndex(tableInfo), IsFalse)
+
+	_, err = withKeyColumns(tableInfo, []string{"name"})
+	c.Assert(err, ErrorMatches, ".*not found.*")
+
+	// the primary key of table is not overridden
+	tableInfo, err = dbutil.GetTableInfoBySQL("CREATE TABLE `t` (`id` int, `day` date, primary key(`id`))", parser.New())
+	c.Assert(err, IsNil)
+	_, err = withKeyColumns(tableInfo, []string{"day"})
+	c.Assert(err, ErrorMatches, ".*key columns on table t with primary key or unique key.*not supported")
+}
+
+func (s *testQuerySuite) TestCheckQueryColumns(c *C) {
+	tableInfo, err := dbutil.GetTableInfoBySQL("CREATE TABLE `t` (`day` date, `cnt` bigint)", parser.New())
+	c.Assert(err, IsNil)
+	db, mock, err := sqlmock.New()
+	c.Assert(err, IsNil)
+	table := &TableInstance{Conn: db, Query: "SELECT `day`, COUNT(*) AS `cnt`, MAX(`ts`) AS `ts` FROM `test`.`orders` GROUP BY `day`"}
+
+	// the ignored column in query is not checked
+	mock.ExpectQuery("SELECT \\* FROM \\(SELECT `day`.*\\) AS `derived_table` LIMIT 0").WillReturnRows(sqlmock.NewRows([]string{"DAY", "cnt", "ts"}))
+	c.Assert(checkQueryColumns(context.Background(), table, tableInfo, []string{"ts"}), IsNil)
+
+	mock.ExpectQuery("SELECT \\* FROM").WillReturnRows(sqlmock.NewRows([]string{"day", "ts"}))
+	err = checkQueryColumns(context.Background(), table, tableInfo, nil)
+	c.Assert(err, ErrorMatches, ".*lack columns \\[cnt\\] and extra columns \\[ts\\] compared with target table t.*")
+
+	c.Assert(mock.ExpectationsWereMet(), IsNil)
+}
+
+func (s *testQuerySuite) TestCompareQuery(c *C) {
+	tableInfo, err := dbutil.GetTableInfoBySQL("CREATE TABLE `t` (`day` date, `cnt` bigint)", parser.New())
+	c.Assert(err, IsNil)
+	tableInfo, err = withKeyColumns(tableInfo, []string{"day"})
+	c.Assert(err, IsNil)
+
+	sourceDB, sourceMock, err := sqlmock.New()
+	c.Assert(err, IsNil)
+	targetDB, targetMock, err := sqlmock.New()
+	c.Assert(err, IsNil)
+
+	query := "SELECT `day`, COUNT(*) AS `cnt` FROM `test`.`orders` GROUP BY `day`"
+	tableDiff := &TableDiff{
+		SourceTables: []*TableInstance{{Conn: sourceDB, Query: query, info: tableInfo}},
+		TargetTable:  &TableInstance{Conn: targetDB, Schema: "test", Table: "t", info: tableInfo},
+		Range:        "TRUE",
+	}
+	chunk := NewChunkRange()
+	chunk.addBound(&Bound{Column: "day", Lower: "2021-01-01", HasLower: true})
+	chunk.Where, chunk.Args = chunk.toString("")
+
+	// the query is wrapped as a derived table
+	sourceMock.ExpectQuery("SELECT COUNT\\(1\\) cnt FROM \\(SELECT `day`, COUNT\\(\\*\\) AS `cnt` FROM `test`.`orders` GROUP BY `day`\\) AS `derived_table` WHERE .*`day` > \\?.*").
+		WithArgs("2021-01-01").WillReturnRows(sqlmock.NewRows([]string{"cnt"}).AddRow(10))
+	targetMock.ExpectQuery("SELECT COUNT\\(1\\) cnt FROM `test`.`t` WHERE .*`day` > \\?.*").
+		WithArgs("2021-01-01").WillReturnRows(sqlmock.NewRows([]string{"cnt"}).AddRow(10))
+	tableDiff.summaryInfo = newTableSummaryInfo(1)
+	equal, _, err := tableDiff.compareRowCount(context.Background(), chunk)
+	c.Assert(err, IsNil)
+	c.Assert(equal, IsTrue)
+
+	sourceMock.ExpectQuery("SELECT BIT_XOR.* FROM \\(SELECT `day`.*\\) AS `derived_table` WHERE").
+		WillReturnRows(sqlmock.NewRows([]string{"checksum", "cnt"}).AddRow(100, 10))
+	targetMock.ExpectQuery("SELECT BIT_XOR.* FROM `test`.`t` WHERE").
+		WillReturnRows(sqlmock.NewRows([]string{"checksum", "cnt"}).AddRow(101, 10))
+	equal, rowNum, err := tableDiff.compareChecksum(context.Background(), chunk)
+	c.Assert(err, IsNil)
+	c.Assert(equal, IsFalse)
+	c.Assert(rowNum, Equals, int64(10))
+
+	// the rows of query are ordered by the key columns
+	sourceMock.ExpectQuery("SELECT .* `day`, `cnt` FROM \\(SELECT `day`.*\\) AS `derived_table` WHERE .* ORDER BY `day`").
+		WillReturnRows(sqlmock.NewRows([]string{"day", "cnt"}).AddRow("2021-01-02", 5))
+	rows, _, err := getChunkRows(context.Background(), sourceDB, tableDiff.SourceTables[0].from(), tableInfo, nil, chunk.Where, []interface{}{"2021-01-01"}, "")
+	c.Assert(err, IsNil)
+	c.Assert(rows.Close(), IsNil)
+
+	for _, mock := range []sqlmock.Sqlmock{sourceMock, targetMock} {
+		c.Assert(mock.ExpectationsWereMet(), IsNil)
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultFixSQLBatchSize is the default num of fix sqls executed in one transaction
+	defaultFixSQLBatchSize = 100
+)
+
+// fixSQLCollector collects the fix sqls by chunk, they are applied on target after the chunk is checked.
+type fixSQLCollector struct {
+	sync.Mutex
+
+	chunks map[int][]string
+	// the num of different rows of the chunks, a row may be fixed by more than one sql
+	rowNums map[int]int64
+
+	// the num of different rows fixed on target
+	appliedNum int64
+
+	// the first error met when apply fix sqls, will not apply fix sqls any more after met error
+	err error
+}
+
+func newFixSQLCollector() *fixSQLCollector {
+	return &fixSQLCollector{
+		chunks:  make(map[int][]string),
+		rowNums: make(map[int]int64),
+	}
+}
+
+func (c *fixSQLCollector) add(chunk *ChunkRange, sql string) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.chunks[chunk.ID] = append(c.chunks[chunk.ID], sql)
+}
+
+func (c *fixSQLCollector) addRow(chunk *ChunkRange) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.rowNums[chunk.ID]++
+}
+
+// take removes the fix sqls of the chunk and returns them with the num of different rows they fix.
+func (c *fixSQLCollector) take(chunk *ChunkRange) ([]string, int64) {
+	c.Lock()
+	defer c.Unlock()
+
+	sqls, rowNum := c.chunks[chunk.ID], c.rowNums[chunk.ID]
+	delete(c.chunks, chunk.ID)
+	delete(c.rowNums, chunk.ID)
+	return sqls, rowNum
+}
+
+// reserve checks the different rows can be fixed, the num of fixed rows can't be greater than maxNum if it is not 0.
+func (c *fixSQLCollector) reserve(table string, num, maxNum int64) error {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.err != nil {
+		return c.err
+	}
+
+	if maxNum > 0 && c.appliedNum+num > maxNum {
+		c.err = errors.Errorf("need to fix %d different rows in table %s, exceeds the max fix row num %d, please check and fix the data manually", c.appliedNum+num, table, maxNum)
+		return c.err
+	}
+	c.appliedNum += num
+
+	return nil
+}
+
+func (c *fixSQLCollector) setErr(err error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+func (c *fixSQLCollector) getErr() error {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.err
+}
+
+// collectFixSQL saves the fix sql if need to apply fix sqls on target.
+func (t *TableDiff) collectFixSQL(chunk *ChunkRange, sql string) {
+	if !t.ApplyFixSQL || t.fixSQLs == nil {
+		return
+	}
+
+	t.fixSQLs.add(chunk, sql)
+}
+
+// collectDiffRow counts the different row of the chunk if need to apply fix sqls on target, the rows are limited by MaxFixRowNum.
+func (t *TableDiff) collectDiffRow(chunk *ChunkRange) {
+	if !t.ApplyFixSQL || t.fixSQLs == nil {
+		return
+	}
+
+	t.fixSQLs.addRow(chunk)
+}
+
+// executeFixSQLs executes the fix sqls in one transaction, the transaction is rolled back and retried if meets retryable error.
+func (t *TableDiff) executeFixSQLs(ctx context.Context, sqls []string) (err error) {
+	for i := 0; i < dbutil.DefaultRetryTime; i++ {
+		err = dbutil.ExecuteSQLs(ctx, t.TargetTable.Conn, sqls, make([][]interface{}, len(sqls)))
+		if err == nil || !dbutil.IsRetryableError(err) || i == dbutil.DefaultRetryTime-1 {
+			return errors.Trace(err)
+		}
+
+		retryCounter.WithLabelValues("fix_sql").Inc()
+		log.Warn("apply fix sqls failed, will try again", zap.Int("sql num", len(sqls)), zap.Error(err))
+		select {
+		case <-ctx.Done():
+			return errors.Trace(ctx.Err())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	return errors.Trace(err)
+}
+
+// repairChunk executes the fix sqls of the not equal chunk on target table in batched transactions, and then checks the
+// chunk again, returns whether the chunk is repaired, which means the chunk's data is equal after fixed.
+func (t *TableDiff) repairChunk(ctx context.Context, chunk *ChunkRange) (bool, error) {
+	table := dbutil.TableName(t.TargetTable.Schema, t.TargetTable.Table)
+	sqls, rowNum := t.fixSQLs.take(chunk)
+	if len(sqls) == 0 {
+		return false, nil
+	}
+
+	if t.FixSQLDryRun {
+		log.Info("[dry-run] fix sqls will be applied", zap.String("table", table), zap.Int("chunk", chunk.ID), zap.Strings("sqls", sqls))
+		return false, nil
+	}
+
+	if err := t.fixSQLs.reserve(table, rowNum, t.MaxFixRowNum); err != nil {
+		log.Warn("skip applying fix sqls", zap.String("table", table), zap.Int("chunk", chunk.ID), zap.Error(err))
+		return false, nil
+	}
+
+	batchSize := t.FixSQLBatchSize
+	if batchSize <= 0 {
+		batchSize = defaultFixSQLBatchSize
+	}
+
+	for i := 0; i < len(sqls); i += batchSize {
+		end := i + batchSize
+		if end > len(sqls) {
+			end = len(sqls)
+		}
+
+		if err := t.executeFixSQLs(ctx, sqls[i:end]); err != nil {
+			err = errors.Annotatef(err, "apply fix sqls on table %s chunk %d", table, chunk.ID)
+			t.fixSQLs.setErr(err)
+			return false, err
+		}
+	}
+	t.summaryInfo.addFixedRowNum(rowNum)
+
+	// check the chunk again in the same way, the data should be equal after fixed
+	if err := t.waitChunk(ctx); err != nil {
+		return false, errors.Trace(err)
+	}
+	chunk.State = repairingState
+	equal, checkedRowNum, err := t.compareChunk(ctx, chunk)
+	if err != nil {
+		return false, errors.Annotatef(err, "check table %s chunk %d after fixed", table, chunk.ID)
+	}
+	if err = t.waitRows(ctx, checkedRowNum); err != nil {
+		return false, errors.Trace(err)
+	}
+	if !equal {
+		log.Warn("chunk's data is still not equal after fixed", zap.String("table", table), zap.String("chunk", chunk.String()))
+		return false, nil
+	}
+
+	log.Info("chunk's data is repaired", zap.String("table", table), zap.Int("chunk", chunk.ID), zap.Int("fix sql num", len(sqls)), zap.Int64("fixed row num", rowNum))
+	return true, nil
+}
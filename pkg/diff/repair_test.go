@@ -0,0 +1,121 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	. "github.com/pingcap/check"
+	"github.com/pingcap/parser"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+)
+
+var _ = Suite(&testRepairSuite{})
+
+type testRepairSuite struct{}
+
+func (s *testRepairSuite) TestRepairChunk(c *C) {
+	createTableSQL := "CREATE TABLE `test`.`atest` (`id` int(24), `name` varchar(24), primary key(`id`))"
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	c.Assert(err, IsNil)
+
+	sourceDB, sourceMock, err := sqlmock.New()
+	c.Assert(err, IsNil)
+	targetDB, targetMock, err := sqlmock.New()
+	c.Assert(err, IsNil)
+
+	tableDiff := &TableDiff{
+		SourceTables:     []*TableInstance{{Conn: sourceDB, Schema: "test", Table: "atest", info: tableInfo}},
+		TargetTable:      &TableInstance{Conn: targetDB, Schema: "test", Table: "atest", InstanceID: "target", info: tableInfo},
+		UseChecksum:      true,
+		ChecksumStrategy: CRC32ChecksumStrategy,
+		ApplyFixSQL:      true,
+		MaxFixRowNum:     2,
+		FixSQLBatchSize:  1,
+		fixSQLs:          newFixSQLCollector(),
+		summaryInfo:      newTableSummaryInfo(2),
+	}
+
+	chunk1 := &ChunkRange{ID: 1, Where: "TRUE", State: checkingState}
+	chunk2 := &ChunkRange{ID: 2, Where: "TRUE", State: checkingState}
+	// the different row of chunk1 is fixed by two sqls
+	collect := func() {
+		tableDiff.collectFixSQL(chunk1, "DELETE FROM `test`.`atest` WHERE `id` = 1 AND `name` = 'b';")
+		tableDiff.collectFixSQL(chunk1, "REPLACE INTO `test`.`atest`(`id`,`name`) VALUES (1,'a');")
+		tableDiff.collectDiffRow(chunk1)
+		tableDiff.collectFixSQL(chunk2, "DELETE FROM `test`.`atest` WHERE `id` = 3;")
+		tableDiff.collectFixSQL(chunk2, "DELETE FROM `test`.`atest` WHERE `id` = 4;")
+		tableDiff.collectDiffRow(chunk2)
+		tableDiff.collectDiffRow(chunk2)
+	}
+
+	// dry run will not execute the sqls
+	collect()
+	tableDiff.FixSQLDryRun = true
+	repaired, err := tableDiff.repairChunk(context.Background(), chunk1)
+	c.Assert(err, IsNil)
+	c.Assert(repaired, IsFalse)
+	sqls, rowNum := tableDiff.fixSQLs.take(chunk1)
+	c.Assert(sqls, HasLen, 0)
+	c.Assert(rowNum, Equals, int64(0))
+	c.Assert(targetMock.ExpectationsWereMet(), IsNil)
+
+	// one sql in a transaction, and then check the chunk again
+	tableDiff.FixSQLDryRun = false
+	tableDiff.fixSQLs = newFixSQLCollector()
+	collect()
+	targetMock.ExpectBegin()
+	targetMock.ExpectExec("DELETE FROM").WillReturnResult(sqlmock.NewResult(0, 1))
+	targetMock.ExpectCommit()
+	targetMock.ExpectBegin()
+	targetMock.ExpectExec("REPLACE INTO").WillReturnResult(sqlmock.NewResult(0, 1))
+	targetMock.ExpectCommit()
+	targetMock.ExpectQuery("SELECT BIT_XOR").WillReturnRows(sqlmock.NewRows([]string{"checksum", "cnt"}).AddRow(123, 1))
+	sourceMock.ExpectQuery("SELECT BIT_XOR").WillReturnRows(sqlmock.NewRows([]string{"checksum", "cnt"}).AddRow(123, 1))
+
+	repaired, err = tableDiff.repairChunk(context.Background(), chunk1)
+	c.Assert(err, IsNil)
+	c.Assert(repaired, IsTrue)
+	c.Assert(targetMock.ExpectationsWereMet(), IsNil)
+	c.Assert(sourceMock.ExpectationsWereMet(), IsNil)
+
+	// the fixed rows are counted by the different rows instead of the sqls
+	c.Assert(tableDiff.Stats().FixedRowNum, Equals, int64(1))
+
+	// exceeds the max fix row num, the sqls are not applied
+	repaired, err = tableDiff.repairChunk(context.Background(), chunk2)
+	c.Assert(err, IsNil)
+	c.Assert(repaired, IsFalse)
+	c.Assert(tableDiff.fixSQLs.getErr(), ErrorMatches, "need to fix 3 different rows in table `test`.`atest`, exceeds the max fix row num 2.*")
+	c.Assert(targetMock.ExpectationsWereMet(), IsNil)
+	c.Assert(tableDiff.Stats().FixedRowNum, Equals, int64(1))
+}
+
+func (s *testRepairSuite) TestRepairedTableSummary(c *C) {
+	summaryInfo := newTableSummaryInfo(2)
+	summaryInfo.addSuccessNum()
+	c.Assert(summaryInfo.toTableSummary().State, Equals, checkingState)
+
+	// the table is not equal even if all the different chunks are repaired
+	summaryInfo.addRepairedNum()
+	summaryInfo.addFixedRowNum(3)
+	summary := summaryInfo.toTableSummary()
+	c.Assert(summary.State, Equals, failedState)
+	c.Assert(summary.SuccessNum, Equals, int64(1))
+	c.Assert(summary.FailedNum, Equals, int64(0))
+	repairedNum, fixedRowNum := summaryInfo.getRepairNum()
+	c.Assert(repairedNum, Equals, int64(1))
+	c.Assert(fixedRowNum, Equals, int64(3))
+}
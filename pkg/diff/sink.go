@@ -0,0 +1,204 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+)
+
+const (
+	// DiffTypeInsert means the row is lacked in target, should be inserted
+	DiffTypeInsert = "insert"
+	// DiffTypeDelete means the row is superfluous in target, should be deleted
+	DiffTypeDelete = "delete"
+	// DiffTypeUpdate means the row exists in both source and target, but some columns are different
+	DiffTypeUpdate = "update"
+
+	// CSVSinkFormat means write the different rows in csv format
+	CSVSinkFormat = "csv"
+	// NDJSONSinkFormat means write the different rows in newline delimited json format
+	NDJSONSinkFormat = "ndjson"
+)
+
+// RowDiff saves a different row found when comparing rows.
+// the column's value is nil if it is NULL.
+type RowDiff struct {
+	Schema  string `json:"schema"`
+	Table   string `json:"table"`
+	ChunkID int    `json:"chunk-id"`
+	Type    string `json:"type"`
+
+	// the key columns' value, used to identify the row
+	Keys map[string]interface{} `json:"keys"`
+
+	// row's value in source, is nil when type is delete
+	Source map[string]interface{} `json:"source,omitempty"`
+	// row's value in target, is nil when type is insert
+	Target map[string]interface{} `json:"target,omitempty"`
+
+	// the columns with different value, only valid when type is update
+	DiffColumns []string `json:"diff-columns,omitempty"`
+}
+
+// DiffSink saves the different rows found when comparing rows.
+// the implement should be safe for concurrent use.
+type DiffSink interface {
+	// Write saves one different row.
+	Write(rowDiff *RowDiff) error
+	// Close flushes the saved rows and releases resources.
+	Close() error
+}
+
+// NewDiffSink returns a DiffSink writes different rows to w in the format.
+func NewDiffSink(w io.Writer, format string) (DiffSink, error) {
+	switch format {
+	case CSVSinkFormat:
+		return NewCSVDiffSink(w), nil
+	case NDJSONSinkFormat:
+		return NewNDJSONDiffSink(w), nil
+	default:
+		return nil, errors.NotSupportedf("diff sink format %s", format)
+	}
+}
+
+// csvDiffSink writes different rows in csv format, the key, source and target values are encoded in json.
+type csvDiffSink struct {
+	sync.Mutex
+
+	writer        *csv.Writer
+	headerWritten bool
+}
+
+// NewCSVDiffSink returns a DiffSink writes different rows to w in csv format.
+func NewCSVDiffSink(w io.Writer) DiffSink {
+	return &csvDiffSink{
+		writer: csv.NewWriter(w),
+	}
+}
+
+// Write implements DiffSink's Write function.
+func (s *csvDiffSink) Write(rowDiff *RowDiff) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if !s.headerWritten {
+		err := s.writer.Write([]string{"schema", "table", "chunk_id", "type", "keys", "source", "target", "diff_columns"})
+		if err != nil {
+			return errors.Trace(err)
+		}
+		s.headerWritten = true
+	}
+
+	record := []string{rowDiff.Schema, rowDiff.Table, strconv.Itoa(rowDiff.ChunkID), rowDiff.Type}
+	for _, values := range []map[string]interface{}{rowDiff.Keys, rowDiff.Source, rowDiff.Target} {
+		if values == nil {
+			record = append(record, "")
+			continue
+		}
+
+		valuesBytes, err := json.Marshal(values)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		record = append(record, string(valuesBytes))
+	}
+	record = append(record, strings.Join(rowDiff.DiffColumns, ","))
+
+	return errors.Trace(s.writer.Write(record))
+}
+
+// Close implements DiffSink's Close function.
+func (s *csvDiffSink) Close() error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.writer.Flush()
+	return errors.Trace(s.writer.Error())
+}
+
+// ndjsonDiffSink writes different rows in newline delimited json format.
+type ndjsonDiffSink struct {
+	sync.Mutex
+
+	encoder *json.Encoder
+}
+
+// NewNDJSONDiffSink returns a DiffSink writes different rows to w in newline delimited json format.
+func NewNDJSONDiffSink(w io.Writer) DiffSink {
+	return &ndjsonDiffSink{
+		encoder: json.NewEncoder(w),
+	}
+}
+
+// Write implements DiffSink's Write function.
+func (s *ndjsonDiffSink) Write(rowDiff *RowDiff) error {
+	s.Lock()
+	defer s.Unlock()
+
+	return errors.Trace(s.encoder.Encode(rowDiff))
+}
+
+// Close implements DiffSink's Close function.
+func (s *ndjsonDiffSink) Close() error {
+	return nil
+}
+
+// rowToValues converts the row's data to a map used in RowDiff, only contains the given columns.
+func rowToValues(row map[string]*dbutil.ColumnData, cols []*model.ColumnInfo) map[string]interface{} {
+	if row == nil {
+		return nil
+	}
+
+	values := make(map[string]interface{}, len(cols))
+	for _, col := range cols {
+		data, ok := row[col.Name.O]
+		if !ok || data.IsNull {
+			values[col.Name.O] = nil
+			continue
+		}
+		values[col.Name.O] = string(data.Data)
+	}
+
+	return values
+}
+
+// newRowDiff returns a RowDiff for the different row, sourceRow is nil if type is delete, targetRow is nil if type is insert.
+func newRowDiff(tp string, schema, table string, chunkID int, sourceRow, targetRow map[string]*dbutil.ColumnData, tableInfo *model.TableInfo, keyCols []*model.ColumnInfo, diffColumns []string) *RowDiff {
+	rowDiff := &RowDiff{
+		Schema:      schema,
+		Table:       table,
+		ChunkID:     chunkID,
+		Type:        tp,
+		Source:      rowToValues(sourceRow, tableInfo.Columns),
+		Target:      rowToValues(targetRow, tableInfo.Columns),
+		DiffColumns: diffColumns,
+	}
+
+	if sourceRow != nil {
+		rowDiff.Keys = rowToValues(sourceRow, keyCols)
+	} else {
+		rowDiff.Keys = rowToValues(targetRow, keyCols)
+	}
+
+	return rowDiff
+}
@@ -0,0 +1,80 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"bytes"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/parser"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+)
+
+var _ = Suite(&testSinkSuite{})
+
+type testSinkSuite struct{}
+
+func (s *testSinkSuite) TestDiffSink(c *C) {
+	createTableSQL := "CREATE TABLE `test`.`atest` (`id` int(24), `name` varchar(24), `age` int(11), primary key(`id`))"
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	c.Assert(err, IsNil)
+	_, keyCols := dbutil.SelectUniqueOrderKey(tableInfo)
+
+	sourceRow := map[string]*dbutil.ColumnData{
+		"id":   {Data: []byte("1"), IsNull: false},
+		"name": {Data: []byte("xxx"), IsNull: false},
+		"age":  {Data: []byte("10"), IsNull: false},
+	}
+	targetRow := map[string]*dbutil.ColumnData{
+		"id":   {Data: []byte("1"), IsNull: false},
+		"name": {Data: nil, IsNull: true},
+		"age":  {Data: []byte("10"), IsNull: false},
+	}
+
+	_, _, diffColumns, err := compareData(sourceRow, targetRow, keyCols, nil)
+	c.Assert(err, IsNil)
+	updateDiff := newRowDiff(DiffTypeUpdate, "test", "atest", 3, sourceRow, targetRow, tableInfo, keyCols, diffColumns)
+	c.Assert(updateDiff.Keys, DeepEquals, map[string]interface{}{"id": "1"})
+	c.Assert(updateDiff.DiffColumns, DeepEquals, []string{"name"})
+	c.Assert(updateDiff.Target["name"], IsNil)
+
+	deleteDiff := newRowDiff(DiffTypeDelete, "test", "atest", 3, nil, targetRow, tableInfo, keyCols, nil)
+	c.Assert(deleteDiff.Keys, DeepEquals, map[string]interface{}{"id": "1"})
+	c.Assert(deleteDiff.Source, IsNil)
+	c.Assert(deleteDiff.DiffColumns, IsNil)
+
+	var buf bytes.Buffer
+	sink, err := NewDiffSink(&buf, NDJSONSinkFormat)
+	c.Assert(err, IsNil)
+	c.Assert(sink.Write(updateDiff), IsNil)
+	c.Assert(sink.Write(deleteDiff), IsNil)
+	c.Assert(sink.Close(), IsNil)
+	c.Assert(buf.String(), Equals, `{"schema":"test","table":"atest","chunk-id":3,"type":"update","keys":{"id":"1"},"source":{"age":"10","id":"1","name":"xxx"},"target":{"age":"10","id":"1","name":null},"diff-columns":["name"]}
+{"schema":"test","table":"atest","chunk-id":3,"type":"delete","keys":{"id":"1"},"target":{"age":"10","id":"1","name":null}}
+`)
+
+	buf.Reset()
+	sink, err = NewDiffSink(&buf, CSVSinkFormat)
+	c.Assert(err, IsNil)
+	c.Assert(sink.Write(updateDiff), IsNil)
+	c.Assert(sink.Write(deleteDiff), IsNil)
+	c.Assert(sink.Close(), IsNil)
+	c.Assert(buf.String(), Equals, `schema,table,chunk_id,type,keys,source,target,diff_columns
+test,atest,3,update,"{""id"":""1""}","{""age"":""10"",""id"":""1"",""name"":""xxx""}","{""age"":""10"",""id"":""1"",""name"":null}",name
+test,atest,3,delete,"{""id"":""1""}",,"{""age"":""10"",""id"":""1"",""name"":null}",
+`)
+
+	_, err = NewDiffSink(&buf, "xml")
+	c.Assert(err, NotNil)
+}
@@ -121,7 +121,7 @@ func (s *testSpliterSuite) TestSplitRangeByRandom(c *C) {
 		c.Assert(err, IsNil)
 		createFakeResultForRandomSplit(mock, 0, testCase.randomValues)
 
-		chunks, err := splitRangeByRandom(db, testCase.originChunk, testCase.splitCount, "test", "test", splitCols, "", "")
+		chunks, err := splitRangeByRandom(db, nil, testCase.originChunk, testCase.splitCount, "test", "test", splitCols, "", "")
 		c.Assert(err, IsNil)
 		for j, chunk := range chunks {
 			chunkStr, args := chunk.toString("")
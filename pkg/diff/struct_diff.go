@@ -0,0 +1,395 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pingcap/parser/charset"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb/types"
+)
+
+const (
+	// StructDiffSchema means the schema only exists in source or target
+	StructDiffSchema = "schema"
+	// StructDiffTable means the table only exists in source or target
+	StructDiffTable = "table"
+	// StructDiffColumn means the column's definition is different
+	StructDiffColumn = "column"
+	// StructDiffIndex means the index's definition is different, includes the primary key
+	StructDiffIndex = "index"
+	// StructDiffPartition means the table's partitioning is different
+	StructDiffPartition = "partition"
+	// StructDiffOption means the table's option is different, like charset, collation and comment
+	StructDiffOption = "option"
+)
+
+// StructDiff is a difference of the table struct between source and target.
+type StructDiff struct {
+	// the type of the different object, like schema, table, column, index, partition and option
+	Type string `json:"type"`
+	Name string `json:"name"`
+	// the definitions in source and target, is empty if the object doesn't exist
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+func (d *StructDiff) String() string {
+	switch {
+	case len(d.Target) == 0:
+		return fmt.Sprintf("%s %s only in source: %s", d.Type, d.Name, d.Source)
+	case len(d.Source) == 0:
+		return fmt.Sprintf("%s %s only in target: %s", d.Type, d.Name, d.Target)
+	default:
+		return fmt.Sprintf("%s %s is different, source: %s, target: %s", d.Type, d.Name, d.Source, d.Target)
+	}
+}
+
+// TableStructDiff is the differences between the struct of a source table and the target table.
+type TableStructDiff struct {
+	// the name of the source table, like `schema`.`table`
+	SourceTable string        `json:"source-table"`
+	Diffs       []*StructDiff `json:"diffs"`
+	// the DDLs converge the target table's struct to the source table's
+	DDLs []string `json:"ddls"`
+}
+
+func (d *TableStructDiff) String() string {
+	lines := make([]string, 0, len(d.Diffs)+1)
+	lines = append(lines, fmt.Sprintf("compare with source table %s:", d.SourceTable))
+	for _, diff := range d.Diffs {
+		lines = append(lines, "  "+diff.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// CommentDDL comments out every line of the DDL, the DDL like CREATE TABLE may have multiple lines.
+func CommentDDL(ddl string) string {
+	return "-- " + strings.ReplaceAll(ddl, "\n", "\n-- ")
+}
+
+// DiffTableStruct compares the struct of the source table and the target table `schema`.`table`, and generates the DDLs
+// converge the target table to the source table. sourceInfo is nil if the table only exists in target, and targetInfo is
+// nil if the table only exists in source.
+func DiffTableStruct(schema, table string, sourceInfo, targetInfo *model.TableInfo) *TableStructDiff {
+	tableName := dbutil.TableName(schema, table)
+	structDiff := &TableStructDiff{}
+
+	switch {
+	case sourceInfo == nil && targetInfo == nil:
+		return structDiff
+	case targetInfo == nil:
+		structDiff.Diffs = append(structDiff.Diffs, &StructDiff{Type: StructDiffTable, Name: tableName, Source: createTableSQL(schema, table, sourceInfo)})
+		structDiff.DDLs = append(structDiff.DDLs, createTableSQL(schema, table, sourceInfo)+";")
+		return structDiff
+	case sourceInfo == nil:
+		structDiff.Diffs = append(structDiff.Diffs, &StructDiff{Type: StructDiffTable, Name: tableName, Target: createTableSQL(schema, table, targetInfo)})
+		structDiff.DDLs = append(structDiff.DDLs, fmt.Sprintf("DROP TABLE %s;", tableName))
+		return structDiff
+	}
+
+	alter := func(format string, args ...interface{}) string {
+		return fmt.Sprintf("ALTER TABLE %s %s;", tableName, fmt.Sprintf(format, args...))
+	}
+	// the indices are dropped before the columns are changed, and added after the columns are changed
+	var dropIndexDDLs, columnDDLs, addIndexDDLs, tableDDLs []string
+
+	// columns
+	for i, sourceCol := range sourceInfo.Columns {
+		sourceDef := columnDefinition(sourceCol)
+		targetCol := dbutil.FindColumnByName(targetInfo.Columns, sourceCol.Name.O)
+		if targetCol == nil {
+			structDiff.Diffs = append(structDiff.Diffs, &StructDiff{Type: StructDiffColumn, Name: dbutil.ColumnName(sourceCol.Name.O), Source: sourceDef})
+			position := "FIRST"
+			if i > 0 {
+				position = "AFTER " + dbutil.ColumnName(sourceInfo.Columns[i-1].Name.O)
+			}
+			columnDDLs = append(columnDDLs, alter("ADD COLUMN %s %s", sourceDef, position))
+			continue
+		}
+
+		if targetDef := columnDefinition(targetCol); targetDef != sourceDef {
+			structDiff.Diffs = append(structDiff.Diffs, &StructDiff{Type: StructDiffColumn, Name: dbutil.ColumnName(sourceCol.Name.O), Source: sourceDef, Target: targetDef})
+			columnDDLs = append(columnDDLs, alter("MODIFY COLUMN %s", sourceDef))
+		}
+	}
+	for _, targetCol := range targetInfo.Columns {
+		if dbutil.FindColumnByName(sourceInfo.Columns, targetCol.Name.O) == nil {
+			structDiff.Diffs = append(structDiff.Diffs, &StructDiff{Type: StructDiffColumn, Name: dbutil.ColumnName(targetCol.Name.O), Target: columnDefinition(targetCol)})
+			columnDDLs = append(columnDDLs, alter("DROP COLUMN %s", dbutil.ColumnName(targetCol.Name.O)))
+		}
+	}
+
+	// indices and primary key
+	dropIndex := func(index *model.IndexInfo) string {
+		if index.Primary {
+			return alter("DROP PRIMARY KEY")
+		}
+		return alter("DROP INDEX %s", dbutil.ColumnName(index.Name.O))
+	}
+	for _, sourceIndex := range sourceInfo.Indices {
+		sourceDef := indexDefinition(sourceIndex)
+		targetIndex := findIndex(targetInfo.Indices, sourceIndex)
+		if targetIndex == nil {
+			structDiff.Diffs = append(structDiff.Diffs, &StructDiff{Type: StructDiffIndex, Name: indexName(sourceIndex), Source: sourceDef})
+			addIndexDDLs = append(addIndexDDLs, alter("ADD %s", sourceDef))
+			continue
+		}
+
+		if targetDef := indexDefinition(targetIndex); targetDef != sourceDef {
+			structDiff.Diffs = append(structDiff.Diffs, &StructDiff{Type: StructDiffIndex, Name: indexName(sourceIndex), Source: sourceDef, Target: targetDef})
+			dropIndexDDLs = append(dropIndexDDLs, dropIndex(targetIndex))
+			addIndexDDLs = append(addIndexDDLs, alter("ADD %s", sourceDef))
+		}
+	}
+	for _, targetIndex := range targetInfo.Indices {
+		if findIndex(sourceInfo.Indices, targetIndex) == nil {
+			structDiff.Diffs = append(structDiff.Diffs, &StructDiff{Type: StructDiffIndex, Name: indexName(targetIndex), Target: indexDefinition(targetIndex)})
+			dropIndexDDLs = append(dropIndexDDLs, dropIndex(targetIndex))
+		}
+	}
+
+	// partitioning
+	sourcePartition, targetPartition := partitionDefinition(sourceInfo.Partition), partitionDefinition(targetInfo.Partition)
+	if sourcePartition != targetPartition {
+		structDiff.Diffs = append(structDiff.Diffs, &StructDiff{Type: StructDiffPartition, Name: tableName, Source: sourcePartition, Target: targetPartition})
+		if len(sourcePartition) == 0 {
+			tableDDLs = append(tableDDLs, alter("REMOVE PARTITIONING"))
+		} else {
+			tableDDLs = append(tableDDLs, alter("%s", sourcePartition))
+		}
+	}
+
+	// table options
+	charsetChanged := false
+	for _, option := range []struct {
+		name           string
+		source, target string
+	}{
+		{"CHARSET", sourceInfo.Charset, targetInfo.Charset},
+		{"COLLATE", sourceInfo.Collate, targetInfo.Collate},
+		{"COMMENT", sourceInfo.Comment, targetInfo.Comment},
+	} {
+		if strings.EqualFold(option.source, option.target) {
+			continue
+		}
+		structDiff.Diffs = append(structDiff.Diffs, &StructDiff{Type: StructDiffOption, Name: option.name, Source: option.source, Target: option.target})
+		if option.name == "COMMENT" {
+			tableDDLs = append(tableDDLs, alter("COMMENT = %s", quoteString(option.source)))
+		} else {
+			charsetChanged = true
+		}
+	}
+	if charsetChanged {
+		tableDDLs = append(tableDDLs, alter("DEFAULT CHARACTER SET = %s COLLATE = %s", sourceInfo.Charset, sourceInfo.Collate))
+	}
+
+	for _, ddls := range [][]string{dropIndexDDLs, columnDDLs, addIndexDDLs, tableDDLs} {
+		structDiff.DDLs = append(structDiff.DDLs, ddls...)
+	}
+	return structDiff
+}
+
+// createTableSQL returns the CREATE TABLE statement of the table info.
+func createTableSQL(schema, table string, tableInfo *model.TableInfo) string {
+	defs := make([]string, 0, len(tableInfo.Columns)+len(tableInfo.Indices))
+	for _, col := range tableInfo.Columns {
+		defs = append(defs, "  "+columnDefinition(col))
+	}
+	for _, index := range tableInfo.Indices {
+		defs = append(defs, "  "+indexDefinition(index))
+	}
+
+	var options string
+	if len(tableInfo.Charset) != 0 {
+		options += fmt.Sprintf(" DEFAULT CHARSET=%s", tableInfo.Charset)
+	}
+	if len(tableInfo.Collate) != 0 {
+		options += fmt.Sprintf(" COLLATE=%s", tableInfo.Collate)
+	}
+	if len(tableInfo.Comment) != 0 {
+		options += fmt.Sprintf(" COMMENT=%s", quoteString(tableInfo.Comment))
+	}
+	if partition := partitionDefinition(tableInfo.Partition); len(partition) != 0 {
+		options += "\n" + partition
+	}
+
+	return fmt.Sprintf("CREATE TABLE %s (\n%s\n)%s", dbutil.TableName(schema, table), strings.Join(defs, ",\n"), options)
+}
+
+// columnDefinition returns the column's definition used in CREATE TABLE, the display width of integer types is ignored.
+func columnDefinition(col *model.ColumnInfo) string {
+	ft := col.FieldType.Clone()
+	if mysql.IsIntegerType(ft.Tp) {
+		ft.Flen = types.UnspecifiedLength
+	}
+
+	parts := []string{dbutil.ColumnName(col.Name.O), ft.InfoSchemaStr()}
+	if mysql.HasZerofillFlag(ft.Flag) {
+		parts = append(parts, "zerofill")
+	}
+	hasCharset := types.IsTypeChar(ft.Tp) || types.IsTypeBlob(ft.Tp) || ft.Tp == mysql.TypeEnum || ft.Tp == mysql.TypeSet
+	if hasCharset && len(ft.Charset) != 0 && ft.Charset != charset.CharsetBin {
+		parts = append(parts, "CHARACTER SET "+ft.Charset)
+		if len(ft.Collate) != 0 {
+			parts = append(parts, "COLLATE "+ft.Collate)
+		}
+	}
+
+	if col.IsGenerated() {
+		storage := "VIRTUAL"
+		if col.GeneratedStored {
+			storage = "STORED"
+		}
+		parts = append(parts, fmt.Sprintf("GENERATED ALWAYS AS (%s) %s", col.GeneratedExprString, storage))
+	}
+
+	if mysql.HasNotNullFlag(ft.Flag) {
+		parts = append(parts, "NOT NULL")
+	}
+
+	defaultValue := col.GetDefaultValue()
+	switch {
+	case defaultValue != nil && isCurrentTimestamp(defaultValue):
+		parts = append(parts, "DEFAULT "+currentTimestamp(ft.Decimal))
+	case defaultValue != nil && col.DefaultIsExpr:
+		parts = append(parts, fmt.Sprintf("DEFAULT (%v)", defaultValue))
+	case defaultValue != nil:
+		parts = append(parts, "DEFAULT "+quoteString(fmt.Sprintf("%v", defaultValue)))
+	case !mysql.HasNotNullFlag(ft.Flag) && !col.IsGenerated() && !types.IsTypeBlob(ft.Tp) && ft.Tp != mysql.TypeJSON:
+		parts = append(parts, "DEFAULT NULL")
+	}
+
+	if mysql.HasAutoIncrementFlag(ft.Flag) {
+		parts = append(parts, "AUTO_INCREMENT")
+	}
+	if mysql.HasOnUpdateNowFlag(ft.Flag) {
+		parts = append(parts, "ON UPDATE "+currentTimestamp(ft.Decimal))
+	}
+	if len(col.Comment) != 0 {
+		parts = append(parts, "COMMENT "+quoteString(col.Comment))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func isCurrentTimestamp(value interface{}) bool {
+	s, ok := value.(string)
+	return ok && strings.HasPrefix(strings.ToUpper(s), "CURRENT_TIMESTAMP")
+}
+
+func currentTimestamp(fsp int) string {
+	if fsp > 0 {
+		return fmt.Sprintf("CURRENT_TIMESTAMP(%d)", fsp)
+	}
+	return "CURRENT_TIMESTAMP"
+}
+
+// indexName returns the name of the index, the primary key is always named PRIMARY.
+func indexName(index *model.IndexInfo) string {
+	if index.Primary {
+		return "PRIMARY"
+	}
+	return dbutil.ColumnName(index.Name.O)
+}
+
+// findIndex finds the index with the same name, or the primary key if the index is the primary key.
+func findIndex(indices []*model.IndexInfo, index *model.IndexInfo) *model.IndexInfo {
+	for _, idx := range indices {
+		if idx.Primary && index.Primary {
+			return idx
+		}
+		if !idx.Primary && !index.Primary && idx.Name.L == index.Name.L {
+			return idx
+		}
+	}
+
+	return nil
+}
+
+// indexDefinition returns the index's definition used in CREATE TABLE.
+func indexDefinition(index *model.IndexInfo) string {
+	cols := make([]string, 0, len(index.Columns))
+	for _, col := range index.Columns {
+		name := dbutil.ColumnName(col.Name.O)
+		if col.Length > 0 {
+			name += fmt.Sprintf("(%d)", col.Length)
+		}
+		cols = append(cols, name)
+	}
+
+	switch {
+	case index.Primary:
+		return fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(cols, ","))
+	case index.Unique:
+		return fmt.Sprintf("UNIQUE KEY %s (%s)", dbutil.ColumnName(index.Name.O), strings.Join(cols, ","))
+	default:
+		return fmt.Sprintf("KEY %s (%s)", dbutil.ColumnName(index.Name.O), strings.Join(cols, ","))
+	}
+}
+
+// partitionDefinition returns the partition options used in CREATE TABLE, returns empty string if the table is not partitioned.
+func partitionDefinition(partition *model.PartitionInfo) string {
+	if partition == nil {
+		return ""
+	}
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "PARTITION BY %s", partition.Type.String())
+	if len(partition.Columns) != 0 {
+		cols := make([]string, 0, len(partition.Columns))
+		for _, col := range partition.Columns {
+			cols = append(cols, dbutil.ColumnName(col.O))
+		}
+		fmt.Fprintf(&buf, " COLUMNS(%s)", strings.Join(cols, ","))
+	} else {
+		fmt.Fprintf(&buf, " (%s)", partition.Expr)
+	}
+
+	if partition.Type == model.PartitionTypeHash || partition.Type == model.PartitionTypeKey {
+		num := partition.Num
+		if num == 0 {
+			num = uint64(len(partition.Definitions))
+		}
+		fmt.Fprintf(&buf, " PARTITIONS %d", num)
+		return buf.String()
+	}
+
+	defs := make([]string, 0, len(partition.Definitions))
+	for _, def := range partition.Definitions {
+		switch {
+		case len(def.LessThan) != 0:
+			defs = append(defs, fmt.Sprintf("PARTITION %s VALUES LESS THAN (%s)", dbutil.ColumnName(def.Name.O), strings.Join(def.LessThan, ",")))
+		case len(def.InValues) != 0:
+			values := make([]string, 0, len(def.InValues))
+			for _, value := range def.InValues {
+				if len(value) == 1 {
+					values = append(values, value[0])
+				} else {
+					values = append(values, fmt.Sprintf("(%s)", strings.Join(value, ",")))
+				}
+			}
+			defs = append(defs, fmt.Sprintf("PARTITION %s VALUES IN (%s)", dbutil.ColumnName(def.Name.O), strings.Join(values, ",")))
+		default:
+			defs = append(defs, fmt.Sprintf("PARTITION %s", dbutil.ColumnName(def.Name.O)))
+		}
+	}
+	fmt.Fprintf(&buf, " (%s)", strings.Join(defs, ", "))
+
+	return buf.String()
+}
@@ -0,0 +1,156 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"strings"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/parser"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+)
+
+var _ = Suite(&testStructDiffSuite{})
+
+type testStructDiffSuite struct{}
+
+func (s *testStructDiffSuite) getTableInfo(c *C, createTableSQL string) *model.TableInfo {
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	c.Assert(err, IsNil)
+	return tableInfo
+}
+
+func (s *testStructDiffSuite) TestDiffTableStruct(c *C) {
+	sourceInfo := s.getTableInfo(c, "CREATE TABLE `t` (`id` int(11) NOT NULL AUTO_INCREMENT, `name` varchar(24) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin DEFAULT 'a''b', "+
+		"`age` int(10) unsigned DEFAULT NULL, `ts` timestamp(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3) ON UPDATE CURRENT_TIMESTAMP(3), "+
+		"PRIMARY KEY (`id`), UNIQUE KEY `uk_name` (`id`,`name`(10)), KEY `idx_age` (`age`)) "+
+		"DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin COMMENT='users' PARTITION BY RANGE (`id`) (PARTITION `p0` VALUES LESS THAN (100), PARTITION `p1` VALUES LESS THAN (MAXVALUE))")
+
+	// the same struct, the display width of integer types is ignored
+	targetInfo := s.getTableInfo(c, "CREATE TABLE `t` (`id` int NOT NULL AUTO_INCREMENT, `name` varchar(24) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin DEFAULT 'a''b', "+
+		"`age` int unsigned DEFAULT NULL, `ts` timestamp(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3) ON UPDATE CURRENT_TIMESTAMP(3), "+
+		"PRIMARY KEY (`id`), UNIQUE KEY `uk_name` (`id`,`name`(10)), KEY `idx_age` (`age`)) "+
+		"DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin COMMENT='users' PARTITION BY RANGE (`id`) (PARTITION `p0` VALUES LESS THAN (100), PARTITION `p1` VALUES LESS THAN (MAXVALUE))")
+	structDiff := DiffTableStruct("test", "t", sourceInfo, targetInfo)
+	c.Assert(structDiff.Diffs, HasLen, 0)
+	c.Assert(structDiff.DDLs, HasLen, 0)
+
+	targetInfo = s.getTableInfo(c, "CREATE TABLE `t` (`id` int NOT NULL AUTO_INCREMENT, `name` varchar(32) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL, "+
+		"`ts` timestamp(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3) ON UPDATE CURRENT_TIMESTAMP(3), `extra` text, "+
+		"PRIMARY KEY (`id`), UNIQUE KEY `uk_name` (`id`,`name`), KEY `idx_extra` (`extra`(8))) DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci")
+	structDiff = DiffTableStruct("test", "t", sourceInfo, targetInfo)
+
+	diffs := make([]string, 0, len(structDiff.Diffs))
+	for _, diff := range structDiff.Diffs {
+		diffs = append(diffs, diff.String())
+	}
+	c.Assert(diffs, DeepEquals, []string{
+		"column `name` is different, source: `name` varchar(24) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin DEFAULT 'a\\'b', target: `name` varchar(32) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL",
+		"column `age` only in source: `age` int(11) unsigned DEFAULT NULL",
+		"column `extra` only in target: `extra` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci",
+		"index `uk_name` is different, source: UNIQUE KEY `uk_name` (`id`,`name`(10)), target: UNIQUE KEY `uk_name` (`id`,`name`)",
+		"index `idx_age` only in source: KEY `idx_age` (`age`)",
+		"index `idx_extra` only in target: KEY `idx_extra` (`extra`(8))",
+		"partition `test`.`t` only in source: PARTITION BY RANGE (`id`) (PARTITION `p0` VALUES LESS THAN (100), PARTITION `p1` VALUES LESS THAN (MAXVALUE))",
+		"option COLLATE is different, source: utf8mb4_bin, target: utf8mb4_general_ci",
+		"option COMMENT only in source: users",
+	})
+	c.Assert(structDiff.DDLs, DeepEquals, []string{
+		"ALTER TABLE `test`.`t` DROP INDEX `uk_name`;",
+		"ALTER TABLE `test`.`t` DROP INDEX `idx_extra`;",
+		"ALTER TABLE `test`.`t` MODIFY COLUMN `name` varchar(24) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin DEFAULT 'a\\'b';",
+		"ALTER TABLE `test`.`t` ADD COLUMN `age` int(11) unsigned DEFAULT NULL AFTER `name`;",
+		"ALTER TABLE `test`.`t` DROP COLUMN `extra`;",
+		"ALTER TABLE `test`.`t` ADD UNIQUE KEY `uk_name` (`id`,`name`(10));",
+		"ALTER TABLE `test`.`t` ADD KEY `idx_age` (`age`);",
+		"ALTER TABLE `test`.`t` PARTITION BY RANGE (`id`) (PARTITION `p0` VALUES LESS THAN (100), PARTITION `p1` VALUES LESS THAN (MAXVALUE));",
+		"ALTER TABLE `test`.`t` COMMENT = 'users';",
+		"ALTER TABLE `test`.`t` DEFAULT CHARACTER SET = utf8mb4 COLLATE = utf8mb4_bin;",
+	})
+
+	// the primary key is different
+	targetInfo = s.getTableInfo(c, "CREATE TABLE `t` (`id` int NOT NULL, `name` varchar(24) NOT NULL, PRIMARY KEY (`id`, `name`))")
+	sourceInfo = s.getTableInfo(c, "CREATE TABLE `t` (`id` int NOT NULL, `name` varchar(24) NOT NULL, PRIMARY KEY (`id`))")
+	structDiff = DiffTableStruct("test", "t", sourceInfo, targetInfo)
+	c.Assert(structDiff.Diffs, HasLen, 1)
+	c.Assert(structDiff.Diffs[0].Name, Equals, "PRIMARY")
+	c.Assert(structDiff.DDLs, DeepEquals, []string{
+		"ALTER TABLE `test`.`t` DROP PRIMARY KEY;",
+		"ALTER TABLE `test`.`t` ADD PRIMARY KEY (`id`);",
+	})
+}
+
+func (s *testStructDiffSuite) TestDiffTableOnlyInOneSide(c *C) {
+	tableInfo := s.getTableInfo(c, "CREATE TABLE `t` (`id` bigint(20) NOT NULL, `v` json, PRIMARY KEY (`id`)) DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin PARTITION BY HASH (`id`) PARTITIONS 4")
+	createTable := "CREATE TABLE `test`.`t` (\n" +
+		"  `id` bigint(20) NOT NULL,\n" +
+		"  `v` json,\n" +
+		"  PRIMARY KEY (`id`)\n" +
+		") DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin\n" +
+		"PARTITION BY HASH (`id`) PARTITIONS 4"
+
+	structDiff := DiffTableStruct("test", "t", tableInfo, nil)
+	c.Assert(structDiff.Diffs, HasLen, 1)
+	c.Assert(*structDiff.Diffs[0], DeepEquals, StructDiff{Type: StructDiffTable, Name: "`test`.`t`", Source: createTable})
+	c.Assert(structDiff.DDLs, DeepEquals, []string{createTable + ";"})
+	// the generated sql can be parsed again
+	_, err := dbutil.GetTableInfoBySQL(createTable, parser.New())
+	c.Assert(err, IsNil)
+	// every line is commented out
+	for _, line := range strings.Split(CommentDDL(structDiff.DDLs[0]), "\n") {
+		c.Assert(line, Matches, "-- .*")
+	}
+
+	structDiff = DiffTableStruct("test", "t", nil, tableInfo)
+	c.Assert(structDiff.Diffs, HasLen, 1)
+	c.Assert(*structDiff.Diffs[0], DeepEquals, StructDiff{Type: StructDiffTable, Name: "`test`.`t`", Target: createTable})
+	c.Assert(structDiff.DDLs, DeepEquals, []string{"DROP TABLE `test`.`t`;"})
+}
+
+func (s *testStructDiffSuite) TestCheckTableStruct(c *C) {
+	targetInfo := s.getTableInfo(c, "CREATE TABLE `t` (`id` int NOT NULL, `name` varchar(24), PRIMARY KEY (`id`)) COMMENT='users'")
+	tableDiff := &TableDiff{
+		TargetTable: &TableInstance{Schema: "test", Table: "t", info: targetInfo},
+		SourceTables: []*TableInstance{
+			{Schema: "test", Table: "t_1", info: s.getTableInfo(c, "CREATE TABLE `t_1` (`id` int NOT NULL, `name` varchar(24), PRIMARY KEY (`id`))")},
+			{Schema: "test", Table: "t_2", info: s.getTableInfo(c, "CREATE TABLE `t_2` (`id` int NOT NULL, `name` varchar(24), PRIMARY KEY (`id`))")},
+		},
+	}
+
+	// the struct is equal when only the table options are different, but the differences are still reported
+	structEqual, err := tableDiff.CheckTableStruct(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(structEqual, IsTrue)
+	c.Assert(tableDiff.StructDiffs(), HasLen, 2)
+
+	// the same DDLs of the shards are written once as comments
+	sqls := make([]string, 0, 2)
+	c.Assert(tableDiff.writeStructDDLs(func(sql string) error {
+		sqls = append(sqls, sql)
+		return nil
+	}), IsNil)
+	c.Assert(sqls, DeepEquals, []string{
+		"-- converge the struct to source table `test`.`t_1`, review and uncomment the DDLs before execute them\n",
+		"-- " + tableDiff.StructDiffs()[0].DDLs[0] + "\n",
+	})
+
+	// the columns are different
+	tableDiff.SourceTables[1].info = s.getTableInfo(c, "CREATE TABLE `t_2` (`id` int NOT NULL, `name` varchar(24), `age` int, PRIMARY KEY (`id`))")
+	structEqual, err = tableDiff.CheckTableStruct(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(structEqual, IsFalse)
+	c.Assert(tableDiff.StructDiffs(), HasLen, 2)
+}
@@ -0,0 +1,251 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"go.uber.org/zap"
+)
+
+const (
+	// the load of the instance is checked at most once in the interval
+	loadCheckInterval = time.Second
+	// the max time to wait before check the overloaded instance again
+	maxLoadBackoff = 30 * time.Second
+)
+
+// ThrottleConfig is the config to limit the load of checking data on a database instance.
+type ThrottleConfig struct {
+	// the max num of queries sent to the instance per second, 0 means no limit
+	QueriesPerSecond float64 `toml:"queries-per-second" json:"queries-per-second"`
+	// the max num of rows read from the instance per second, 0 means no limit
+	RowsPerSecond float64 `toml:"rows-per-second" json:"rows-per-second"`
+	// pause checking chunks when the Threads_running of the instance is greater than it, 0 means don't check.
+	// the load is not checked anymore if failed to query it
+	MaxThreadsRunning int64 `toml:"max-threads-running" json:"max-threads-running"`
+	// pause checking chunks when the instance is a replica and its lag in seconds is greater than it, 0 means don't check
+	MaxReplicationLag int64 `toml:"max-replication-lag" json:"max-replication-lag"`
+}
+
+// Throttler limits the rate of queries and rows of checking data on a database instance, and pauses checking chunks
+// until the instance's load is under the thresholds. it can be shared by all the tables in the instance.
+type Throttler struct {
+	db  *sql.DB
+	cfg ThrottleConfig
+
+	queryLimiter *rateLimiter
+	rowLimiter   *rateLimiter
+
+	mu sync.Mutex
+	// the time and result of the latest load check
+	lastCheck      time.Time
+	overloadReason string
+	// the load check is stopped if failed to query the load
+	loadCheckFailed bool
+}
+
+// NewThrottler returns a Throttler of the instance, returns nil if don't need to throttle.
+func NewThrottler(db *sql.DB, cfg ThrottleConfig) *Throttler {
+	if cfg.QueriesPerSecond <= 0 && cfg.RowsPerSecond <= 0 && cfg.MaxThreadsRunning <= 0 && cfg.MaxReplicationLag <= 0 {
+		return nil
+	}
+
+	return &Throttler{
+		db:           db,
+		cfg:          cfg,
+		queryLimiter: newRateLimiter(cfg.QueriesPerSecond),
+		rowLimiter:   newRateLimiter(cfg.RowsPerSecond),
+	}
+}
+
+// WaitChunk blocks until the instance is not overloaded.
+func (t *Throttler) WaitChunk(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+
+	return errors.Trace(t.waitLoad(ctx))
+}
+
+// WaitRows takes the rows read from the instance, and blocks until the rows are allowed by the rate.
+// it's called after the rows are read, because the num of rows in a chunk is unknown before.
+func (t *Throttler) WaitRows(ctx context.Context, rowNum int64) error {
+	if t == nil {
+		return nil
+	}
+
+	return errors.Trace(t.rowLimiter.wait(ctx, float64(rowNum)))
+}
+
+// WaitQuery blocks until a query can be sent to the instance.
+func (t *Throttler) WaitQuery(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+
+	return errors.Trace(t.queryLimiter.wait(ctx, 1))
+}
+
+// waitLoad blocks until the load of the instance is under the thresholds, the wait time is doubled every time the instance is still overloaded.
+func (t *Throttler) waitLoad(ctx context.Context) error {
+	if t.cfg.MaxThreadsRunning <= 0 && t.cfg.MaxReplicationLag <= 0 {
+		return nil
+	}
+
+	backoff := loadCheckInterval
+	for {
+		reason := t.checkLoad(ctx)
+		if len(reason) == 0 {
+			return nil
+		}
+
+		log.Warn("instance is overloaded, pause checking chunks", zap.String("reason", reason), zap.Duration("backoff", backoff))
+		throttleCounter.WithLabelValues("load").Inc()
+		if err := sleep(ctx, backoff); err != nil {
+			return errors.Trace(err)
+		}
+		backoff *= 2
+		if backoff > maxLoadBackoff {
+			backoff = maxLoadBackoff
+		}
+	}
+}
+
+// checkLoad returns the reason if the instance is overloaded, the result is reused in loadCheckInterval.
+// if failed to query the load, the error is only logged, and the load is not checked anymore.
+func (t *Throttler) checkLoad(ctx context.Context) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.loadCheckFailed || time.Since(t.lastCheck) < loadCheckInterval {
+		return t.overloadReason
+	}
+
+	reason, err := t.getOverloadReason(ctx)
+	if err != nil {
+		log.Warn("get the load of instance failed, stop pausing checking chunks by the load", zap.Error(err))
+		t.loadCheckFailed, t.overloadReason = true, ""
+		return ""
+	}
+	t.lastCheck, t.overloadReason = time.Now(), reason
+
+	return reason
+}
+
+// getOverloadReason queries the load of the instance, returns the reason if the load exceeds the thresholds.
+func (t *Throttler) getOverloadReason(ctx context.Context) (string, error) {
+	if t.cfg.MaxThreadsRunning > 0 {
+		value, err := dbutil.ShowMySQLStatus(ctx, t.db, "Threads_running")
+		if err != nil {
+			return "", errors.Annotate(err, "get Threads_running")
+		}
+		threadsRunning, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "", errors.Annotatef(err, "parse Threads_running %s", value)
+		}
+		if threadsRunning > t.cfg.MaxThreadsRunning {
+			return fmt.Sprintf("Threads_running %d is greater than %d", threadsRunning, t.cfg.MaxThreadsRunning), nil
+		}
+	}
+
+	if t.cfg.MaxReplicationLag > 0 {
+		lag, ok, err := dbutil.ShowReplicationLag(ctx, t.db)
+		if err != nil {
+			return "", errors.Annotate(err, "get replication lag")
+		}
+		if ok && lag > t.cfg.MaxReplicationLag {
+			return fmt.Sprintf("replication lag %ds is greater than %ds", lag, t.cfg.MaxReplicationLag), nil
+		}
+	}
+
+	return "", nil
+}
+
+// rateLimiter is a token bucket allows rate tokens per second and bursts at most rate tokens,
+// the tokens can be borrowed, and the borrower waits until the tokens are refilled.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter returns a rateLimiter, returns nil if rate is not positive, which means no limit.
+func newRateLimiter(rate float64) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+
+	return &rateLimiter{
+		rate:   rate,
+		tokens: rate,
+	}
+}
+
+// wait takes n tokens, and blocks until they are available.
+func (l *rateLimiter) wait(ctx context.Context, n float64) error {
+	if l == nil {
+		return nil
+	}
+
+	d := l.reserve(time.Now(), n)
+	if d > 0 {
+		throttleCounter.WithLabelValues("rate").Inc()
+	}
+	return sleep(ctx, d)
+}
+
+// reserve takes n tokens at now, returns the time to wait until the tokens are available.
+func (l *rateLimiter) reserve(now time.Time, n float64) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.last.IsZero() && now.After(l.last) {
+		l.tokens = math.Min(l.rate, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+	l.last = now
+
+	l.tokens -= n
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// sleep blocks for the duration, returns error if the context is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
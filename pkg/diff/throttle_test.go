@@ -0,0 +1,95 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	. "github.com/pingcap/check"
+	"github.com/pingcap/errors"
+)
+
+var _ = Suite(&testThrottleSuite{})
+
+type testThrottleSuite struct{}
+
+func (s *testThrottleSuite) TestRateLimiter(c *C) {
+	c.Assert(newRateLimiter(0), IsNil)
+	c.Assert((*rateLimiter)(nil).wait(context.Background(), 100), IsNil)
+
+	l := newRateLimiter(100)
+	now := time.Now()
+	// can burst the tokens of one second
+	c.Assert(l.reserve(now, 60), Equals, time.Duration(0))
+	c.Assert(l.reserve(now, 40), Equals, time.Duration(0))
+	// borrow the tokens, and wait until they are refilled
+	c.Assert(l.reserve(now, 50), Equals, 500*time.Millisecond)
+	c.Assert(l.reserve(now.Add(500*time.Millisecond), 100), Equals, time.Second)
+	// the tokens never exceed the rate
+	c.Assert(l.reserve(now.Add(time.Hour), 100), Equals, time.Duration(0))
+	c.Assert(l.reserve(now.Add(time.Hour), 1), Equals, 10*time.Millisecond)
+}
+
+func (s *testThrottleSuite) TestThrottler(c *C) {
+	c.Assert(NewThrottler(nil, ThrottleConfig{}), IsNil)
+	c.Assert((*Throttler)(nil).WaitChunk(context.Background()), IsNil)
+	c.Assert((*Throttler)(nil).WaitRows(context.Background(), 1000), IsNil)
+	c.Assert((*Throttler)(nil).WaitQuery(context.Background()), IsNil)
+
+	db, mock, err := sqlmock.New()
+	c.Assert(err, IsNil)
+	throttler := NewThrottler(db, ThrottleConfig{MaxThreadsRunning: 20, MaxReplicationLag: 10})
+
+	mock.ExpectQuery("SHOW GLOBAL STATUS LIKE 'Threads_running'").
+		WillReturnRows(sqlmock.NewRows([]string{"Variable_name", "Value"}).AddRow("Threads_running", "32"))
+	reason := throttler.checkLoad(context.Background())
+	c.Assert(reason, Equals, "Threads_running 32 is greater than 20")
+	// the result is reused in the check interval
+	reason = throttler.checkLoad(context.Background())
+	c.Assert(reason, Equals, "Threads_running 32 is greater than 20")
+
+	mock.ExpectQuery("SHOW GLOBAL STATUS LIKE 'Threads_running'").
+		WillReturnRows(sqlmock.NewRows([]string{"Variable_name", "Value"}).AddRow("Threads_running", "8"))
+	mock.ExpectQuery("SHOW SLAVE STATUS").
+		WillReturnRows(sqlmock.NewRows([]string{"Seconds_Behind_Master"}).AddRow("60"))
+	reason, err = throttler.getOverloadReason(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(reason, Equals, "replication lag 60s is greater than 10s")
+
+	// the instance is not overloaded
+	throttler.lastCheck = time.Time{}
+	mock.ExpectQuery("SHOW GLOBAL STATUS LIKE 'Threads_running'").
+		WillReturnRows(sqlmock.NewRows([]string{"Variable_name", "Value"}).AddRow("Threads_running", "8"))
+	mock.ExpectQuery("SHOW SLAVE STATUS").
+		WillReturnRows(sqlmock.NewRows([]string{"Seconds_Behind_Master"}).AddRow("1"))
+	c.Assert(throttler.WaitChunk(context.Background()), IsNil)
+	c.Assert(mock.ExpectationsWereMet(), IsNil)
+
+	// the load is not checked anymore after failed to query it
+	throttler.lastCheck = time.Time{}
+	mock.ExpectQuery("SHOW GLOBAL STATUS LIKE 'Threads_running'").WillReturnError(errors.New("access denied"))
+	c.Assert(throttler.WaitChunk(context.Background()), IsNil)
+	throttler.lastCheck = time.Time{}
+	c.Assert(throttler.WaitChunk(context.Background()), IsNil)
+	c.Assert(mock.ExpectationsWereMet(), IsNil)
+	throttler.loadCheckFailed = false
+
+	// pause until the context is done when the instance is still overloaded
+	throttler.lastCheck, throttler.overloadReason = time.Now(), "Threads_running 32 is greater than 20"
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	c.Assert(throttler.WaitChunk(ctx), ErrorMatches, ".*deadline exceeded.*")
+}
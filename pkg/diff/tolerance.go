@@ -0,0 +1,195 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb/types"
+)
+
+const (
+	// AllColumnsTolerance is the column name of the tolerance applied to all the FLOAT, DOUBLE, DECIMAL and time columns
+	// which don't have their own tolerance, and only applied to the columns whose type is covered by the tolerance
+	AllColumnsTolerance = "*"
+)
+
+// ColumnTolerance is the difference allowed when compare the column's value in source and target.
+// the columns with tolerance are excluded from checksum, and are always compared by rows.
+type ColumnTolerance struct {
+	// the max absolute difference of FLOAT, DOUBLE or DECIMAL value
+	AbsoluteEpsilon float64 `toml:"absolute-epsilon" json:"absolute-epsilon"`
+	// the max relative difference of FLOAT, DOUBLE or DECIMAL value, relative to the greater absolute value
+	RelativeEpsilon float64 `toml:"relative-epsilon" json:"relative-epsilon"`
+	// the num of fractional seconds digits compared for DATETIME, TIMESTAMP or TIME value, the extra digits are truncated,
+	// the time columns have no tolerance if it is nil
+	TimePrecision *int `toml:"time-precision" json:"time-precision"`
+}
+
+// covers returns true if the tolerance is set for the column type.
+func (t *ColumnTolerance) covers(tp byte) bool {
+	if dbutil.IsFloatType(tp) {
+		return t.AbsoluteEpsilon > 0 || t.RelativeEpsilon > 0
+	}
+
+	return isFractionalTimeType(tp) && t.TimePrecision != nil
+}
+
+// columnTolerance is the tolerance of a column in table.
+type columnTolerance struct {
+	*ColumnTolerance
+	tp byte
+}
+
+// getColumnTolerances returns column name => tolerance of the table's columns.
+func getColumnTolerances(tableInfo *model.TableInfo, tolerances map[string]*ColumnTolerance) (map[string]*columnTolerance, error) {
+	if len(tolerances) == 0 {
+		return nil, nil
+	}
+
+	orderKeys, _ := dbutil.SelectUniqueOrderKey(tableInfo)
+	isOrderKey := func(col *model.ColumnInfo) bool {
+		for _, key := range orderKeys {
+			if key == col.Name.O {
+				return true
+			}
+		}
+		return false
+	}
+
+	columnTolerances := make(map[string]*columnTolerance)
+	for name, tolerance := range tolerances {
+		if name == AllColumnsTolerance {
+			continue
+		}
+
+		col := dbutil.FindColumnByName(tableInfo.Columns, name)
+		if col == nil {
+			return nil, errors.NotFoundf("column %s with tolerance", name)
+		}
+		if !isToleranceType(col.Tp) {
+			return nil, errors.NotSupportedf("tolerance on column %s with type %s", col.Name.O, col.FieldType.String())
+		}
+		if !tolerance.covers(col.Tp) {
+			return nil, errors.Errorf("tolerance on column %s with type %s is not valid, should set absolute-epsilon or relative-epsilon for FLOAT, DOUBLE and DECIMAL, and time-precision for time", col.Name.O, col.FieldType.String())
+		}
+		// the rows are ordered by the order key, so it must be compared exactly
+		if isOrderKey(col) {
+			return nil, errors.NotSupportedf("tolerance on the order key %s", col.Name.O)
+		}
+		columnTolerances[col.Name.O] = &columnTolerance{ColumnTolerance: tolerance, tp: col.Tp}
+	}
+
+	if tolerance, ok := tolerances[AllColumnsTolerance]; ok {
+		for _, col := range tableInfo.Columns {
+			if _, ok := columnTolerances[col.Name.O]; ok || !isToleranceType(col.Tp) || !tolerance.covers(col.Tp) || isOrderKey(col) {
+				continue
+			}
+			columnTolerances[col.Name.O] = &columnTolerance{ColumnTolerance: tolerance, tp: col.Tp}
+		}
+	}
+
+	return columnTolerances, nil
+}
+
+func isToleranceType(tp byte) bool {
+	return dbutil.IsFloatType(tp) || isFractionalTimeType(tp)
+}
+
+func isFractionalTimeType(tp byte) bool {
+	return tp == mysql.TypeDatetime || tp == mysql.TypeTimestamp || tp == mysql.TypeDuration
+}
+
+// equal returns true if the difference of the values is in the tolerance.
+func (t *columnTolerance) equal(data1, data2 *dbutil.ColumnData) bool {
+	if data1.IsNull || data2.IsNull {
+		return data1.IsNull == data2.IsNull
+	}
+
+	if isFractionalTimeType(t.tp) {
+		return truncateFractionalSeconds(string(data1.Data), *t.TimePrecision) == truncateFractionalSeconds(string(data2.Data), *t.TimePrecision)
+	}
+	// the DECIMAL value may lose precision if converted to float
+	if t.tp == mysql.TypeNewDecimal {
+		return t.equalDecimal(data1.Data, data2.Data)
+	}
+
+	num1, err1 := strconv.ParseFloat(string(data1.Data), 64)
+	num2, err2 := strconv.ParseFloat(string(data2.Data), 64)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+
+	diff := math.Abs(num1 - num2)
+	return diff <= t.AbsoluteEpsilon || diff <= t.RelativeEpsilon*math.Max(math.Abs(num1), math.Abs(num2))
+}
+
+// equalDecimal returns true if the difference of the DECIMAL values is in the tolerance, the values are compared exactly.
+func (t *columnTolerance) equalDecimal(value1, value2 []byte) bool {
+	var dec1, dec2, diff, epsilon, maxDiff types.MyDecimal
+	if dec1.FromString(value1) != nil || dec2.FromString(value2) != nil {
+		return false
+	}
+	if types.DecimalSub(&dec1, &dec2, &diff) != nil {
+		return false
+	}
+	diff = *absDecimal(&diff)
+
+	if epsilon.FromFloat64(t.AbsoluteEpsilon) == nil && diff.Compare(&epsilon) <= 0 {
+		return true
+	}
+
+	greater := absDecimal(&dec1)
+	if abs2 := absDecimal(&dec2); abs2.Compare(greater) > 0 {
+		greater = abs2
+	}
+	if epsilon.FromFloat64(t.RelativeEpsilon) != nil {
+		return false
+	}
+	// the fractional digits exceeds the max scale are truncated
+	if err := types.DecimalMul(greater, &epsilon, &maxDiff); err != nil && !types.ErrTruncated.Equal(err) {
+		return false
+	}
+	return diff.Compare(&maxDiff) <= 0
+}
+
+func absDecimal(dec *types.MyDecimal) *types.MyDecimal {
+	if dec.IsNegative() {
+		return types.DecimalNeg(dec)
+	}
+	return dec
+}
+
+// truncateFractionalSeconds keeps at most precision digits of the fractional seconds, and removes the trailing zeros,
+// so "10:00:00.1200" and "10:00:00.12" are same.
+func truncateFractionalSeconds(value string, precision int) string {
+	dot := strings.LastIndexByte(value, '.')
+	if dot == -1 {
+		return value
+	}
+
+	if precision < 0 {
+		precision = 0
+	}
+	if len(value) > dot+1+precision {
+		value = value[:dot+1+precision]
+	}
+	return strings.TrimRight(strings.TrimRight(value, "0"), ".")
+}
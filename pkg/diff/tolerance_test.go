@@ -0,0 +1,133 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	. "github.com/pingcap/check"
+	"github.com/pingcap/parser"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+)
+
+var _ = Suite(&testToleranceSuite{})
+
+type testToleranceSuite struct{}
+
+func (s *testToleranceSuite) TestGetColumnTolerances(c *C) {
+	tableInfo, err := dbutil.GetTableInfoBySQL("CREATE TABLE `t` (`id` decimal(10,2), `price` double, `amount` decimal(10,2), `name` varchar(24), `ts` datetime(6), primary key(`id`))", parser.New())
+	c.Assert(err, IsNil)
+
+	tolerances, err := getColumnTolerances(tableInfo, nil)
+	c.Assert(err, IsNil)
+	c.Assert(tolerances, HasLen, 0)
+
+	timePrecision := 3
+	priceTolerance := &ColumnTolerance{AbsoluteEpsilon: 0.1}
+	allTolerance := &ColumnTolerance{RelativeEpsilon: 0.01, TimePrecision: &timePrecision}
+	tolerances, err = getColumnTolerances(tableInfo, map[string]*ColumnTolerance{"PRICE": priceTolerance, AllColumnsTolerance: allTolerance})
+	c.Assert(err, IsNil)
+	c.Assert(tolerances, HasLen, 3)
+	c.Assert(tolerances["price"].ColumnTolerance, Equals, priceTolerance)
+	c.Assert(tolerances["amount"].ColumnTolerance, Equals, allTolerance)
+	c.Assert(tolerances["ts"].ColumnTolerance, Equals, allTolerance)
+
+	// the tolerance of all columns only applies to the columns whose type is covered
+	timeTolerance := &ColumnTolerance{TimePrecision: &timePrecision}
+	tolerances, err = getColumnTolerances(tableInfo, map[string]*ColumnTolerance{"price": priceTolerance, AllColumnsTolerance: timeTolerance})
+	c.Assert(err, IsNil)
+	c.Assert(tolerances, HasLen, 2)
+	c.Assert(tolerances["price"].ColumnTolerance, Equals, priceTolerance)
+	c.Assert(tolerances["ts"].ColumnTolerance, Equals, timeTolerance)
+	_, err = getColumnTolerances(tableInfo, map[string]*ColumnTolerance{"price": timeTolerance})
+	c.Assert(err, ErrorMatches, ".*tolerance on column price.*not valid.*")
+	_, err = getColumnTolerances(tableInfo, map[string]*ColumnTolerance{"ts": priceTolerance})
+	c.Assert(err, ErrorMatches, ".*tolerance on column ts.*not valid.*")
+
+	_, err = getColumnTolerances(tableInfo, map[string]*ColumnTolerance{"age": priceTolerance})
+	c.Assert(err, ErrorMatches, ".*not found.*")
+	_, err = getColumnTolerances(tableInfo, map[string]*ColumnTolerance{"name": priceTolerance})
+	c.Assert(err, ErrorMatches, ".*not supported.*")
+	_, err = getColumnTolerances(tableInfo, map[string]*ColumnTolerance{"id": priceTolerance})
+	c.Assert(err, ErrorMatches, ".*order key.*not supported.*")
+}
+
+func (s *testToleranceSuite) TestColumnToleranceEqual(c *C) {
+	tableInfo, err := dbutil.GetTableInfoBySQL("CREATE TABLE `t` (`id` int, `price` double, `amount` decimal(65,30), `rate` decimal(10,4), `ts` datetime(6), primary key(`id`))", parser.New())
+	c.Assert(err, IsNil)
+	timePrecision := 3
+	tolerances, err := getColumnTolerances(tableInfo, map[string]*ColumnTolerance{
+		"price":  {AbsoluteEpsilon: 0.01, RelativeEpsilon: 0.001},
+		"amount": {AbsoluteEpsilon: 0.01},
+		"rate":   {RelativeEpsilon: 0.001},
+		"ts":     {TimePrecision: &timePrecision},
+	})
+	c.Assert(err, IsNil)
+
+	data := func(value string) *dbutil.ColumnData {
+		return &dbutil.ColumnData{Data: []byte(value)}
+	}
+	null := &dbutil.ColumnData{IsNull: true}
+
+	testCases := []struct {
+		column string
+		data1  *dbutil.ColumnData
+		data2  *dbutil.ColumnData
+		equal  bool
+	}{
+		{"price", data("1.005"), data("1.014"), true},
+		{"price", data("1.005"), data("1.02"), false},
+		// relative epsilon
+		{"price", data("1000"), data("1000.9"), true},
+		{"price", data("1000"), data("1001.1"), false},
+		{"price", data("-1.5e10"), data("-1.5000001e10"), true},
+		{"price", null, null, true},
+		{"price", null, data("0"), false},
+		{"price", data("abc"), data("abc1"), false},
+		// the decimal values are compared exactly, they are same if converted to float
+		{"amount", data("12345678901234567890.005"), data("12345678901234567890.015"), true},
+		{"amount", data("12345678901234567890.005"), data("12345678901234567890.016"), false},
+		{"amount", data("123456789012345678901234567890.1"), data("123456789012345678901234567890.2"), false},
+		{"amount", data("0.000000000000000000000000000001"), data("0.000000000000000000000000000002"), true},
+		{"amount", data("-1000"), data("-1000.009"), true},
+		{"amount", data("abc"), data("1"), false},
+		{"rate", data("1000"), data("1000.9"), true},
+		{"rate", data("-1000"), data("-1001.1"), false},
+		{"rate", data("-1001.1"), data("-1000.1"), true},
+		{"ts", data("2021-01-01 10:00:00.123456"), data("2021-01-01 10:00:00.123"), true},
+		{"ts", data("2021-01-01 10:00:00.000999"), data("2021-01-01 10:00:00"), true},
+		{"ts", data("2021-01-01 10:00:00.124"), data("2021-01-01 10:00:00.123"), false},
+		{"ts", data("2021-01-01 10:00:01"), data("2021-01-01 10:00:00"), false},
+	}
+	for _, testCase := range testCases {
+		c.Assert(tolerances[testCase.column].equal(testCase.data1, testCase.data2), Equals, testCase.equal, Commentf("%s %s %s", testCase.column, testCase.data1.Data, testCase.data2.Data))
+	}
+
+	c.Assert(truncateFractionalSeconds("10:00:00.120", 6), Equals, "10:00:00.12")
+	c.Assert(truncateFractionalSeconds("10:00:00.999", 0), Equals, "10:00:00")
+	c.Assert(truncateFractionalSeconds("10:00:00", 3), Equals, "10:00:00")
+
+	// the rows are equal with tolerance
+	keyCols := []*model.ColumnInfo{tableInfo.Columns[0]}
+	row1 := map[string]*dbutil.ColumnData{"id": data("1"), "price": data("1.005"), "ts": data("2021-01-01 10:00:00.123456")}
+	row2 := map[string]*dbutil.ColumnData{"id": data("1"), "price": data("1.01"), "ts": data("2021-01-01 10:00:00.123")}
+	equal, _, diffColumns, err := compareData(row1, row2, keyCols, tolerances)
+	c.Assert(err, IsNil)
+	c.Assert(equal, IsTrue)
+	c.Assert(diffColumns, HasLen, 0)
+
+	equal, _, diffColumns, err = compareData(row1, row2, keyCols, nil)
+	c.Assert(err, IsNil)
+	c.Assert(equal, IsFalse)
+	c.Assert(diffColumns, HasLen, 2)
+}
@@ -97,6 +97,63 @@ func getColumnsFromIndex(index *model.IndexInfo, tableInfo *model.TableInfo) []*
 	return indexColumns
 }
 
+// hasUniqueIndex returns true if the table has primary key or unique key.
+func hasUniqueIndex(tableInfo *model.TableInfo) bool {
+	for _, index := range tableInfo.Indices {
+		if index.Primary || index.Unique {
+			return true
+		}
+	}
+
+	return false
+}
+
+// escapeString escapes the special characters in the string by the MySQL quoting rules, so it can be used in single quotes.
+// see https://dev.mysql.com/doc/refman/5.7/en/string-literals.html
+func escapeString(s string) string {
+	var buf strings.Builder
+	buf.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case 0:
+			buf.WriteString(`\0`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\'':
+			buf.WriteString(`\'`)
+		case '"':
+			buf.WriteString(`\"`)
+		case '\032':
+			buf.WriteString(`\Z`)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+
+	return buf.String()
+}
+
+// rowKeyColumns returns the columns of the primary key or unique key which locate the row in the table, returns nil if
+// the table has neither of them, or the row has NULL value in the unique key because it can't identify the row.
+func rowKeyColumns(tableInfo *model.TableInfo, row map[string]*dbutil.ColumnData) []*model.ColumnInfo {
+	if !hasUniqueIndex(tableInfo) {
+		return nil
+	}
+
+	_, keyCols := dbutil.SelectUniqueOrderKey(tableInfo)
+	for _, col := range keyCols {
+		if data, ok := row[col.Name.O]; !ok || data.IsNull {
+			return nil
+		}
+	}
+
+	return keyCols
+}
+
 func needQuotes(ft types.FieldType) bool {
 	return !(dbutil.IsNumberType(ft.Tp) || dbutil.IsFloatType(ft.Tp))
 }
@@ -73,6 +73,30 @@ func (s *testUtilSuite) TestRowContainsCols(c *C) {
 	c.Assert(contain, Equals, false)
 }
 
+func (s *testUtilSuite) TestRowKeyColumns(c *C) {
+	row := map[string]*dbutil.ColumnData{
+		"a": {Data: []byte("1")},
+		"b": {IsNull: true},
+		"c": {Data: []byte("1")},
+	}
+
+	tableInfo, err := dbutil.GetTableInfoBySQL("CREATE TABLE `test`.`atest` (`a` int, `b` int, `c` int, primary key(`a`))", parser.New())
+	c.Assert(err, IsNil)
+	keyCols := rowKeyColumns(tableInfo, row)
+	c.Assert(keyCols, HasLen, 1)
+	c.Assert(keyCols[0].Name.O, Equals, "a")
+
+	// the row can't be located by the unique key with NULL value
+	tableInfo, err = dbutil.GetTableInfoBySQL("CREATE TABLE `test`.`atest` (`a` int, `b` int, `c` int, unique key(`b`))", parser.New())
+	c.Assert(err, IsNil)
+	c.Assert(rowKeyColumns(tableInfo, row), IsNil)
+
+	// the order key of the table without primary key or unique key is not the identity of the row
+	tableInfo, err = dbutil.GetTableInfoBySQL("CREATE TABLE `test`.`atest` (`a` int, `b` int, `c` int, key(`a`))", parser.New())
+	c.Assert(err, IsNil)
+	c.Assert(rowKeyColumns(tableInfo, row), IsNil)
+}
+
 func (s *testUtilSuite) TestRowToString(c *C) {
 	row := make(map[string]*dbutil.ColumnData)
 	row["id"] = &dbutil.ColumnData{
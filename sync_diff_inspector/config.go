@@ -19,12 +19,17 @@ import (
 	"flag"
 	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/pingcap/errors"
 	"github.com/pingcap/log"
 	"github.com/pingcap/parser/model"
+	column "github.com/pingcap/tidb-tools/pkg/column-mapping"
 	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb-tools/pkg/diff"
+	tfilter "github.com/pingcap/tidb-tools/pkg/table-filter"
 	router "github.com/pingcap/tidb-tools/pkg/table-router"
 	"go.uber.org/zap"
 )
@@ -42,7 +47,12 @@ type DBConfig struct {
 
 	InstanceID string `toml:"instance-id" json:"instance-id"`
 
+	// limits the rate of queries and rows, and pauses checking when the database is overloaded
+	Throttle diff.ThrottleConfig `toml:"throttle" json:"throttle"`
+
 	Conn *sql.DB
+
+	Throttler *diff.Throttler `toml:"-" json:"-"`
 }
 
 // Valid returns true if database's config is valide.
@@ -51,11 +61,24 @@ func (c *DBConfig) Valid() bool {
 		log.Error("must specify source database's instance id")
 		return false
 	}
+	if !c.validThrottle() {
+		return false
+	}
 	sourceInstanceMap[c.InstanceID] = struct{}{}
 
 	return true
 }
 
+// validThrottle returns true if the throttle config is valid.
+func (c *DBConfig) validThrottle() bool {
+	if c.Throttle.QueriesPerSecond < 0 || c.Throttle.RowsPerSecond < 0 || c.Throttle.MaxThreadsRunning < 0 || c.Throttle.MaxReplicationLag < 0 {
+		log.Error("throttle config should not be negative", zap.String("instance id", c.InstanceID), zap.Reflect("throttle", c.Throttle))
+		return false
+	}
+
+	return true
+}
+
 // CheckTables saves the tables need to check.
 type CheckTables struct {
 	// schema name
@@ -88,6 +111,89 @@ type TableConfig struct {
 
 	// collation config in mysql/tidb
 	Collation string `toml:"collation"`
+
+	// the strategy used to calculate checksum, support crc32 and md5, md5 can detect duplicate rows but is slower
+	ChecksumStrategy string `toml:"checksum-strategy"`
+
+	// the column saves the row's update time, will only check the rows updated since the last check if is set
+	UpdateTimeColumn string `toml:"update-time-column"`
+
+	// the column mapping rules used to migrate the source tables, the source rows are mapped by them before compared with target
+	ColumnMappingRules []*ColumnMappingRule `toml:"column-mapping-rules"`
+	// source instance id => the column mapping of the source tables in this instance
+	columnMappings map[string]*column.Mapping
+
+	// column name => the difference allowed when compare the FLOAT, DOUBLE, DECIMAL or time column, "*" means all these columns
+	ColumnTolerances map[string]*diff.ColumnTolerance `toml:"column-tolerances"`
+
+	// source column name => target column name, for the columns renamed in target
+	RenamedColumns map[string]string `toml:"renamed-columns"`
+	// target column name => the expected value in all the rows, for the columns only exist in target
+	TargetOnlyColumns map[string]*diff.TargetOnlyColumn `toml:"target-only-columns"`
+
+	// the columns identify a row, used as the primary key to order and split the rows, must be set if compare with a query and the target table has no primary key,
+	// can't be set if the target table has primary key or unique key, the rows are identified by the table's key
+	KeyColumns []string `toml:"key-columns"`
+}
+
+// ColumnMappingRule is the column mapping rule of the source tables.
+type ColumnMappingRule struct {
+	column.Rule
+	// only map the source tables in this instance, map the source tables in all instances if is empty
+	InstanceID string `toml:"instance-id" json:"instance-id"`
+}
+
+// buildColumnMappings builds the column mapping for every source instance by the column mapping rules.
+// only one rule can be applied to a source table, returns error if a source table matches more than one rules.
+func (t *TableConfig) buildColumnMappings() error {
+	if len(t.ColumnMappingRules) == 0 {
+		return nil
+	}
+
+	t.columnMappings = make(map[string]*column.Mapping)
+	for _, sourceTable := range t.SourceTables {
+		if mapping, ok := t.columnMappings[sourceTable.InstanceID]; ok {
+			if err := checkColumnMappingRules(mapping, sourceTable); err != nil {
+				return errors.Annotatef(err, "build column mapping of table %s", dbutil.TableName(t.Schema, t.Table))
+			}
+			continue
+		}
+
+		rules := make([]*column.Rule, 0, len(t.ColumnMappingRules))
+		for _, rule := range t.ColumnMappingRules {
+			if len(rule.InstanceID) != 0 && rule.InstanceID != sourceTable.InstanceID {
+				continue
+			}
+			// the rule is applied to all the source tables if the patterns are not set
+			columnRule := rule.Rule
+			if len(columnRule.PatternSchema) == 0 {
+				columnRule.PatternSchema = "*"
+			}
+			rules = append(rules, &columnRule)
+		}
+
+		mapping, err := column.NewMapping(false, rules)
+		if err == nil {
+			err = checkColumnMappingRules(mapping, sourceTable)
+		}
+		if err != nil {
+			return errors.Annotatef(err, "build column mapping of table %s for instance %s", dbutil.TableName(t.Schema, t.Table), sourceTable.InstanceID)
+		}
+		t.columnMappings[sourceTable.InstanceID] = mapping
+	}
+
+	return nil
+}
+
+// checkColumnMappingRules checks the source table matches at most one rule in the mapping, the mapping only applies one
+// rule to a table, and prefers the rule with table pattern, the other rules will be dropped silently.
+func checkColumnMappingRules(mapping *column.Mapping, sourceTable TableInstance) error {
+	rules := mapping.Match(strings.ToLower(sourceTable.Schema), strings.ToLower(sourceTable.Table))
+	if len(rules) > 1 {
+		return errors.NotSupportedf("source table %s in instance %s matches %d column mapping rules, only one rule can be applied to a table, it's", dbutil.TableName(sourceTable.Schema, sourceTable.Table), sourceTable.InstanceID, len(rules))
+	}
+
+	return nil
 }
 
 // Valid returns true if table's config is valide.
@@ -116,6 +222,33 @@ func (t *TableConfig) Valid() bool {
 		}
 	}
 
+	switch t.ChecksumStrategy {
+	case "", diff.CRC32ChecksumStrategy, diff.MD5ChecksumStrategy:
+	default:
+		log.Error("checksum-strategy only support crc32 and md5", zap.String("checksum-strategy", t.ChecksumStrategy))
+		return false
+	}
+
+	for name, tolerance := range t.ColumnTolerances {
+		if tolerance == nil || tolerance.AbsoluteEpsilon < 0 || tolerance.RelativeEpsilon < 0 || (tolerance.TimePrecision != nil && *tolerance.TimePrecision < 0) {
+			log.Error("column tolerance can't be negative", zap.String("column", name), zap.Reflect("tolerance", tolerance))
+			return false
+		}
+	}
+
+	for _, rule := range t.ColumnMappingRules {
+		if err := rule.Valid(); err != nil {
+			log.Error("invalid column mapping rule", zap.Reflect("rule", rule), zap.Error(err))
+			return false
+		}
+		if len(rule.InstanceID) != 0 {
+			if _, ok := sourceInstanceMap[rule.InstanceID]; !ok {
+				log.Error("unknown database instance id in column mapping rule", zap.String("instance id", rule.InstanceID))
+				return false
+			}
+		}
+	}
+
 	return true
 }
 
@@ -127,6 +260,8 @@ type TableInstance struct {
 	Schema string `toml:"schema"`
 	// table name
 	Table string `toml:"table"`
+	// the query compared with the target table, the schema and table are not needed if is set, only valid for source tables
+	Query string `toml:"query"`
 }
 
 // Valid returns true if table instance's info is valide.
@@ -142,6 +277,10 @@ func (t *TableInstance) Valid() bool {
 		return false
 	}
 
+	if t.Query != "" {
+		return true
+	}
+
 	if t.Schema == "" || t.Table == "" {
 		log.Error("schema and table's name can't be empty")
 		return false
@@ -175,18 +314,92 @@ type Config struct {
 	// how many goroutines are created to check data
 	CheckThreadCount int `toml:"check-thread-count" json:"check-thread-count"`
 
+	// how many tables are checked concurrently, the chunks of all the checking tables share the check-thread-count goroutines
+	TableCheckThreadCount int `toml:"table-check-thread-count" json:"table-check-thread-count"`
+
 	// set false if want to comapre the data directly
 	UseChecksum bool `toml:"use-checksum" json:"use-checksum"`
 
 	// set true if just want compare data by checksum, will skip select data when checksum is not equal.
 	OnlyUseChecksum bool `toml:"only-use-checksum" json:"only-use-checksum"`
 
+	// set true will compare the row count before checksum, will skip checksum and select data directly when row count is not equal.
+	UseRowCount bool `toml:"use-row-count" json:"use-row-count"`
+
+	// set true if just want to compare the row count, used to check append-only tables quickly.
+	OnlyUseRowCount bool `toml:"only-use-row-count" json:"only-use-row-count"`
+
+	// set true will split the chunk whose checksum is not equal into sub ranges recursively, and only compare rows in the mismatching sub ranges.
+	UseBisect bool `toml:"use-bisect" json:"use-bisect"`
+
+	// the max times to split a mismatching chunk, only valid when use-bisect is true.
+	BisectMaxDepth int `toml:"bisect-max-depth" json:"bisect-max-depth"`
+
 	// the name of the file which saves sqls used to fix different data
 	FixSQLFile string `toml:"fix-sql-file" json:"fix-sql-file"`
 
+	// the directory which saves the fix sqls and the revert sqls in a file per table, every check creates a sub directory in it, will use fix-sql-file if is empty
+	FixSQLDir string `toml:"fix-sql-dir" json:"fix-sql-dir"`
+
+	// set true will execute the fix sqls on target database, and then check the fixed data again
+	ApplyFixSQL bool `toml:"apply-fix-sql" json:"apply-fix-sql"`
+
+	// set true will only print the fix sqls instead of executing them, only valid when apply-fix-sql is true
+	FixSQLDryRun bool `toml:"fix-sql-dry-run" json:"fix-sql-dry-run"`
+
+	// will stop applying fix sqls for the table if the num of different rows (inserted, deleted and updated) is greater than it, 0 means no limit
+	MaxFixRowNum int64 `toml:"max-fix-row-num" json:"max-fix-row-num"`
+
+	// the num of fix sqls executed in one transaction
+	FixSQLBatchSize int `toml:"fix-sql-batch-size" json:"fix-sql-batch-size"`
+
+	// the name of the file which saves the different rows, will not save them if is empty
+	DiffRowFile string `toml:"diff-row-file" json:"diff-row-file"`
+
+	// the format of the different rows file, support "csv" and "ndjson"
+	DiffRowFormat string `toml:"diff-row-format" json:"diff-row-format"`
+
+	// the name of the file which saves the check report, will not write report file if is empty
+	ReportFile string `toml:"report-file" json:"report-file"`
+
+	// the format of the report file, support "json" and "junit"
+	ReportFormat string `toml:"report-format" json:"report-format"`
+
+	// the interval to check the data periodically, for example "10m", only check once if is empty
+	CheckInterval string `toml:"check-interval" json:"check-interval"`
+	checkInterval time.Duration
+
+	// the interval to print the check progress, for example "1m", will not print it if is empty
+	ProgressInterval string `toml:"progress-interval" json:"progress-interval"`
+	progressInterval time.Duration
+
+	// checking chunks is only allowed in these time windows of every day in local time, for example ["01:00-06:00"], always allowed if is empty
+	CheckTimeWindows []string `toml:"check-time-windows" json:"check-time-windows"`
+	// pauses checking chunks out of the time windows, or when paused by signal or http
+	pauser *diff.Pauser
+
+	// the address of the http server which serves the check progress and the prometheus metrics, for example "127.0.0.1:8080", will not start it if is empty
+	StatusAddr string `toml:"status-addr" json:"status-addr"`
+	// the token required by pausing and resuming by http, only the requests from localhost are allowed if is empty
+	StatusToken string `toml:"status-token" json:"-"`
+
+	// read the tso mapping of source and target from target, and set the snapshot of them by it, support "drainer-checkpoint" and "syncpoint"
+	TSOMapping string `toml:"tso-mapping" json:"tso-mapping"`
+
+	// the table saves the tso mapping in target, will use `tidb_binlog`.`checkpoint` for drainer-checkpoint and `tidb_cdc`.`syncpoint_v1` for syncpoint if is empty
+	TSOMappingTable string `toml:"tso-mapping-table" json:"tso-mapping-table"`
+
+	// the id of the changefeed replicating the data to target, only read its syncpoints, must be set for syncpoint
+	TSOMappingChangefeedID string `toml:"tso-mapping-changefeed-id" json:"tso-mapping-changefeed-id"`
+
 	// the tables to be checked
 	Tables []*CheckTables `toml:"check-tables" json:"check-tables"`
 
+	// the tables to be checked in table-filter rules, for example ["db*.tbl*", "!db1.tmp_*", "@filter.txt"], is an
+	// alternative to check-tables and matches the table names in target case-insensitively
+	TableFilter []string `toml:"table-filter" json:"table-filter"`
+	tableFilter tfilter.Filter
+
 	// TableRules defines table name and database name's conversion relationship between source database and target database
 	TableRules []*router.TableRule `toml:"table-rules" json:"table-rules"`
 
@@ -196,6 +409,13 @@ type Config struct {
 	// ignore check table's struct
 	IgnoreStructCheck bool `toml:"ignore-struct-check" json:"ignore-struct-check"`
 
+	// set true will write the DDLs converge the target's struct to the source to the fix sql file as comments
+	WriteStructDDL bool `toml:"write-struct-ddl" json:"write-struct-ddl"`
+
+	// set true will list the schemas, tables and views only exist in source or target as failures, the schemas in check-tables
+	// or table-filter are compared after routed by table-rules, and the tables in exclude-tables or not matched are ignored
+	CheckInventory bool `toml:"check-inventory" json:"check-inventory"`
+
 	// ignore tidb stats only use randomSpliter to split chunks
 	IgnoreStats bool `toml:"ignore-stats" json:"ignore-stats"`
 
@@ -205,6 +425,13 @@ type Config struct {
 	// set true will continue check from the latest checkpoint
 	UseCheckpoint bool `toml:"use-checkpoint" json:"use-checkpoint"`
 
+	// where to save the checkpoint, support "sql" and "file", "sql" saves it in the schema `sync_diff_inspector` of target database,
+	// "file" saves it in checkpoint-file, can be used when can't write the target database
+	CheckpointStore string `toml:"checkpoint-store" json:"checkpoint-store"`
+
+	// the file which saves the checkpoint, only valid when checkpoint-store is "file"
+	CheckpointFile string `toml:"checkpoint-file" json:"checkpoint-file"`
+
 	// DMAddr is dm-master's address, the format should like "http://127.0.0.1:8261"
 	DMAddr string `toml:"dm-addr" json:"dm-addr"`
 	// DMTask is dm's task name
@@ -215,6 +442,9 @@ type Config struct {
 
 	// print version if set true
 	PrintVersion bool
+
+	// print how the check will continue from the checkpoint and exit if set true
+	CheckpointInspect bool
 }
 
 // NewConfig creates a new config.
@@ -228,13 +458,39 @@ func NewConfig() *Config {
 	fs.IntVar(&cfg.ChunkSize, "chunk-size", 1000, "diff check chunk size")
 	fs.IntVar(&cfg.Sample, "sample", 100, "the percent of sampling check")
 	fs.IntVar(&cfg.CheckThreadCount, "check-thread-count", 1, "how many goroutines are created to check data")
+	fs.IntVar(&cfg.TableCheckThreadCount, "table-check-thread-count", 1, "how many tables are checked concurrently")
 	fs.BoolVar(&cfg.UseChecksum, "use-checksum", true, "set false if want to comapre the data directly")
+	fs.BoolVar(&cfg.UseRowCount, "use-row-count", false, "set true will compare the row count before checksum")
+	fs.BoolVar(&cfg.OnlyUseRowCount, "only-use-row-count", false, "set true if just want to compare the row count")
+	fs.BoolVar(&cfg.UseBisect, "use-bisect", false, "set true will split the chunk whose checksum is not equal into sub ranges recursively, and only compare rows in the mismatching sub ranges")
+	fs.IntVar(&cfg.BisectMaxDepth, "bisect-max-depth", 4, "the max times to split a mismatching chunk")
 	fs.StringVar(&cfg.FixSQLFile, "fix-sql-file", "fix.sql", "the name of the file which saves sqls used to fix different data")
+	fs.StringVar(&cfg.FixSQLDir, "fix-sql-dir", "", "the directory which saves the fix sqls and the revert sqls in a file per table, every check creates a sub directory in it, will use fix-sql-file if is empty")
+	fs.BoolVar(&cfg.ApplyFixSQL, "apply-fix-sql", false, "set true will execute the fix sqls on target database")
+	fs.BoolVar(&cfg.FixSQLDryRun, "fix-sql-dry-run", false, "set true will only print the fix sqls instead of executing them")
+	fs.Int64Var(&cfg.MaxFixRowNum, "max-fix-row-num", 10000, "will stop applying fix sqls for the table if the num of different rows (inserted, deleted and updated) is greater than it, 0 means no limit")
+	fs.IntVar(&cfg.FixSQLBatchSize, "fix-sql-batch-size", 100, "the num of fix sqls executed in one transaction")
+	fs.StringVar(&cfg.DiffRowFile, "diff-row-file", "", "the name of the file which saves the different rows")
+	fs.StringVar(&cfg.DiffRowFormat, "diff-row-format", "csv", "the format of the different rows file, support csv and ndjson")
+	fs.StringVar(&cfg.ReportFile, "report-file", "", "the name of the file which saves the check report")
+	fs.StringVar(&cfg.ReportFormat, "report-format", "json", "the format of the report file, support json and junit")
+	fs.StringVar(&cfg.CheckInterval, "check-interval", "", "the interval to check the data periodically, for example 10m, only check once if is empty")
+	fs.StringVar(&cfg.ProgressInterval, "progress-interval", "1m", "the interval to print the check progress, will not print it if is empty")
+	fs.StringVar(&cfg.StatusAddr, "status-addr", "", "the address of the http server which serves the check progress and the prometheus metrics, will not start it if is empty")
+	fs.StringVar(&cfg.StatusToken, "status-token", "", "the token required by pausing and resuming by http in the header \"Authorization: Bearer <token>\", only the requests from localhost are allowed if is empty")
+	fs.StringVar(&cfg.TSOMapping, "tso-mapping", "", "read the tso mapping of source and target from target, and set the snapshot of them by it, support drainer-checkpoint and syncpoint")
+	fs.StringVar(&cfg.TSOMappingTable, "tso-mapping-table", "", "the table saves the tso mapping in target")
+	fs.StringVar(&cfg.TSOMappingChangefeedID, "tso-mapping-changefeed-id", "", "the id of the changefeed replicating the data to target, must be set for syncpoint")
 	fs.BoolVar(&cfg.PrintVersion, "V", false, "print version of sync_diff_inspector")
 	fs.BoolVar(&cfg.IgnoreDataCheck, "ignore-data-check", false, "ignore check table's data")
 	fs.BoolVar(&cfg.IgnoreStructCheck, "ignore-struct-check", false, "ignore check table's struct")
+	fs.BoolVar(&cfg.WriteStructDDL, "write-struct-ddl", false, "set true will write the DDLs converge the target's struct to the source to the fix sql file as comments")
+	fs.BoolVar(&cfg.CheckInventory, "check-inventory", false, "set true will list the schemas, tables and views only exist in source or target as failures")
 	fs.BoolVar(&cfg.IgnoreStats, "ignore-stats", false, "don't use tidb stats to split chunks")
 	fs.BoolVar(&cfg.UseCheckpoint, "use-checkpoint", true, "set true will continue check from the latest checkpoint")
+	fs.StringVar(&cfg.CheckpointStore, "checkpoint-store", "sql", "where to save the checkpoint, support sql and file")
+	fs.StringVar(&cfg.CheckpointFile, "checkpoint-file", "sync_diff_checkpoint.json", "the file which saves the checkpoint, only valid when checkpoint-store is file")
+	fs.BoolVar(&cfg.CheckpointInspect, "checkpoint-inspect", false, "print how the check of every table will continue from the checkpoint, and then exit")
 
 	return cfg
 }
@@ -299,6 +555,11 @@ func (c *Config) checkConfig() bool {
 		return false
 	}
 
+	if c.TableCheckThreadCount <= 0 {
+		log.Error("table-check-thread-count must greater than 0!")
+		return false
+	}
+
 	if len(c.DMAddr) != 0 {
 		u, err := url.Parse(c.DMAddr)
 		if err != nil || u.Scheme == "" || u.Host == "" {
@@ -318,8 +579,8 @@ func (c *Config) checkConfig() bool {
 			return false
 		}
 
-		if len(c.Tables) != 0 || len(c.TableRules) != 0 || len(c.TableCfgs) != 0 {
-			log.Error("should not set `check-tables`, `table-rules` or `table-config`, diff will generate them automatically when set `dm-addr` and `dm-task`")
+		if len(c.Tables) != 0 || len(c.TableFilter) != 0 || len(c.TableRules) != 0 || len(c.TableCfgs) != 0 {
+			log.Error("should not set `check-tables`, `table-filter`, `table-rules` or `table-config`, diff will generate them automatically when set `dm-addr` and `dm-task`")
 			return false
 		}
 	} else {
@@ -343,15 +604,30 @@ func (c *Config) checkConfig() bool {
 		if c.TargetDBCfg.Snapshot != "" {
 			c.TargetDBCfg.Snapshot = strconv.Quote(c.TargetDBCfg.Snapshot)
 		}
+		if !c.TargetDBCfg.validThrottle() {
+			return false
+		}
 		if _, ok := sourceInstanceMap[c.TargetDBCfg.InstanceID]; ok {
 			log.Error("target has same instance id in source", zap.String("instance id", c.TargetDBCfg.InstanceID))
 			return false
 		}
 
-		if len(c.Tables) == 0 {
-			log.Error("must specify check tables")
+		if len(c.Tables) == 0 && len(c.TableFilter) == 0 {
+			log.Error("must specify check tables or table filter")
+			return false
+		}
+		if len(c.Tables) != 0 && len(c.TableFilter) != 0 {
+			log.Error("should not set both `check-tables` and `table-filter`")
 			return false
 		}
+		if len(c.TableFilter) != 0 {
+			f, err := tfilter.Parse(c.TableFilter)
+			if err != nil {
+				log.Error("table-filter is invalid", zap.Strings("table filter", c.TableFilter), zap.Error(err))
+				return false
+			}
+			c.tableFilter = tfilter.CaseInsensitive(f)
+		}
 
 		for _, tableCfg := range c.TableCfgs {
 			if !tableCfg.Valid() {
@@ -360,13 +636,135 @@ func (c *Config) checkConfig() bool {
 		}
 	}
 
+	if len(c.DiffRowFile) != 0 {
+		if c.DiffRowFormat == "" {
+			c.DiffRowFormat = diff.CSVSinkFormat
+		}
+		if c.DiffRowFormat != diff.CSVSinkFormat && c.DiffRowFormat != diff.NDJSONSinkFormat {
+			log.Error("diff-row-format must be csv or ndjson", zap.String("diff row format", c.DiffRowFormat))
+			return false
+		}
+	}
+
+	if len(c.ReportFile) != 0 {
+		if c.ReportFormat == "" {
+			c.ReportFormat = JSONFormat
+		}
+		if c.ReportFormat != JSONFormat && c.ReportFormat != JUnitFormat {
+			log.Error("report-format must be json or junit", zap.String("report format", c.ReportFormat))
+			return false
+		}
+	}
+
+	if len(c.CheckInterval) != 0 {
+		interval, err := time.ParseDuration(c.CheckInterval)
+		if err != nil || interval <= 0 {
+			log.Error("check-interval is invalid, should be a positive duration like 10m", zap.String("check interval", c.CheckInterval))
+			return false
+		}
+		c.checkInterval = interval
+	}
+
+	windows := make([]*diff.TimeWindow, 0, len(c.CheckTimeWindows))
+	for _, s := range c.CheckTimeWindows {
+		window, err := diff.ParseTimeWindow(s)
+		if err != nil {
+			log.Error("check-time-windows is invalid, should be like 01:00-06:00", zap.String("time window", s), zap.Error(err))
+			return false
+		}
+		windows = append(windows, window)
+	}
+	c.pauser = diff.NewPauser(windows)
+
+	switch c.CheckpointStore {
+	case "":
+		c.CheckpointStore = diff.SQLCheckpointStore
+	case diff.SQLCheckpointStore:
+	case diff.FileCheckpointStore:
+		if len(c.CheckpointFile) == 0 {
+			log.Error("must set checkpoint-file when checkpoint-store is file")
+			return false
+		}
+	default:
+		log.Error("checkpoint-store must be sql or file", zap.String("checkpoint store", c.CheckpointStore))
+		return false
+	}
+
+	if len(c.ProgressInterval) != 0 {
+		interval, err := time.ParseDuration(c.ProgressInterval)
+		if err != nil || interval <= 0 {
+			log.Error("progress-interval is invalid, should be a positive duration like 1m", zap.String("progress interval", c.ProgressInterval))
+			return false
+		}
+		c.progressInterval = interval
+	}
+
+	if len(c.TSOMapping) != 0 {
+		if c.TSOMapping != DrainerCheckpointTSOMapping && c.TSOMapping != SyncpointTSOMapping {
+			log.Error("tso-mapping must be drainer-checkpoint or syncpoint", zap.String("tso mapping", c.TSOMapping))
+			return false
+		}
+		if c.TSOMapping == SyncpointTSOMapping && len(c.TSOMappingChangefeedID) == 0 {
+			log.Error("tso-mapping-changefeed-id must be set for syncpoint, the syncpoint table may save the syncpoints of many changefeeds")
+			return false
+		}
+		if len(c.DMAddr) != 0 || len(c.SourceDBCfg) != 1 {
+			log.Error("tso-mapping only support one source database")
+			return false
+		}
+		if c.SourceDBCfg[0].Snapshot != "" || c.TargetDBCfg.Snapshot != "" {
+			log.Error("should not set snapshot when tso-mapping is set, diff will set it automatically")
+			return false
+		}
+	}
+
+	if c.ApplyFixSQL {
+		if c.OnlyUseChecksum {
+			log.Error("can't apply fix sqls when only-use-checksum is true, because will not generate fix sqls")
+			return false
+		}
+		if c.OnlyUseRowCount {
+			log.Error("can't apply fix sqls when only-use-row-count is true, because will not generate fix sqls")
+			return false
+		}
+		if c.TargetDBCfg.Snapshot != "" || len(c.TSOMapping) != 0 {
+			log.Error("can't apply fix sqls on target database with snapshot")
+			return false
+		}
+		if c.MaxFixRowNum < 0 {
+			log.Error("max-fix-row-num must be greater than or equal to 0")
+			return false
+		}
+		if c.FixSQLBatchSize <= 0 {
+			log.Warn("fix-sql-batch-size is invalid, will use default value 100")
+			c.FixSQLBatchSize = 100
+		}
+	}
+
+	if c.UseBisect {
+		if !c.UseChecksum {
+			log.Error("need set use-checksum = true when use-bisect is true")
+			return false
+		}
+		if c.BisectMaxDepth <= 0 {
+			log.Error("bisect-max-depth must be greater than 0")
+			return false
+		}
+	}
+
 	if c.OnlyUseChecksum {
 		if !c.UseChecksum {
 			log.Error("need set use-checksum = true")
 			return false
 		}
+		for _, tableCfg := range c.TableCfgs {
+			if len(tableCfg.ColumnTolerances) != 0 {
+				log.Error("can't set column-tolerances when only-use-checksum is true, because the columns with tolerance can't be compared by checksum", zap.String("table", dbutil.TableName(tableCfg.Schema, tableCfg.Table)))
+				return false
+			}
+		}
 	} else {
-		if len(c.FixSQLFile) == 0 {
+		if len(c.FixSQLDir) == 0 && len(c.FixSQLFile) == 0 {
 			log.Warn("fix-sql-file is invalid, will use default value 'fix.sql'")
 			c.FixSQLFile = "fix.sql"
 		}
@@ -20,6 +20,7 @@ import (
 	"testing"
 
 	. "github.com/pingcap/check"
+	"github.com/pingcap/tidb-tools/pkg/diff"
 )
 
 func TestClient(t *testing.T) {
@@ -93,3 +94,262 @@ func (s *testConfigSuite) TestUnknownFlagOrItem(c *C) {
 	err = cfg.Parse([]string{"-config", path})
 	c.Assert(err, ErrorMatches, ".*table_rules.*")
 }
+
+func (s *testConfigSuite) TestColumnMappingRules(c *C) {
+	path := filepath.Join(c.MkDir(), "column_mapping.toml")
+	content := `
+[[table-config]]
+schema = "test"
+table = "t"
+
+[[table-config.column-mapping-rules]]
+instance-id = "source-1"
+schema-pattern = "test_*"
+table-pattern = "t_*"
+target-column = "id"
+expression = "partition id"
+arguments = ["1", "test", "t", "_"]
+
+[[table-config.column-mapping-rules]]
+target-column = "name"
+expression = "add prefix"
+arguments = ["source:"]
+`
+	c.Assert(ioutil.WriteFile(path, []byte(content), 0644), IsNil)
+
+	cfg := NewConfig()
+	c.Assert(cfg.Parse([]string{"-config", path}), IsNil)
+	c.Assert(cfg.TableCfgs, HasLen, 1)
+	table := cfg.TableCfgs[0]
+	c.Assert(table.ColumnMappingRules, HasLen, 2)
+	c.Assert(table.ColumnMappingRules[0].InstanceID, Equals, "source-1")
+	c.Assert(string(table.ColumnMappingRules[0].Expression), Equals, "partition id")
+
+	// the rule without instance id and patterns is applied to all the source tables
+	table.SourceTables = []TableInstance{
+		{InstanceID: "source-1", Schema: "other", Table: "t_1"},
+		{InstanceID: "source-2", Schema: "test_2", Table: "t_1"},
+	}
+	c.Assert(table.buildColumnMappings(), IsNil)
+	c.Assert(table.columnMappings, HasLen, 2)
+
+	rule, err := table.columnMappings["source-1"].QueryRule("other", "t_1")
+	c.Assert(err, IsNil)
+	c.Assert(rule.TargetColumn, Equals, "name")
+	rule, err = table.columnMappings["source-2"].QueryRule("test_2", "t_1")
+	c.Assert(err, IsNil)
+	c.Assert(rule.TargetColumn, Equals, "name")
+
+	// the source table in source-1 matches both rules, one of them would be dropped
+	table.SourceTables = []TableInstance{
+		{InstanceID: "source-1", Schema: "other", Table: "t_1"},
+		{InstanceID: "source-1", Schema: "test_1", Table: "t_1"},
+	}
+	c.Assert(table.buildColumnMappings(), ErrorMatches, ".*source table `test_1`.`t_1` in instance source-1 matches 2 column mapping rules.*")
+
+	// only the rule with patterns is applied to the source tables in source-1
+	table.ColumnMappingRules[1].InstanceID = "source-2"
+	c.Assert(table.buildColumnMappings(), IsNil)
+	rule, err = table.columnMappings["source-1"].QueryRule("test_1", "t_1")
+	c.Assert(err, IsNil)
+	c.Assert(rule.TargetColumn, Equals, "id")
+}
+
+func (s *testConfigSuite) TestColumnTolerances(c *C) {
+	path := filepath.Join(c.MkDir(), "column_tolerances.toml")
+	content := `
+table-filter = ["test.*"]
+
+[[source-db]]
+instance-id = "source-1"
+
+[[table-config]]
+schema = "test"
+table = "t"
+
+[table-config.column-tolerances.price]
+absolute-epsilon = 0.01
+relative-epsilon = 0.0001
+
+[table-config.column-tolerances."*"]
+time-precision = 3
+`
+	c.Assert(ioutil.WriteFile(path, []byte(content), 0644), IsNil)
+
+	cfg := NewConfig()
+	c.Assert(cfg.Parse([]string{"-config", path}), IsNil)
+	c.Assert(cfg.TableCfgs, HasLen, 1)
+	table := cfg.TableCfgs[0]
+	timePrecision := 3
+	c.Assert(table.ColumnTolerances, DeepEquals, map[string]*diff.ColumnTolerance{
+		"price":                  {AbsoluteEpsilon: 0.01, RelativeEpsilon: 0.0001},
+		diff.AllColumnsTolerance: {TimePrecision: &timePrecision},
+	})
+	c.Assert(table.Valid(), IsTrue)
+
+	// the columns with tolerance can't be compared by checksum
+	cfg.UseChecksum, cfg.OnlyUseChecksum = true, true
+	c.Assert(cfg.checkConfig(), IsFalse)
+	cfg.OnlyUseChecksum = false
+	c.Assert(cfg.checkConfig(), IsTrue)
+
+	table.ColumnTolerances["price"].AbsoluteEpsilon = -1
+	c.Assert(table.Valid(), IsFalse)
+	table.ColumnTolerances["price"].AbsoluteEpsilon = 0.01
+	*table.ColumnTolerances[diff.AllColumnsTolerance].TimePrecision = -1
+	c.Assert(table.Valid(), IsFalse)
+}
+
+func (s *testConfigSuite) TestColumnNames(c *C) {
+	path := filepath.Join(c.MkDir(), "column_names.toml")
+	content := `
+[[table-config]]
+schema = "test"
+table = "t"
+
+[table-config.renamed-columns]
+name = "user_name"
+
+[table-config.target-only-columns.status]
+value = "1"
+
+[table-config.target-only-columns.remark]
+is-null = true
+`
+	c.Assert(ioutil.WriteFile(path, []byte(content), 0644), IsNil)
+
+	cfg := NewConfig()
+	c.Assert(cfg.Parse([]string{"-config", path}), IsNil)
+	c.Assert(cfg.TableCfgs, HasLen, 1)
+	table := cfg.TableCfgs[0]
+	c.Assert(table.RenamedColumns, DeepEquals, map[string]string{"name": "user_name"})
+	c.Assert(table.TargetOnlyColumns, DeepEquals, map[string]*diff.TargetOnlyColumn{
+		"status": {Value: "1"},
+		"remark": {IsNull: true},
+	})
+}
+
+func (s *testConfigSuite) TestQuerySourceTable(c *C) {
+	path := filepath.Join(c.MkDir(), "query.toml")
+	content := "[[table-config]]\n" +
+		"schema = \"test\"\n" +
+		"table = \"daily_orders\"\n" +
+		"key-columns = [\"day\"]\n" +
+		"[[table-config.source-tables]]\n" +
+		"instance-id = \"source-1\"\n" +
+		"query = \"SELECT `day`, COUNT(*) AS `cnt` FROM `test`.`orders` GROUP BY `day`\"\n"
+	c.Assert(ioutil.WriteFile(path, []byte(content), 0644), IsNil)
+
+	cfg := NewConfig()
+	c.Assert(cfg.Parse([]string{"-config", path}), IsNil)
+	c.Assert(cfg.TableCfgs, HasLen, 1)
+	table := cfg.TableCfgs[0]
+	c.Assert(table.KeyColumns, DeepEquals, []string{"day"})
+	c.Assert(table.SourceTables, HasLen, 1)
+	c.Assert(table.SourceTables[0].Query, Equals, "SELECT `day`, COUNT(*) AS `cnt` FROM `test`.`orders` GROUP BY `day`")
+
+	// the schema and table are not needed for query
+	origInstanceMap := sourceInstanceMap
+	sourceInstanceMap = map[string]interface{}{"source-1": struct{}{}}
+	defer func() {
+		sourceInstanceMap = origInstanceMap
+	}()
+	c.Assert(table.SourceTables[0].Valid(), IsTrue)
+	c.Assert((&TableInstance{InstanceID: "source-1", Schema: "test"}).Valid(), IsFalse)
+}
+
+func (s *testConfigSuite) TestThrottle(c *C) {
+	path := filepath.Join(c.MkDir(), "throttle.toml")
+	content := `
+[[source-db]]
+host = "127.0.0.1"
+port = 3306
+instance-id = "source-1"
+
+[source-db.throttle]
+queries-per-second = 50.0
+rows-per-second = 100000.0
+max-threads-running = 30
+max-replication-lag = 10
+`
+	c.Assert(ioutil.WriteFile(path, []byte(content), 0644), IsNil)
+
+	cfg := NewConfig()
+	c.Assert(cfg.Parse([]string{"-config", path}), IsNil)
+	c.Assert(cfg.SourceDBCfg, HasLen, 1)
+	source := cfg.SourceDBCfg[0]
+	c.Assert(source.Throttle, DeepEquals, diff.ThrottleConfig{
+		QueriesPerSecond:  50,
+		RowsPerSecond:     100000,
+		MaxThreadsRunning: 30,
+		MaxReplicationLag: 10,
+	})
+	c.Assert(source.validThrottle(), IsTrue)
+
+	source.Throttle.MaxReplicationLag = -1
+	c.Assert(source.validThrottle(), IsFalse)
+}
+
+func (s *testConfigSuite) TestCheckTimeWindows(c *C) {
+	cfg := NewConfig()
+	cfg.DMAddr = "http://127.0.0.1:8261"
+	cfg.DMTask = "test"
+	c.Assert(cfg.checkConfig(), IsTrue)
+	c.Assert(cfg.pauser, NotNil)
+	c.Assert(cfg.pauser.PauseReason(), Equals, "")
+
+	cfg.CheckTimeWindows = []string{"01:00-06:00", "22:00-23:30"}
+	c.Assert(cfg.checkConfig(), IsTrue)
+	c.Assert(cfg.pauser, NotNil)
+
+	cfg.CheckTimeWindows = []string{"01:00~06:00"}
+	c.Assert(cfg.checkConfig(), IsFalse)
+}
+
+func (s *testConfigSuite) TestTableFilter(c *C) {
+	dir := c.MkDir()
+	filterPath := filepath.Join(dir, "filter.txt")
+	c.Assert(ioutil.WriteFile(filterPath, []byte("# the tables of shop\nshop.*\n!shop.tmp_*\n"), 0644), IsNil)
+
+	path := filepath.Join(dir, "filter.toml")
+	content := `
+table-filter = ["Test.t*", "@` + filterPath + `"]
+
+[[source-db]]
+host = "127.0.0.1"
+port = 3306
+instance-id = "source-1"
+`
+	c.Assert(ioutil.WriteFile(path, []byte(content), 0644), IsNil)
+
+	cfg := NewConfig()
+	c.Assert(cfg.Parse([]string{"-config", path}), IsNil)
+	c.Assert(cfg.TableFilter, DeepEquals, []string{"Test.t*", "@" + filterPath})
+	c.Assert(cfg.checkConfig(), IsTrue)
+	c.Assert(cfg.tableFilter, NotNil)
+
+	targetTables := map[string]map[string]interface{}{
+		"test":  {"t1": struct{}{}, "a1": struct{}{}},
+		"shop":  {"orders": struct{}{}, "tmp_orders": struct{}{}},
+		"other": {"t1": struct{}{}},
+		"mysql": {"user": struct{}{}},
+	}
+	sourceTablesMap := map[string]map[string][]TableInstance{
+		"test": {"t2": {{InstanceID: "source-1", Schema: "test_1", Table: "t2"}}},
+	}
+	checkTables := checkTablesFromFilter(cfg.tableFilter, targetTables, sourceTablesMap)
+	c.Assert(checkTables, DeepEquals, []*CheckTables{
+		{Schema: "shop", Tables: []string{"orders"}, ExcludeTables: []string{"tmp_orders"}},
+		{Schema: "test", Tables: []string{"t1", "t2"}, ExcludeTables: []string{"a1"}},
+	})
+
+	// check-tables and table-filter can't be both set
+	cfg.Tables = []*CheckTables{{Schema: "test", Tables: []string{"t1"}}}
+	c.Assert(cfg.checkConfig(), IsFalse)
+
+	cfg.Tables = nil
+	c.Assert(cfg.checkConfig(), IsTrue)
+
+	cfg.TableFilter = []string{"test.t1", "test."}
+	c.Assert(cfg.checkConfig(), IsFalse)
+}
@@ -19,15 +19,19 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pingcap/dm/dm/config"
 	"github.com/pingcap/errors"
 	"github.com/pingcap/log"
+	"github.com/pingcap/parser/model"
 	"github.com/pingcap/tidb-tools/pkg/dbutil"
 	"github.com/pingcap/tidb-tools/pkg/diff"
 	"github.com/pingcap/tidb-tools/pkg/filter"
+	tfilter "github.com/pingcap/tidb-tools/pkg/table-filter"
 	router "github.com/pingcap/tidb-tools/pkg/table-router"
 	"github.com/pingcap/tidb-tools/pkg/utils"
 	tidbconfig "github.com/pingcap/tidb/config"
@@ -36,24 +40,48 @@ import (
 
 // Diff contains two sql DB, used for comparing.
 type Diff struct {
-	sourceDBs         map[string]DBConfig
-	targetDB          DBConfig
-	chunkSize         int
-	sample            int
-	checkThreadCount  int
-	useChecksum       bool
-	useCheckpoint     bool
-	onlyUseChecksum   bool
-	ignoreDataCheck   bool
-	ignoreStructCheck bool
-	ignoreStats       bool
-	tables            map[string]map[string]*TableConfig
-	fixSQLFile        *os.File
+	sourceDBs             map[string]DBConfig
+	targetDB              DBConfig
+	chunkSize             int
+	sample                int
+	checkThreadCount      int
+	tableCheckThreadCount int
+	useChecksum           bool
+	useCheckpoint         bool
+	onlyUseChecksum       bool
+	useRowCount           bool
+	onlyUseRowCount       bool
+	useBisect             bool
+	bisectMaxDepth        int
+	ignoreDataCheck       bool
+	ignoreStructCheck     bool
+	writeStructDDL        bool
+	checkInventory        bool
+	ignoreStats           bool
+	applyFixSQL           bool
+	fixSQLDryRun          bool
+	maxFixRowNum          int64
+	fixSQLBatchSize       int
+	progressInterval      time.Duration
+	pauser                *diff.Pauser
+	tables                map[string]map[string]*TableConfig
+	fixSQLFile            *os.File
+	fixSQLLock            sync.Mutex
+	fixSQLDir             string
+	diffRowFile           *os.File
+	diffSink              diff.DiffSink
 
 	report         *Report
+	progress       *diff.Progress
 	tidbInstanceID string
 	tableRouter    *router.Table
 	cpDB           *sql.DB
+	// the checkpoint of all the tables, saved in cpDB or a local file
+	checkpointStore diff.CheckpointStore
+	// the tables only exist in source or target, can't be checked and are reported as the struct is not equal
+	unmatchedTables []*unmatchedTable
+	// the schemas, tables and views only exist in source or target found by the inventory check
+	unmatchedObjects []*UnmatchedObject
 
 	// DM's subtask config
 	subTaskCfgs []*config.SubTaskConfig
@@ -64,19 +92,32 @@ type Diff struct {
 // NewDiff returns a Diff instance.
 func NewDiff(ctx context.Context, cfg *Config) (diff *Diff, err error) {
 	diff = &Diff{
-		sourceDBs:         make(map[string]DBConfig),
-		chunkSize:         cfg.ChunkSize,
-		sample:            cfg.Sample,
-		checkThreadCount:  cfg.CheckThreadCount,
-		useChecksum:       cfg.UseChecksum,
-		useCheckpoint:     cfg.UseCheckpoint,
-		onlyUseChecksum:   cfg.OnlyUseChecksum,
-		ignoreDataCheck:   cfg.IgnoreDataCheck,
-		ignoreStructCheck: cfg.IgnoreStructCheck,
-		ignoreStats:       cfg.IgnoreStats,
-		tables:            make(map[string]map[string]*TableConfig),
-		report:            NewReport(),
-		ctx:               ctx,
+		sourceDBs:             make(map[string]DBConfig),
+		chunkSize:             cfg.ChunkSize,
+		sample:                cfg.Sample,
+		checkThreadCount:      cfg.CheckThreadCount,
+		tableCheckThreadCount: cfg.TableCheckThreadCount,
+		useChecksum:           cfg.UseChecksum,
+		useCheckpoint:         cfg.UseCheckpoint,
+		onlyUseChecksum:       cfg.OnlyUseChecksum,
+		useRowCount:           cfg.UseRowCount,
+		onlyUseRowCount:       cfg.OnlyUseRowCount,
+		useBisect:             cfg.UseBisect,
+		bisectMaxDepth:        cfg.BisectMaxDepth,
+		ignoreDataCheck:       cfg.IgnoreDataCheck,
+		ignoreStructCheck:     cfg.IgnoreStructCheck,
+		writeStructDDL:        cfg.WriteStructDDL,
+		checkInventory:        cfg.CheckInventory,
+		ignoreStats:           cfg.IgnoreStats,
+		applyFixSQL:           cfg.ApplyFixSQL,
+		fixSQLDryRun:          cfg.FixSQLDryRun,
+		maxFixRowNum:          cfg.MaxFixRowNum,
+		fixSQLBatchSize:       cfg.FixSQLBatchSize,
+		progressInterval:      cfg.progressInterval,
+		pauser:                cfg.pauser,
+		tables:                make(map[string]map[string]*TableConfig),
+		report:                NewReport(),
+		ctx:                   ctx,
 	}
 
 	if err = diff.init(cfg); err != nil {
@@ -103,6 +144,12 @@ func (df *Diff) init(cfg *Config) (err error) {
 		}
 	}
 
+	if len(cfg.TSOMapping) != 0 {
+		if err = setSnapshotByTSOMapping(df.ctx, cfg); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
 	// create connection for source.
 	if err = df.CreateDBConn(cfg); err != nil {
 		return errors.Trace(err)
@@ -112,9 +159,34 @@ func (df *Diff) init(cfg *Config) (err error) {
 		return errors.Trace(err)
 	}
 
-	df.fixSQLFile, err = os.Create(cfg.FixSQLFile)
-	if err != nil {
-		return errors.Trace(err)
+	// only inspect the checkpoint, don't truncate the output files
+	if cfg.CheckpointInspect {
+		return nil
+	}
+
+	if len(cfg.FixSQLDir) != 0 {
+		df.fixSQLDir, err = newFixSQLDir(cfg.FixSQLDir, time.Now())
+		if err != nil {
+			return errors.Trace(err)
+		}
+		log.Info("the fix sqls are saved in dir", zap.String("dir", df.fixSQLDir))
+	} else {
+		df.fixSQLFile, err = os.Create(cfg.FixSQLFile)
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	if len(cfg.DiffRowFile) != 0 {
+		df.diffRowFile, err = os.Create(cfg.DiffRowFile)
+		if err != nil {
+			return errors.Trace(err)
+		}
+
+		df.diffSink, err = diff.NewDiffSink(df.diffRowFile, cfg.DiffRowFormat)
+		if err != nil {
+			return errors.Trace(err)
+		}
 	}
 
 	return nil
@@ -127,6 +199,7 @@ func (df *Diff) CreateDBConn(cfg *Config) (err error) {
 	if err != nil {
 		return errors.Errorf("create target db %s error %v", cfg.TargetDBCfg.DBConfig.String(), err)
 	}
+	cfg.TargetDBCfg.Throttler = diff.NewThrottler(cfg.TargetDBCfg.Conn, cfg.TargetDBCfg.Throttle)
 	df.targetDB = cfg.TargetDBCfg
 
 	targetTZOffset, err := dbutil.GetTimeZoneOffset(df.ctx, cfg.TargetDBCfg.Conn)
@@ -143,13 +216,20 @@ func (df *Diff) CreateDBConn(cfg *Config) (err error) {
 		if err != nil {
 			return errors.Annotatef(err, "create source db %s failed", source.DBConfig.String())
 		}
+		source.Throttler = diff.NewThrottler(source.Conn, source.Throttle)
 		df.sourceDBs[source.InstanceID] = source
 	}
 
+	if cfg.CheckpointStore == diff.FileCheckpointStore {
+		df.checkpointStore = diff.NewFileCheckpointStore(cfg.CheckpointFile)
+		return nil
+	}
+
 	df.cpDB, err = diff.CreateDBForCP(df.ctx, cfg.TargetDBCfg.DBConfig)
 	if err != nil {
 		return errors.Errorf("create checkpoint db %s error %v", cfg.TargetDBCfg.DBConfig.String(), err)
 	}
+	df.checkpointStore = diff.NewSQLCheckpointStore(df.cpDB)
 
 	return nil
 }
@@ -237,6 +317,14 @@ func (df *Diff) adjustTableConfigBySubTask(cfg *Config) (err error) {
 				continue
 			}
 
+			if _, ok := allTablesMap[df.targetDB.InstanceID][schema][table]; !ok {
+				_, schemaExists := allTablesMap[df.targetDB.InstanceID][schema]
+				if err = df.addSourceOnlyTable(schema, table, sourceTables[0], !schemaExists); err != nil {
+					return errors.Trace(err)
+				}
+				continue
+			}
+
 			tableInfo, err := dbutil.GetTableInfo(df.ctx, df.targetDB.Conn, schema, table)
 			if err != nil {
 				return errors.Errorf("get table %s.%s's information error %s", schema, table, errors.ErrorStack(err))
@@ -258,6 +346,30 @@ func (df *Diff) adjustTableConfigBySubTask(cfg *Config) (err error) {
 		}
 	}
 
+	if df.checkInventory {
+		schemas := make(map[string]interface{}, len(sourceTablesMap))
+		for schema := range sourceTablesMap {
+			schemas[schema] = struct{}{}
+		}
+		inv := &inventory{
+			schemas: schemas,
+			include: func(string, string) bool { return true },
+			route: func(instanceID, schema, table string) (string, string, bool, error) {
+				if baLists[instanceID] != nil && len(baLists[instanceID].ApplyOn([]*filter.Table{{Schema: schema, Name: table}})) == 0 {
+					return "", "", false, nil
+				}
+				if tableRouters[instanceID] == nil {
+					return schema, table, true, nil
+				}
+				targetSchema, targetTable, err := tableRouters[instanceID].Route(schema, table)
+				return targetSchema, targetTable, true, err
+			},
+		}
+		if err = df.compareInventory(inv, allTablesMap); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
 	return nil
 }
 
@@ -309,20 +421,42 @@ func (df *Diff) AdjustTableConfig(cfg *Config) (err error) {
 		}
 	}
 
+	checkTables := cfg.Tables
+	if cfg.tableFilter != nil {
+		checkTables = checkTablesFromFilter(cfg.tableFilter, allTablesMap[df.targetDB.InstanceID], sourceTablesMap)
+		if len(checkTables) == 0 {
+			return errors.NotFoundf("tables match table-filter %v", cfg.TableFilter)
+		}
+	}
+
 	// fill the table information.
 	// will add default source information, don't worry, we will use table config's info replace this later.
-	for _, schemaTables := range cfg.Tables {
+	for _, schemaTables := range checkTables {
 		df.tables[schemaTables.Schema] = make(map[string]*TableConfig)
 		tables := make([]string, 0, len(schemaTables.Tables))
-		allTables, ok := allTablesMap[df.targetDB.InstanceID][schemaTables.Schema]
-		if !ok {
-			return errors.NotFoundf("schema %s.%s", df.targetDB.InstanceID, schemaTables.Schema)
+		// the source tables are routed to the schema, but the tables don't exist in target
+		sourceOnlyTables := make([]string, 0)
+		routedTables := make(map[string]interface{}, len(sourceTablesMap[schemaTables.Schema]))
+		for table := range sourceTablesMap[schemaTables.Schema] {
+			routedTables[table] = struct{}{}
+		}
+
+		allTables, schemaExists := allTablesMap[df.targetDB.InstanceID][schemaTables.Schema]
+		if !schemaExists {
+			if len(routedTables) == 0 {
+				return errors.NotFoundf("schema %s.%s", df.targetDB.InstanceID, schemaTables.Schema)
+			}
+			allTables = make(map[string]interface{})
 		}
 
 		for _, table := range schemaTables.Tables {
 			matchedTables, err := df.GetMatchTable(df.targetDB, schemaTables.Schema, table, allTables)
+			routedMatchedTables, routedErr := df.GetMatchTable(df.targetDB, schemaTables.Schema, table, routedTables)
 			if err != nil {
-				return errors.Trace(err)
+				if routedErr != nil {
+					return errors.Trace(err)
+				}
+				matchedTables = nil
 			}
 
 			//exclude those in "exclude-tables"
@@ -333,6 +467,18 @@ func (df *Diff) AdjustTableConfig(cfg *Config) (err error) {
 					tables = append(tables, t)
 				}
 			}
+			for _, t := range routedMatchedTables {
+				if _, ok := allTables[t]; ok || df.InExcludeTables(schemaTables.ExcludeTables, t) {
+					continue
+				}
+				sourceOnlyTables = append(sourceOnlyTables, t)
+			}
+		}
+
+		for _, tableName := range sourceOnlyTables {
+			if err = df.addSourceOnlyTable(schemaTables.Schema, tableName, sourceTablesMap[schemaTables.Schema][tableName][0], !schemaExists); err != nil {
+				return errors.Trace(err)
+			}
 		}
 
 		for _, tableName := range tables {
@@ -372,6 +518,9 @@ func (df *Diff) AdjustTableConfig(cfg *Config) (err error) {
 	}
 
 	for _, table := range cfg.TableCfgs {
+		if df.isUnmatchedTable(table.Schema, table.Table) {
+			continue
+		}
 		if _, ok := df.tables[table.Schema]; !ok {
 			return errors.NotFoundf("schema %s in check tables", table.Schema)
 		}
@@ -385,6 +534,12 @@ func (df *Diff) AdjustTableConfig(cfg *Config) (err error) {
 				return errors.Errorf("unkonwn database instance id %s", sourceTable.InstanceID)
 			}
 
+			// the query is compared as a table
+			if sourceTable.Query != "" {
+				sourceTables = append(sourceTables, sourceTable)
+				continue
+			}
+
 			allTables, ok := allTablesMap[df.sourceDBs[sourceTable.InstanceID].InstanceID][sourceTable.Schema]
 			if !ok {
 				return errors.Errorf("unknown schema %s in database %+v", sourceTable.Schema, df.sourceDBs[sourceTable.InstanceID])
@@ -413,6 +568,49 @@ func (df *Diff) AdjustTableConfig(cfg *Config) (err error) {
 		df.tables[table.Schema][table.Table].IgnoreColumns = table.IgnoreColumns
 		df.tables[table.Schema][table.Table].Fields = table.Fields
 		df.tables[table.Schema][table.Table].Collation = table.Collation
+		df.tables[table.Schema][table.Table].ChecksumStrategy = table.ChecksumStrategy
+		df.tables[table.Schema][table.Table].UpdateTimeColumn = table.UpdateTimeColumn
+		df.tables[table.Schema][table.Table].ColumnTolerances = table.ColumnTolerances
+		df.tables[table.Schema][table.Table].RenamedColumns = table.RenamedColumns
+		df.tables[table.Schema][table.Table].TargetOnlyColumns = table.TargetOnlyColumns
+		df.tables[table.Schema][table.Table].KeyColumns = table.KeyColumns
+		df.tables[table.Schema][table.Table].ColumnMappingRules = table.ColumnMappingRules
+		if err := df.tables[table.Schema][table.Table].buildColumnMappings(); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	// the tables have no source table are only in target
+	for schema, tables := range df.tables {
+		for tableName, table := range tables {
+			if df.hasSourceTable(table, allTablesMap) {
+				continue
+			}
+			df.addTargetOnlyTable(schema, tableName, table.TargetTableInfo)
+			delete(tables, tableName)
+		}
+	}
+
+	if df.checkInventory {
+		schemas := make(map[string]interface{}, len(checkTables))
+		excludeTables := make(map[string][]string, len(checkTables))
+		for _, schemaTables := range checkTables {
+			schemas[schemaTables.Schema] = struct{}{}
+			excludeTables[schemaTables.Schema] = append(excludeTables[schemaTables.Schema], schemaTables.ExcludeTables...)
+		}
+		inv := &inventory{
+			schemas: schemas,
+			include: func(schema, table string) bool {
+				return !df.InExcludeTables(excludeTables[schema], table)
+			},
+			route: func(_, schema, table string) (string, string, bool, error) {
+				targetSchema, targetTable, err := df.tableRouter.Route(schema, table)
+				return targetSchema, targetTable, true, err
+			},
+		}
+		if err = df.compareInventory(inv, allTablesMap); err != nil {
+			return errors.Trace(err)
+		}
 	}
 
 	// we need to increase max open connections for upstream, because one chunk needs accessing N shard tables in one
@@ -477,6 +675,55 @@ func (df *Diff) adjustDBCfgByDMSubTasks(cfg *Config) error {
 	return nil
 }
 
+// checkTablesFromFilter returns the check tables of the tables in target or routed from source matched by the filter, the
+// tables not matched in these schemas are excluded.
+func checkTablesFromFilter(f tfilter.Filter, targetTables map[string]map[string]interface{}, sourceTablesMap map[string]map[string][]TableInstance) []*CheckTables {
+	// schema => table => matched
+	allTables := make(map[string]map[string]bool)
+	addTable := func(schema, table string) {
+		if filter.IsSystemSchema(schema) || !f.MatchSchema(schema) {
+			return
+		}
+		if _, ok := allTables[schema]; !ok {
+			allTables[schema] = make(map[string]bool)
+		}
+		allTables[schema][table] = f.MatchTable(schema, table)
+	}
+	for schema, tables := range targetTables {
+		for table := range tables {
+			addTable(schema, table)
+		}
+	}
+	for schema, tables := range sourceTablesMap {
+		for table := range tables {
+			addTable(schema, table)
+		}
+	}
+
+	checkTables := make([]*CheckTables, 0, len(allTables))
+	for schema, tables := range allTables {
+		schemaTables := &CheckTables{Schema: schema}
+		for table, matched := range tables {
+			if matched {
+				schemaTables.Tables = append(schemaTables.Tables, table)
+			} else {
+				schemaTables.ExcludeTables = append(schemaTables.ExcludeTables, table)
+			}
+		}
+		if len(schemaTables.Tables) == 0 {
+			continue
+		}
+		sort.Strings(schemaTables.Tables)
+		sort.Strings(schemaTables.ExcludeTables)
+		checkTables = append(checkTables, schemaTables)
+	}
+	sort.Slice(checkTables, func(i, j int) bool {
+		return checkTables[i].Schema < checkTables[j].Schema
+	})
+
+	return checkTables
+}
+
 // GetAllTables get all tables in all databases.
 func (df *Diff) GetAllTables(cfg *Config) (map[string]map[string]map[string]interface{}, error) {
 	// instanceID => schema => table
@@ -537,12 +784,131 @@ func (df *Diff) GetMatchTable(db DBConfig, schema, table string, allTables map[s
 	return tableNames, nil
 }
 
+// unmatchedTable is a table only exists in source or target.
+type unmatchedTable struct {
+	schema     string
+	table      string
+	structDiff *diff.TableStructDiff
+}
+
+// addSourceOnlyTable records the table routed from the source table doesn't exist in target, the schema is also created
+// by the DDLs if it doesn't exist in target.
+func (df *Diff) addSourceOnlyTable(schema, table string, sourceTable TableInstance, createSchema bool) error {
+	sourceInfo, err := dbutil.GetTableInfo(df.ctx, df.sourceDBs[sourceTable.InstanceID].Conn, sourceTable.Schema, sourceTable.Table)
+	if err != nil {
+		return errors.Annotatef(err, "get source table %s.%s's information", sourceTable.InstanceID, dbutil.TableName(sourceTable.Schema, sourceTable.Table))
+	}
+
+	structDiff := diff.DiffTableStruct(schema, table, sourceInfo, nil)
+	structDiff.SourceTable = dbutil.TableName(sourceTable.Schema, sourceTable.Table)
+	if createSchema {
+		createSchemaSQL := fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s", dbutil.ColumnName(schema))
+		structDiff.Diffs = append([]*diff.StructDiff{{Type: diff.StructDiffSchema, Name: dbutil.ColumnName(schema), Source: createSchemaSQL}}, structDiff.Diffs...)
+		structDiff.DDLs = append([]string{createSchemaSQL + ";"}, structDiff.DDLs...)
+	}
+
+	log.Warn("table only exists in source", zap.String("table", dbutil.TableName(schema, table)), zap.String("source table", structDiff.SourceTable), zap.String("instance id", sourceTable.InstanceID))
+	df.unmatchedTables = append(df.unmatchedTables, &unmatchedTable{schema: schema, table: table, structDiff: structDiff})
+	return nil
+}
+
+// addTargetOnlyTable records the table has no source table.
+func (df *Diff) addTargetOnlyTable(schema, table string, targetInfo *model.TableInfo) {
+	log.Warn("table only exists in target", zap.String("table", dbutil.TableName(schema, table)))
+	df.unmatchedTables = append(df.unmatchedTables, &unmatchedTable{schema: schema, table: table, structDiff: diff.DiffTableStruct(schema, table, nil, targetInfo)})
+}
+
+func (df *Diff) isUnmatchedTable(schema, table string) bool {
+	for _, t := range df.unmatchedTables {
+		if t.schema == schema && t.table == table {
+			return true
+		}
+	}
+	return false
+}
+
+// hasSourceTable returns true if any source table of the table exists, the query is always treated as existing.
+func (df *Diff) hasSourceTable(table *TableConfig, allTablesMap map[string]map[string]map[string]interface{}) bool {
+	for _, sourceTable := range table.SourceTables {
+		if len(sourceTable.Query) != 0 {
+			return true
+		}
+		if _, ok := allTablesMap[sourceTable.InstanceID][sourceTable.Schema][sourceTable.Table]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// reportUnmatched reports the tables only exist in source or target as the struct is not equal, and writes the
+// DDLs converge the target to the source if write-struct-ddl is set. the objects found by the inventory check are
+// reported as failures.
+func (df *Diff) reportUnmatched() {
+	for _, t := range df.unmatchedTables {
+		df.report.SetTableStructCheckResult(t.schema, t.table, false)
+		df.report.SetTableDataCheckResult(t.schema, t.table, false)
+		df.report.SetTableStructDiffs(t.schema, t.table, []*diff.TableStructDiff{t.structDiff})
+		df.report.AddFailedNum()
+
+		if !df.writeStructDDL {
+			continue
+		}
+		if err := df.writeStructDDLs(t.schema, t.table, t.structDiff); err != nil {
+			log.Error("write struct ddls failed", zap.String("table", dbutil.TableName(t.schema, t.table)), zap.Error(err))
+		}
+	}
+
+	for _, object := range df.unmatchedObjects {
+		df.report.AddUnmatchedObject(object)
+	}
+}
+
+// writeStructDDLs writes the DDLs converge the target table to the source table to the fix sql file as comments,
+// the DDLs create or drop the table, so they should be reviewed and uncommented before executed.
+func (df *Diff) writeStructDDLs(schema, table string, structDiff *diff.TableStructDiff) error {
+	ddls := make([]string, 0, len(structDiff.DDLs)+1)
+	ddls = append(ddls, fmt.Sprintf("-- converge the struct of %s to the source, review and uncomment the DDLs before execute them\n", dbutil.TableName(schema, table)))
+	for _, ddl := range structDiff.DDLs {
+		ddls = append(ddls, diff.CommentDDL(ddl)+"\n")
+	}
+
+	if len(df.fixSQLDir) != 0 {
+		fixSQLWriter := newTableFixSQLWriter(df.fixSQLDir, schema, table)
+		for _, ddl := range ddls {
+			if err := fixSQLWriter.WriteFixSQL(ddl); err != nil {
+				fixSQLWriter.Close()
+				return errors.Trace(err)
+			}
+		}
+		return errors.Trace(fixSQLWriter.Close())
+	}
+
+	df.fixSQLLock.Lock()
+	defer df.fixSQLLock.Unlock()
+	for _, ddl := range ddls {
+		if _, err := df.fixSQLFile.WriteString(ddl); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
 // Close closes file and database connection.
 func (df *Diff) Close() {
 	if df.fixSQLFile != nil {
 		df.fixSQLFile.Close()
 	}
 
+	if df.diffSink != nil {
+		if err := df.diffSink.Close(); err != nil {
+			log.Warn("close diff sink failed", zap.Error(err))
+		}
+	}
+
+	if df.diffRowFile != nil {
+		df.diffRowFile.Close()
+	}
+
 	for _, db := range df.sourceDBs {
 		if db.Conn != nil {
 			db.Conn.Close()
@@ -553,6 +919,12 @@ func (df *Diff) Close() {
 		df.targetDB.Conn.Close()
 	}
 
+	if df.checkpointStore != nil {
+		if err := df.checkpointStore.Close(); err != nil {
+			log.Warn("close checkpoint store failed", zap.Error(err))
+		}
+	}
+
 	if df.cpDB != nil {
 		df.cpDB.Close()
 	}
@@ -562,94 +934,246 @@ func (df *Diff) Close() {
 func (df *Diff) Equal() (err error) {
 	defer df.Close()
 
+	tables := make([]*TableConfig, 0, len(df.tables))
 	for _, schema := range df.tables {
 		for _, table := range schema {
-			sourceTables := make([]*diff.TableInstance, 0, len(table.SourceTables))
-			for _, sourceTable := range table.SourceTables {
-				sourceTableInstance := &diff.TableInstance{
-					Conn:       df.sourceDBs[sourceTable.InstanceID].Conn,
-					Schema:     sourceTable.Schema,
-					Table:      sourceTable.Table,
-					InstanceID: sourceTable.InstanceID,
-				}
-				sourceTables = append(sourceTables, sourceTableInstance)
-			}
+			tables = append(tables, table)
+		}
+	}
 
-			targetTableInstance := &diff.TableInstance{
-				Conn:       df.targetDB.Conn,
-				Schema:     table.Schema,
-				Table:      table.Table,
-				InstanceID: df.targetDB.InstanceID,
+	df.reportUnmatched()
+
+	df.progress = diff.NewProgress(len(tables))
+	status.setProgress(df.progress)
+	stopPrintProgressCh := make(chan struct{})
+	printProgressDone := make(chan struct{})
+	go func() {
+		defer close(printProgressDone)
+		df.printProgress(stopPrintProgressCh)
+	}()
+	defer func() {
+		close(stopPrintProgressCh)
+		<-printProgressDone
+		log.Info("check progress", zap.Stringer("progress", df.progress.Info()))
+	}()
+
+	// the chunks of all the checking tables share the workers, so the num of checking chunks will not exceed check-thread-count
+	workerPool := diff.NewWorkerPool(df.checkThreadCount)
+
+	tableCh := make(chan *TableConfig)
+	var wg sync.WaitGroup
+	for i := 0; i < df.tableCheckThreadCount; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for table := range tableCh {
+				df.checkTable(table, workerPool)
 			}
+		}()
+	}
 
-			// find tidb instance for getting statistical information to split chunk
-			var tidbStatsSource *diff.TableInstance
-			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-			defer cancel()
+SendTables:
+	for _, table := range tables {
+		select {
+		case tableCh <- table:
+		case <-df.ctx.Done():
+			log.Warn("check is canceled", zap.Error(df.ctx.Err()))
+			break SendTables
+		}
+	}
+	close(tableCh)
+	wg.Wait()
 
-			if !df.ignoreStats {
-				log.Info("use tidb stats to split chunks")
-				isTiDB, err := dbutil.IsTiDB(ctx, targetTableInstance.Conn)
-				if err != nil {
-					log.Warn("judge instance is tidb failed", zap.Error(err))
-				} else if isTiDB {
-					tidbStatsSource = targetTableInstance
-				} else if len(sourceTables) == 1 {
-					isTiDB, err := dbutil.IsTiDB(ctx, sourceTables[0].Conn)
-					if err != nil {
-						log.Warn("judge instance is tidb failed", zap.Error(err))
-					} else if isTiDB {
-						tidbStatsSource = sourceTables[0]
-					}
-				}
-			} else {
-				log.Info("ignore tidb stats because of user setting")
-			}
+	return
+}
 
-			td := &diff.TableDiff{
-				SourceTables: sourceTables,
-				TargetTable:  targetTableInstance,
+// InspectCheckpoint prints how the check of every table will continue from the checkpoint, doesn't check any data.
+func (df *Diff) InspectCheckpoint() error {
+	defer df.Close()
 
-				IgnoreColumns: table.IgnoreColumns,
+	tables := make([]*TableConfig, 0, len(df.tables))
+	for _, schema := range df.tables {
+		for _, table := range schema {
+			tables = append(tables, table)
+		}
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		return dbutil.TableName(tables[i].Schema, tables[i].Table) < dbutil.TableName(tables[j].Schema, tables[j].Table)
+	})
 
-				Fields:            table.Fields,
-				Range:             table.Range,
-				Collation:         table.Collation,
-				ChunkSize:         df.chunkSize,
-				Sample:            df.sample,
-				CheckThreadCount:  df.checkThreadCount,
-				UseChecksum:       df.useChecksum,
-				UseCheckpoint:     df.useCheckpoint,
-				OnlyUseChecksum:   df.onlyUseChecksum,
-				IgnoreStructCheck: df.ignoreStructCheck,
-				IgnoreDataCheck:   df.ignoreDataCheck,
-				TiDBStatsSource:   tidbStatsSource,
-				CpDB:              df.cpDB,
-			}
+	var resumeNum int
+	for _, table := range tables {
+		inspection, err := df.newTableDiff(table).InspectCheckpoint(df.ctx)
+		if err != nil {
+			return errors.Annotatef(err, "inspect checkpoint of table %s", dbutil.TableName(table.Schema, table.Table))
+		}
 
-			structEqual, dataEqual, err := td.Equal(df.ctx, func(dml string) error {
-				_, err := df.fixSQLFile.WriteString(fmt.Sprintf("%s\n", dml))
-				return errors.Trace(err)
-			})
+		if inspection.Resume {
+			resumeNum++
+			fmt.Printf("%s: resume, skip %d/%d chunks\n", dbutil.TableName(table.Schema, table.Table), inspection.SkipChunkNum, inspection.ChunkNum)
+		} else {
+			fmt.Printf("%s: check from the beginning, %s\n", dbutil.TableName(table.Schema, table.Table), inspection.Reason)
+		}
+	}
+	fmt.Printf("%d/%d tables will resume from the checkpoint\n", resumeNum, len(tables))
+
+	return nil
+}
+
+// printProgress prints the check progress every progress interval until stopCh is closed.
+func (df *Diff) printProgress(stopCh chan struct{}) {
+	if df.progressInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(df.progressInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			log.Info("check progress", zap.Stringer("progress", df.progress.Info()))
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// newTableDiff returns the TableDiff of the table, the fields only used when check data is not set.
+func (df *Diff) newTableDiff(table *TableConfig) *diff.TableDiff {
+	sourceTables := make([]*diff.TableInstance, 0, len(table.SourceTables))
+	for _, sourceTable := range table.SourceTables {
+		sourceTableInstance := &diff.TableInstance{
+			Conn:       df.sourceDBs[sourceTable.InstanceID].Conn,
+			Schema:     sourceTable.Schema,
+			Table:      sourceTable.Table,
+			InstanceID: sourceTable.InstanceID,
+			Query:      sourceTable.Query,
+			Throttler:  df.sourceDBs[sourceTable.InstanceID].Throttler,
+
+			ColumnMapping: table.columnMappings[sourceTable.InstanceID],
+		}
+		sourceTables = append(sourceTables, sourceTableInstance)
+	}
 
+	targetTableInstance := &diff.TableInstance{
+		Conn:       df.targetDB.Conn,
+		Schema:     table.Schema,
+		Table:      table.Table,
+		InstanceID: df.targetDB.InstanceID,
+		Throttler:  df.targetDB.Throttler,
+	}
+
+	return &diff.TableDiff{
+		SourceTables: sourceTables,
+		TargetTable:  targetTableInstance,
+
+		IgnoreColumns:     table.IgnoreColumns,
+		ColumnTolerances:  table.ColumnTolerances,
+		RenamedColumns:    table.RenamedColumns,
+		TargetOnlyColumns: table.TargetOnlyColumns,
+		KeyColumns:        table.KeyColumns,
+
+		Fields:            table.Fields,
+		Range:             table.Range,
+		Collation:         table.Collation,
+		ChecksumStrategy:  table.ChecksumStrategy,
+		UpdateTimeColumn:  table.UpdateTimeColumn,
+		ChunkSize:         df.chunkSize,
+		Sample:            df.sample,
+		CheckThreadCount:  df.checkThreadCount,
+		UseChecksum:       df.useChecksum,
+		UseCheckpoint:     df.useCheckpoint,
+		OnlyUseChecksum:   df.onlyUseChecksum,
+		UseRowCount:       df.useRowCount,
+		OnlyUseRowCount:   df.onlyUseRowCount,
+		UseBisect:         df.useBisect,
+		BisectMaxDepth:    df.bisectMaxDepth,
+		IgnoreStructCheck: df.ignoreStructCheck,
+		WriteStructDDLs:   df.writeStructDDL,
+		IgnoreDataCheck:   df.ignoreDataCheck,
+		DiffSink:          df.diffSink,
+		ApplyFixSQL:       df.applyFixSQL,
+		FixSQLDryRun:      df.fixSQLDryRun,
+		MaxFixRowNum:      df.maxFixRowNum,
+		FixSQLBatchSize:   df.fixSQLBatchSize,
+		CheckpointStore:   df.checkpointStore,
+	}
+}
+
+// checkTable checks the table's struct and data, and saves the result in report.
+func (df *Diff) checkTable(table *TableConfig, workerPool *diff.WorkerPool) {
+	defer df.progress.DoneTable()
+
+	td := df.newTableDiff(table)
+	td.WorkerPool = workerPool
+	td.Pauser = df.pauser
+	td.Progress = df.progress
+
+	// find tidb instance for getting statistical information to split chunk
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if !df.ignoreStats {
+		log.Info("use tidb stats to split chunks")
+		isTiDB, err := dbutil.IsTiDB(ctx, td.TargetTable.Conn)
+		if err != nil {
+			log.Warn("judge instance is tidb failed", zap.Error(err))
+		} else if isTiDB {
+			td.TiDBStatsSource = td.TargetTable
+		} else if len(td.SourceTables) == 1 {
+			isTiDB, err := dbutil.IsTiDB(ctx, td.SourceTables[0].Conn)
 			if err != nil {
-				log.Error("check failed", zap.String("table", dbutil.TableName(table.Schema, table.Table)), zap.Error(err))
-				df.report.SetTableMeetError(table.Schema, table.Table, err)
-				df.report.FailedNum++
-				continue
+				log.Warn("judge instance is tidb failed", zap.Error(err))
+			} else if isTiDB {
+				td.TiDBStatsSource = td.SourceTables[0]
 			}
+		}
+	} else {
+		log.Info("ignore tidb stats because of user setting")
+	}
 
-			df.report.SetTableStructCheckResult(table.Schema, table.Table, structEqual)
-			df.report.SetTableDataCheckResult(table.Schema, table.Table, dataEqual)
-			if structEqual && dataEqual {
-				df.report.PassNum++
-			} else {
-				df.report.FailedNum++
-			}
+	writeFixSQL := func(dml string) error {
+		df.fixSQLLock.Lock()
+		defer df.fixSQLLock.Unlock()
+
+		_, err := df.fixSQLFile.WriteString(fmt.Sprintf("%s\n", dml))
+		return errors.Trace(err)
+	}
+	var fixSQLWriter *tableFixSQLWriter
+	if len(df.fixSQLDir) != 0 {
+		fixSQLWriter = newTableFixSQLWriter(df.fixSQLDir, table.Schema, table.Table)
+		writeFixSQL = fixSQLWriter.WriteFixSQL
+		td.WriteRevertSQL = fixSQLWriter.WriteRevertSQL
+	}
+
+	beginTime := time.Now()
+	structEqual, dataEqual, err := td.Equal(df.ctx, writeFixSQL)
+	if fixSQLWriter != nil {
+		if closeErr := fixSQLWriter.Close(); closeErr != nil {
+			log.Warn("close fix sql files failed", zap.String("table", dbutil.TableName(table.Schema, table.Table)), zap.Error(closeErr))
 		}
 	}
+	df.report.SetTableStats(table.Schema, table.Table, td.Stats(), time.Since(beginTime))
 
-	return
+	if err != nil {
+		log.Error("check failed", zap.String("table", dbutil.TableName(table.Schema, table.Table)), zap.Error(err))
+		df.report.SetTableMeetError(table.Schema, table.Table, err)
+		df.report.AddFailedNum()
+		return
+	}
+
+	df.report.SetTableStructCheckResult(table.Schema, table.Table, structEqual)
+	// the differences are reported even if the struct is equal
+	if len(td.StructDiffs()) != 0 {
+		df.report.SetTableStructDiffs(table.Schema, table.Table, td.StructDiffs())
+	}
+	df.report.SetTableDataCheckResult(table.Schema, table.Table, dataEqual)
+	if structEqual && dataEqual {
+		df.report.AddPassNum()
+	} else {
+		df.report.AddFailedNum()
+	}
 }
 
 // Judge if a table is in "exclude-tables" list
@@ -0,0 +1,215 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+)
+
+// tableFixSQLWriter writes the fix sqls and the revert sqls of one table to separate files in the dir,
+// the files are created when the first sql is written, so no file will be created if the table's data is equal.
+type tableFixSQLWriter struct {
+	sync.Mutex
+
+	dir    string
+	schema string
+	table  string
+
+	fixFile *os.File
+
+	// the revert sqls are saved in a temporary file in order, and are written to the revert file in reverse order
+	// when closed, so they can undo the fix sqls from the last one. the temporary file is kept if crashed.
+	revertTmpFile *os.File
+}
+
+// revertReadBlockSize is the size of the block read from the end of the revert sqls' temporary file when reverse it.
+const revertReadBlockSize = 64 * 1024
+
+func newTableFixSQLWriter(dir, schema, table string) *tableFixSQLWriter {
+	return &tableFixSQLWriter{
+		dir:    dir,
+		schema: schema,
+		table:  table,
+	}
+}
+
+// fixSQLFileName returns the name of the file which saves the fix sqls or the revert sqls of the table.
+// the dot is escaped too, so the names of different tables can't be the same.
+func fixSQLFileName(schema, table string, revert bool) string {
+	escape := func(name string) string {
+		return strings.Replace(url.PathEscape(name), ".", "%2E", -1)
+	}
+
+	name := fmt.Sprintf("%s.%s", escape(schema), escape(table))
+	if revert {
+		return name + ".revert.sql"
+	}
+	return name + ".sql"
+}
+
+// WriteFixSQL saves the sql used to fix the table's data.
+func (w *tableFixSQLWriter) WriteFixSQL(sql string) error {
+	w.Lock()
+	defer w.Unlock()
+
+	if w.fixFile == nil {
+		f, err := w.openFile(fixSQLFileName(w.schema, w.table, false))
+		if err != nil {
+			return errors.Trace(err)
+		}
+		w.fixFile = f
+	}
+
+	_, err := w.fixFile.WriteString(sql)
+	return errors.Trace(err)
+}
+
+// WriteRevertSQL saves the sql used to revert the fix sql, every line of it is a complete sql.
+func (w *tableFixSQLWriter) WriteRevertSQL(sql string) error {
+	w.Lock()
+	defer w.Unlock()
+
+	if w.revertTmpFile == nil {
+		f, err := w.openFile(revertTmpFileName(w.schema, w.table))
+		if err != nil {
+			return errors.Trace(err)
+		}
+		w.revertTmpFile = f
+	}
+
+	_, err := w.revertTmpFile.WriteString(sql)
+	return errors.Trace(err)
+}
+
+// revertTmpFileName returns the name of the temporary file which saves the revert sqls of the table in order.
+func revertTmpFileName(schema, table string) string {
+	return fixSQLFileName(schema, table, true) + ".tmp"
+}
+
+// openFile opens the file in append mode, the table's struct ddls and the fix sqls of data are written to the same file.
+func (w *tableFixSQLWriter) openFile(name string) (*os.File, error) {
+	f, err := os.OpenFile(filepath.Join(w.dir, name), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	return f, errors.Trace(err)
+}
+
+// writeRevertSQLs writes the revert sqls in the temporary file to the revert file in reverse order, and then closes and
+// removes the temporary file. the temporary file is kept if failed to write the revert file.
+func (w *tableFixSQLWriter) writeRevertSQLs() error {
+	tmpFile := w.revertTmpFile
+	if tmpFile == nil {
+		return nil
+	}
+	w.revertTmpFile = nil
+
+	f, err := w.openFile(fixSQLFileName(w.schema, w.table, true))
+	if err != nil {
+		tmpFile.Close()
+		return errors.Trace(err)
+	}
+
+	err = reverseLines(tmpFile, f, revertReadBlockSize)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return errors.Trace(os.Remove(tmpFile.Name()))
+}
+
+// reverseLines writes the lines of src to w in reverse order, src is read from the end by blocks, so only the block and
+// the line across blocks are kept in memory.
+func reverseLines(src *os.File, w io.Writer, blockSize int64) error {
+	pos, err := src.Seek(0, io.SeekEnd)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	// rest is the lines after pos which are not written, the first line in it may be incomplete
+	var rest []byte
+	for pos > 0 {
+		n := blockSize
+		if n > pos {
+			n = pos
+		}
+		pos -= n
+
+		block := make([]byte, n, n+int64(len(rest)))
+		if _, err = src.ReadAt(block, pos); err != nil {
+			return errors.Trace(err)
+		}
+		data := append(block, rest...)
+
+		for len(data) != 0 {
+			// the last byte is the line break of the last line
+			i := bytes.LastIndexByte(data[:len(data)-1], '\n')
+			if i < 0 && pos > 0 {
+				break
+			}
+			if _, err = w.Write(data[i+1:]); err != nil {
+				return errors.Trace(err)
+			}
+			data = data[:i+1]
+		}
+		rest = data
+	}
+
+	return nil
+}
+
+// Close writes the revert sqls and closes the created files.
+func (w *tableFixSQLWriter) Close() error {
+	w.Lock()
+	defer w.Unlock()
+
+	firstErr := w.writeRevertSQLs()
+
+	if w.fixFile != nil {
+		if err := w.fixFile.Close(); err != nil && firstErr == nil {
+			firstErr = errors.Trace(err)
+		}
+		w.fixFile = nil
+	}
+
+	return firstErr
+}
+
+// newFixSQLDir creates a sub directory named by the check's begin time in the dir, so the fix sqls and the revert sqls
+// of the last check are not overwritten.
+func newFixSQLDir(dir string, beginTime time.Time) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", errors.Trace(err)
+	}
+
+	subDir := filepath.Join(dir, beginTime.Format("20060102-150405.000"))
+	if err := os.Mkdir(subDir, 0755); err != nil {
+		return "", errors.Trace(err)
+	}
+
+	return subDir, nil
+}
@@ -0,0 +1,173 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/parser"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb-tools/pkg/diff"
+)
+
+var _ = Suite(&testFixSQLSuite{})
+
+type testFixSQLSuite struct{}
+
+func (s *testFixSQLSuite) TestTableFixSQLWriter(c *C) {
+	dir, err := ioutil.TempDir("", "fix-sql")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+
+	// no file is created if no sql is written
+	writer := newTableFixSQLWriter(dir, "test", "t1")
+	c.Assert(writer.Close(), IsNil)
+	files, err := ioutil.ReadDir(dir)
+	c.Assert(err, IsNil)
+	c.Assert(files, HasLen, 0)
+
+	writer = newTableFixSQLWriter(dir, "test", "t/2")
+	c.Assert(writer.WriteFixSQL("DELETE FROM `test`.`t/2` WHERE `id` = 1;\n"), IsNil)
+	c.Assert(writer.WriteRevertSQL("REPLACE INTO `test`.`t/2`(`id`) VALUES (1);\n"), IsNil)
+	c.Assert(writer.WriteFixSQL("REPLACE INTO `test`.`t/2`(`id`) VALUES (2);\n"), IsNil)
+	c.Assert(writer.WriteRevertSQL("DELETE FROM `test`.`t/2` WHERE `id` = 2;\n"), IsNil)
+
+	// the revert sqls are saved in the temporary file in order before closed
+	revertSQLs, err := ioutil.ReadFile(filepath.Join(dir, "test.t%2F2.revert.sql.tmp"))
+	c.Assert(err, IsNil)
+	c.Assert(string(revertSQLs), Equals, "REPLACE INTO `test`.`t/2`(`id`) VALUES (1);\nDELETE FROM `test`.`t/2` WHERE `id` = 2;\n")
+	c.Assert(writer.Close(), IsNil)
+
+	fixSQLs, err := ioutil.ReadFile(filepath.Join(dir, "test.t%2F2.sql"))
+	c.Assert(err, IsNil)
+	c.Assert(string(fixSQLs), Equals, "DELETE FROM `test`.`t/2` WHERE `id` = 1;\nREPLACE INTO `test`.`t/2`(`id`) VALUES (2);\n")
+
+	// the revert sqls undo the fix sqls from the last one, and the temporary file is removed
+	revertSQLs, err = ioutil.ReadFile(filepath.Join(dir, "test.t%2F2.revert.sql"))
+	c.Assert(err, IsNil)
+	c.Assert(string(revertSQLs), Equals, "DELETE FROM `test`.`t/2` WHERE `id` = 2;\nREPLACE INTO `test`.`t/2`(`id`) VALUES (1);\n")
+	files, err = ioutil.ReadDir(dir)
+	c.Assert(err, IsNil)
+	c.Assert(files, HasLen, 2)
+}
+
+func (s *testFixSQLSuite) TestReverseLines(c *C) {
+	f, err := ioutil.TempFile("", "revert-sql")
+	c.Assert(err, IsNil)
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	_, err = f.WriteString("a1;\nb22;\nc333;\n\nd4444;\n")
+	c.Assert(err, IsNil)
+
+	// the lines across blocks are reversed as a whole
+	for _, blockSize := range []int64{1, 2, 3, 5, 64} {
+		var buf bytes.Buffer
+		c.Assert(reverseLines(f, &buf, blockSize), IsNil)
+		c.Assert(buf.String(), Equals, "d4444;\n\nc333;\nb22;\na1;\n")
+	}
+}
+
+func (s *testFixSQLSuite) TestFixSQLFileName(c *C) {
+	c.Assert(fixSQLFileName("test", "t", false), Equals, "test.t.sql")
+	c.Assert(fixSQLFileName("test", "t", true), Equals, "test.t.revert.sql")
+	c.Assert(fixSQLFileName("a.b", "c", false), Equals, "a%2Eb.c.sql")
+	c.Assert(fixSQLFileName("a", "b.c", false), Equals, "a.b%2Ec.sql")
+	c.Assert(fixSQLFileName("a%2Eb", "c", false), Equals, "a%252Eb.c.sql")
+}
+
+func (s *testFixSQLSuite) TestNewFixSQLDir(c *C) {
+	dir, err := ioutil.TempDir("", "fix-sql")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+
+	beginTime := time.Date(2021, 10, 17, 15, 4, 5, 0, time.Local)
+	subDir, err := newFixSQLDir(filepath.Join(dir, "fix-sql"), beginTime)
+	c.Assert(err, IsNil)
+	c.Assert(subDir, Equals, filepath.Join(dir, "fix-sql", "20211017-150405.000"))
+
+	// the sqls of the last check are not overwritten
+	_, err = newFixSQLDir(filepath.Join(dir, "fix-sql"), beginTime)
+	c.Assert(err, NotNil)
+	subDir, err = newFixSQLDir(filepath.Join(dir, "fix-sql"), beginTime.Add(time.Second))
+	c.Assert(err, IsNil)
+	c.Assert(subDir, Equals, filepath.Join(dir, "fix-sql", "20211017-150406.000"))
+}
+
+func (s *testFixSQLSuite) TestReportUnmatchedTables(c *C) {
+	dir, err := ioutil.TempDir("", "fix-sql")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+
+	targetInfo, err := dbutil.GetTableInfoBySQL("CREATE TABLE `t` (`id` int NOT NULL, PRIMARY KEY (`id`))", parser.New())
+	c.Assert(err, IsNil)
+
+	df := &Diff{fixSQLDir: dir, report: NewReport()}
+	df.addTargetOnlyTable("test", "t", targetInfo)
+	c.Assert(df.isUnmatchedTable("test", "t"), IsTrue)
+	c.Assert(df.isUnmatchedTable("test", "t1"), IsFalse)
+	df.reportUnmatched()
+
+	c.Assert(df.report.Result, Equals, Fail)
+	c.Assert(df.report.FailedNum, Equals, int32(1))
+	result := df.report.TableResults["test"]["t"]
+	c.Assert(result.StructEqual, IsFalse)
+	c.Assert(result.StructDiffs, HasLen, 1)
+	c.Assert(result.StructDiffs[0].Diffs[0].Type, Equals, diff.StructDiffTable)
+
+	// the DDLs are not written without write-struct-ddl
+	_, err = os.Stat(filepath.Join(dir, "test.t.sql"))
+	c.Assert(os.IsNotExist(err), IsTrue)
+
+	// the DDLs are written as comments
+	df.writeStructDDL = true
+	df.reportUnmatched()
+	fixSQLs, err := ioutil.ReadFile(filepath.Join(dir, "test.t.sql"))
+	c.Assert(err, IsNil)
+	c.Assert(string(fixSQLs), Equals, "-- converge the struct of `test`.`t` to the source, review and uncomment the DDLs before execute them\n-- DROP TABLE `test`.`t`;\n")
+
+	// every line of the CREATE TABLE for the table only exists in source is commented out
+	sourceInfo, err := dbutil.GetTableInfoBySQL("CREATE TABLE `t1` (`id` int NOT NULL, PRIMARY KEY (`id`))", parser.New())
+	c.Assert(err, IsNil)
+	structDiff := diff.DiffTableStruct("test", "t1", sourceInfo, nil)
+	structDiff.SourceTable = "`test`.`t1`"
+	df.unmatchedTables = []*unmatchedTable{{schema: "test", table: "t1", structDiff: structDiff}}
+	df.reportUnmatched()
+	fixSQLs, err = ioutil.ReadFile(filepath.Join(dir, "test.t1.sql"))
+	c.Assert(err, IsNil)
+	c.Assert(string(fixSQLs), Equals, "-- converge the struct of `test`.`t1` to the source, review and uncomment the DDLs before execute them\n"+
+		"-- CREATE TABLE `test`.`t1` (\n"+
+		"--   `id` int(11) NOT NULL,\n"+
+		"--   PRIMARY KEY (`id`)\n"+
+		"-- ) DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;\n")
+}
+
+func (s *testFixSQLSuite) TestHasSourceTable(c *C) {
+	allTablesMap := map[string]map[string]map[string]interface{}{
+		"source-1": {"test": {"t1": struct{}{}}},
+	}
+
+	df := &Diff{}
+	table := &TableConfig{SourceTables: []TableInstance{{InstanceID: "source-1", Schema: "test", Table: "t2"}}}
+	c.Assert(df.hasSourceTable(table, allTablesMap), IsFalse)
+	table.SourceTables = append(table.SourceTables, TableInstance{InstanceID: "source-1", Schema: "test", Table: "t1"})
+	c.Assert(df.hasSourceTable(table, allTablesMap), IsTrue)
+	table.SourceTables = []TableInstance{{InstanceID: "source-1", Query: "SELECT 1"}}
+	c.Assert(df.hasSourceTable(table, allTablesMap), IsTrue)
+}
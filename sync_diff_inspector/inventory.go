@@ -0,0 +1,224 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb-tools/pkg/filter"
+	"github.com/pingcap/tidb-tools/pkg/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	// SchemaObject means the unmatched object is a schema
+	SchemaObject = "schema"
+	// TableObject means the unmatched object is a table
+	TableObject = "table"
+	// ViewObject means the unmatched object is a view
+	ViewObject = "view"
+
+	// SourceSide means the object only exists in source
+	SourceSide = "source"
+	// TargetSide means the object only exists in target
+	TargetSide = "target"
+)
+
+// UnmatchedObject is a schema, table or view only exists in source or target.
+type UnmatchedObject struct {
+	// schema, table or view
+	Type   string `json:"type"`
+	Schema string `json:"schema"`
+	// the name of the table or view, is empty for schema
+	Name string `json:"name,omitempty"`
+	// the side the object exists, source or target
+	OnlyIn string `json:"only-in"`
+	// the source objects routed to the object in target, only valid when the object only exists in source
+	SourceObjects []string `json:"source-objects,omitempty"`
+}
+
+func (o *UnmatchedObject) String() string {
+	name := dbutil.ColumnName(o.Schema)
+	if len(o.Name) != 0 {
+		name = dbutil.TableName(o.Schema, o.Name)
+	}
+	if len(o.SourceObjects) != 0 {
+		return fmt.Sprintf("%s %s only exists in %s, routed from %v", o.Type, name, o.OnlyIn, o.SourceObjects)
+	}
+	return fmt.Sprintf("%s %s only exists in %s", o.Type, name, o.OnlyIn)
+}
+
+// routeFunc returns the target schema and table of the source object, returns false if the object is filtered.
+type routeFunc func(instanceID, schema, table string) (string, string, bool, error)
+
+// inventory compares the schemas, tables or views of the sources and the target.
+type inventory struct {
+	targetInstanceID string
+	// the target schemas are checked
+	schemas map[string]interface{}
+	// returns false if the object in target is excluded
+	include func(schema, table string) bool
+	// returns true if the table is checked or already reported, it is skipped
+	checked func(schema, table string) bool
+	route   routeFunc
+}
+
+// compare returns the objects only exist in source or target, objects is instanceID => schema => names.
+func (i *inventory) compare(tp string, objects map[string]map[string]map[string]interface{}) ([]*UnmatchedObject, error) {
+	// target schema => target name => the source objects routed to it
+	routed := make(map[string]map[string][]string)
+	for instanceID, schemas := range objects {
+		if instanceID == i.targetInstanceID {
+			continue
+		}
+
+		for schema, names := range schemas {
+			if filter.IsSystemSchema(schema) {
+				continue
+			}
+			for name := range names {
+				targetSchema, targetName, ok, err := i.route(instanceID, schema, name)
+				if err != nil {
+					return nil, errors.Errorf("get route result for %s.%s.%s failed, error %v", instanceID, schema, name, err)
+				}
+				if !ok {
+					continue
+				}
+				if _, ok = i.schemas[targetSchema]; !ok || !i.include(targetSchema, targetName) {
+					continue
+				}
+
+				if _, ok = routed[targetSchema]; !ok {
+					routed[targetSchema] = make(map[string][]string)
+				}
+				routed[targetSchema][targetName] = append(routed[targetSchema][targetName], fmt.Sprintf("%s.%s", instanceID, dbutil.TableName(schema, name)))
+			}
+		}
+	}
+
+	unmatched := make([]*UnmatchedObject, 0)
+	for schema := range i.schemas {
+		targetNames, schemaExists := objects[i.targetInstanceID][schema]
+		routedNames := routed[schema]
+
+		targetOnlyNames := make([]string, 0)
+		for name := range targetNames {
+			if _, ok := routedNames[name]; !ok && i.include(schema, name) && !i.checked(schema, name) {
+				targetOnlyNames = append(targetOnlyNames, name)
+			}
+		}
+		sourceOnlyNames := make([]string, 0)
+		for name := range routedNames {
+			if _, ok := targetNames[name]; !ok && !i.checked(schema, name) {
+				sourceOnlyNames = append(sourceOnlyNames, name)
+			}
+		}
+
+		// the whole schema only exists in one side, it is reported only once when check tables
+		if tp == TableObject {
+			if !schemaExists {
+				// the tables already reported as unmatched include the schema's creation
+				if len(sourceOnlyNames) != 0 {
+					unmatched = append(unmatched, &UnmatchedObject{Type: SchemaObject, Schema: schema, OnlyIn: SourceSide})
+				}
+				continue
+			}
+			if len(routedNames) == 0 && len(targetOnlyNames) != 0 && len(targetOnlyNames) == len(targetNames) {
+				unmatched = append(unmatched, &UnmatchedObject{Type: SchemaObject, Schema: schema, OnlyIn: TargetSide})
+				continue
+			}
+		} else if !schemaExists {
+			continue
+		}
+
+		for _, name := range targetOnlyNames {
+			unmatched = append(unmatched, &UnmatchedObject{Type: tp, Schema: schema, Name: name, OnlyIn: TargetSide})
+		}
+		for _, name := range sourceOnlyNames {
+			sourceObjects := routedNames[name]
+			sort.Strings(sourceObjects)
+			unmatched = append(unmatched, &UnmatchedObject{Type: tp, Schema: schema, Name: name, OnlyIn: SourceSide, SourceObjects: sourceObjects})
+		}
+	}
+
+	sort.Slice(unmatched, func(i, j int) bool {
+		return unmatched[i].String() < unmatched[j].String()
+	})
+	return unmatched, nil
+}
+
+// compareInventory lists the schemas, tables and views only exist in source or target, the checked tables and the tables
+// already reported as unmatched are skipped.
+func (df *Diff) compareInventory(inv *inventory, allTablesMap map[string]map[string]map[string]interface{}) error {
+	inv.targetInstanceID = df.targetDB.InstanceID
+	inv.checked = func(schema, table string) bool {
+		_, ok := df.tables[schema][table]
+		return ok || df.isUnmatchedTable(schema, table)
+	}
+
+	unmatchedTables, err := inv.compare(TableObject, allTablesMap)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	allViewsMap, err := df.GetAllViews()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	unmatchedViews, err := inv.compare(ViewObject, allViewsMap)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	for _, object := range append(unmatchedTables, unmatchedViews...) {
+		log.Warn("object only exists in one side", zap.Stringer("object", object))
+		df.unmatchedObjects = append(df.unmatchedObjects, object)
+	}
+
+	return nil
+}
+
+// GetAllViews get all views in all databases.
+func (df *Diff) GetAllViews() (map[string]map[string]map[string]interface{}, error) {
+	// instanceID => schema => view
+	allViewsMap := make(map[string]map[string]map[string]interface{})
+
+	dbs := make([]DBConfig, 0, len(df.sourceDBs)+1)
+	dbs = append(dbs, df.targetDB)
+	for _, source := range df.sourceDBs {
+		dbs = append(dbs, source)
+	}
+
+	for _, db := range dbs {
+		allViewsMap[db.InstanceID] = make(map[string]map[string]interface{})
+		schemas, err := dbutil.GetSchemas(df.ctx, db.Conn)
+		if err != nil {
+			return nil, errors.Annotatef(err, "get schemas from %s", db.InstanceID)
+		}
+
+		for _, schema := range schemas {
+			views, err := dbutil.GetViews(df.ctx, db.Conn, schema)
+			if err != nil {
+				return nil, errors.Annotatef(err, "get views from %s.%s", db.InstanceID, schema)
+			}
+			allViewsMap[db.InstanceID][schema] = utils.SliceToMap(views)
+		}
+	}
+
+	return allViewsMap, nil
+}
@@ -0,0 +1,130 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/tidb-tools/pkg/utils"
+)
+
+var _ = Suite(&testInventorySuite{})
+
+type testInventorySuite struct{}
+
+func (s *testInventorySuite) newInventory() *inventory {
+	return &inventory{
+		targetInstanceID: "target",
+		schemas:          map[string]interface{}{"test": struct{}{}, "empty": struct{}{}, "only_target": struct{}{}},
+		include: func(schema, table string) bool {
+			return table != "excluded"
+		},
+		checked: func(schema, table string) bool {
+			return schema == "test" && table == "checked"
+		},
+		// the tables in source1's shard_* are merged to test.shard, the schema other is filtered
+		route: func(instanceID, schema, table string) (string, string, bool, error) {
+			if schema == "other" {
+				return "", "", false, nil
+			}
+			if instanceID == "source1" && strings.HasPrefix(table, "shard_") {
+				return "test", "shard", true, nil
+			}
+			return schema, table, true, nil
+		},
+	}
+}
+
+func (s *testInventorySuite) TestCompareTables(c *C) {
+	objects := map[string]map[string]map[string]interface{}{
+		"target": {
+			"test":        utils.SliceToMap([]string{"t1", "checked", "shard", "excluded", "target_only"}),
+			"only_target": utils.SliceToMap([]string{"t1", "t2"}),
+			"empty":       {},
+			"mysql":       utils.SliceToMap([]string{"user"}),
+		},
+		"source1": {
+			"test":        utils.SliceToMap([]string{"t1", "checked", "shard_1", "shard_2", "excluded", "source_only"}),
+			"other":       utils.SliceToMap([]string{"t1"}),
+			"only_source": utils.SliceToMap([]string{"t1"}),
+			"mysql":       utils.SliceToMap([]string{"db"}),
+		},
+		"source2": {
+			"test":  utils.SliceToMap([]string{"t1", "routed_only"}),
+			"empty": {},
+		},
+	}
+
+	unmatched, err := s.newInventory().compare(TableObject, objects)
+	c.Assert(err, IsNil)
+
+	results := make([]string, 0, len(unmatched))
+	for _, object := range unmatched {
+		results = append(results, object.String())
+	}
+	c.Assert(results, DeepEquals, []string{
+		"schema `only_target` only exists in target",
+		"table `test`.`routed_only` only exists in source, routed from [source2.`test`.`routed_only`]",
+		"table `test`.`source_only` only exists in source, routed from [source1.`test`.`source_only`]",
+		"table `test`.`target_only` only exists in target",
+	})
+
+	// the source schema is routed to a target schema not exists
+	objects["source2"]["only_target2"] = utils.SliceToMap([]string{"t1"})
+	inv := s.newInventory()
+	inv.schemas["only_target2"] = struct{}{}
+	unmatched, err = inv.compare(TableObject, objects)
+	c.Assert(err, IsNil)
+	c.Assert(unmatched, HasLen, 5)
+	c.Assert(*unmatched[0], DeepEquals, UnmatchedObject{Type: SchemaObject, Schema: "only_target2", OnlyIn: SourceSide})
+	c.Assert(*unmatched[1], DeepEquals, UnmatchedObject{Type: SchemaObject, Schema: "only_target", OnlyIn: TargetSide})
+
+	// the schema is not reported if its tables are already reported as unmatched
+	inv.checked = func(schema, table string) bool {
+		return schema == "only_target2" || (schema == "test" && table == "checked")
+	}
+	unmatched, err = inv.compare(TableObject, objects)
+	c.Assert(err, IsNil)
+	c.Assert(unmatched, HasLen, 4)
+	c.Assert(*unmatched[0], DeepEquals, UnmatchedObject{Type: SchemaObject, Schema: "only_target", OnlyIn: TargetSide})
+}
+
+func (s *testInventorySuite) TestCompareViews(c *C) {
+	objects := map[string]map[string]map[string]interface{}{
+		"target": {
+			"test":        utils.SliceToMap([]string{"v1", "v2"}),
+			"only_target": utils.SliceToMap([]string{"v1"}),
+		},
+		"source1": {
+			"test":  utils.SliceToMap([]string{"v1", "v3", "shard_v"}),
+			"empty": utils.SliceToMap([]string{"v1"}),
+		},
+	}
+
+	unmatched, err := s.newInventory().compare(ViewObject, objects)
+	c.Assert(err, IsNil)
+
+	results := make([]string, 0, len(unmatched))
+	for _, object := range unmatched {
+		results = append(results, object.String())
+	}
+	// the views are not reported as schemas, and the schemas not exist in target are reported by the tables' check
+	c.Assert(results, DeepEquals, []string{
+		"view `only_target`.`v1` only exists in target",
+		"view `test`.`shard` only exists in source, routed from [source1.`test`.`shard_v`]",
+		"view `test`.`v2` only exists in target",
+		"view `test`.`v3` only exists in source, routed from [source1.`test`.`v3`]",
+	})
+}
@@ -61,15 +61,73 @@ func main() {
 
 	log.Info("", zap.Stringer("config", cfg))
 
+	status.setPauser(cfg.pauser)
+	handlePauseSignals(cfg.pauser)
+	if len(cfg.StatusAddr) != 0 {
+		go func() {
+			if err := status.run(cfg.StatusAddr, cfg.StatusToken); err != nil {
+				log.Error("status server exited", zap.String("address", cfg.StatusAddr), zap.Error(err))
+			}
+		}()
+	}
+
 	ctx := context.Background()
-	if !checkSyncState(ctx, cfg) {
+	if cfg.CheckpointInspect {
+		d, err := NewDiff(ctx, cfg)
+		if err != nil {
+			log.Fatal("fail to initialize diff process", zap.Error(err))
+		}
+		if err = d.InspectCheckpoint(); err != nil {
+			log.Fatal("inspect checkpoint failed", zap.Error(err))
+		}
+		return
+	}
+
+	if cfg.checkInterval > 0 {
+		checkPeriodically(ctx, cfg)
+		return
+	}
+
+	pass, err := checkSyncState(ctx, cfg)
+	if err != nil {
+		log.Fatal("check data difference failed", zap.Error(err))
+	}
+	if !pass {
 		log.Warn("check failed!!!")
 		os.Exit(1)
 	}
 	log.Info("check pass!!!")
 }
 
-func checkSyncState(ctx context.Context, cfg *Config) bool {
+// checkPeriodically checks the data every check interval, the tables with update time column only check the rows updated since the last check.
+func checkPeriodically(ctx context.Context, cfg *Config) {
+	ticker := time.NewTicker(cfg.checkInterval)
+	defer ticker.Stop()
+
+	for round := 1; ; round++ {
+		// the db config may be adjusted when initialize the diff, so use a copy in every round
+		roundCfg := *cfg
+		roundCfg.SourceDBCfg = append([]DBConfig(nil), cfg.SourceDBCfg...)
+
+		// the error may be transient, so check again in the next round
+		pass, err := checkSyncState(ctx, &roundCfg)
+		if err != nil {
+			log.Error("check data difference failed, will check again in the next round", zap.Int("round", round), zap.Error(err))
+		} else if pass {
+			log.Info("check pass!!!", zap.Int("round", round))
+		} else {
+			log.Warn("check failed!!!", zap.Int("round", round))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func checkSyncState(ctx context.Context, cfg *Config) (bool, error) {
 	beginTime := time.Now()
 	defer func() {
 		log.Info("check data finished", zap.Duration("cost", time.Since(beginTime)))
@@ -77,15 +135,23 @@ func checkSyncState(ctx context.Context, cfg *Config) bool {
 
 	d, err := NewDiff(ctx, cfg)
 	if err != nil {
-		log.Fatal("fail to initialize diff process", zap.Error(err))
+		return false, errors.Annotate(err, "fail to initialize diff process")
 	}
 
 	err = d.Equal()
 	if err != nil {
-		log.Fatal("check data difference failed", zap.Error(err))
+		return false, errors.Trace(err)
 	}
 
 	d.report.Print()
 
-	return d.report.Result == Pass
+	if len(cfg.ReportFile) != 0 {
+		if err = d.report.WriteFile(cfg.ReportFile, cfg.ReportFormat); err != nil {
+			log.Error("write report file failed", zap.String("file", cfg.ReportFile), zap.Error(err))
+		} else {
+			log.Info("write report file", zap.String("file", cfg.ReportFile), zap.String("format", cfg.ReportFormat))
+		}
+	}
+
+	return d.report.Result == Pass, nil
 }
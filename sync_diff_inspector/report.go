@@ -14,9 +14,18 @@
 package main
 
 import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"sort"
 	"sync"
+	"time"
 
+	"github.com/pingcap/errors"
 	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/pkg/diff"
 	"go.uber.org/zap"
 )
 
@@ -25,15 +34,29 @@ const (
 	Pass = "pass"
 	// Fail means not all data or struct of tables are equal
 	Fail = "fail"
+
+	// JSONFormat means write the report to file in json format
+	JSONFormat = "json"
+	// JUnitFormat means write the report to file in junit xml format
+	JUnitFormat = "junit"
 )
 
 // TableResult saves the check result for every table.
 type TableResult struct {
-	Schema      string
-	Table       string
-	StructEqual bool
-	DataEqual   bool
-	MeetError   error
+	Schema      string `json:"schema"`
+	Table       string `json:"table"`
+	StructEqual bool   `json:"struct-equal"`
+	DataEqual   bool   `json:"data-equal"`
+	MeetError   error  `json:"-"`
+
+	// the differences with the source tables' struct, only valid when the table's struct is not equal
+	StructDiffs []*diff.TableStructDiff `json:"struct-diffs,omitempty"`
+
+	// statistics of the data check, only valid when the table's data is checked
+	diff.TableStats
+
+	// time cost for checking this table
+	Cost time.Duration `json:"-"`
 }
 
 // Report saves the check results.
@@ -45,6 +68,11 @@ type Report struct {
 	PassNum      int32
 	FailedNum    int32
 	TableResults map[string]map[string]*TableResult
+
+	// the schemas, tables and views only exist in source or target
+	UnmatchedObjects []*UnmatchedObject
+
+	startTime time.Time
 }
 
 // NewReport returns a new Report.
@@ -52,7 +80,47 @@ func NewReport() *Report {
 	return &Report{
 		TableResults: make(map[string]map[string]*TableResult),
 		Result:       Pass,
+		startTime:    time.Now(),
+	}
+}
+
+// tableResult returns the table's check result, will create a new one if not exists.
+// should be called with lock.
+func (r *Report) tableResult(schema, table string) *TableResult {
+	if _, ok := r.TableResults[schema]; !ok {
+		r.TableResults[schema] = make(map[string]*TableResult)
+	}
+
+	tableResult, ok := r.TableResults[schema][table]
+	if !ok {
+		tableResult = &TableResult{
+			Schema: schema,
+			Table:  table,
+		}
+		r.TableResults[schema][table] = tableResult
+	}
+
+	return tableResult
+}
+
+// sortedTableResults returns all the tables' check results ordered by schema and table name.
+// should be called with lock.
+func (r *Report) sortedTableResults() []*TableResult {
+	results := make([]*TableResult, 0, len(r.TableResults))
+	for _, tableMap := range r.TableResults {
+		for _, result := range tableMap {
+			results = append(results, result)
+		}
 	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Schema != results[j].Schema {
+			return results[i].Schema < results[j].Schema
+		}
+		return results[i].Table < results[j].Table
+	})
+
+	return results
 }
 
 // Print prints the check report.
@@ -84,11 +152,22 @@ func (r *Report) Print() {
 			if result.MeetError != nil {
 				log.Error("table check result", zap.String("schema", schema), zap.String("table", table), zap.String("meet error", result.MeetError.Error()))
 			} else {
-				log.Info("table check result", zap.String("schema", schema), zap.String("table", table), zap.Bool("struct equal", result.StructEqual), zap.Bool("data equal", result.DataEqual))
+				log.Info("table check result", zap.String("schema", schema), zap.String("table", table), zap.Bool("struct equal", result.StructEqual), zap.Bool("data equal", result.DataEqual),
+					zap.Int64("chunk num", result.ChunkNum), zap.Int64("failed chunk num", result.FailedChunkNum), zap.Int64("repaired chunk num", result.RepairedChunkNum),
+					zap.Int64("fixed row num", result.FixedRowNum), zap.Int64("insert row num", result.InsertRowNum),
+					zap.Int64("delete row num", result.DeleteRowNum), zap.Int64("update row num", result.UpdateRowNum), zap.Reflect("different columns", result.DiffColumnNum),
+					zap.Int64("count mismatch chunk num", result.CountMismatchChunkNum), zap.Int64("source row count", result.SourceRowCount), zap.Int64("target row count", result.TargetRowCount), zap.Duration("cost", result.Cost))
+			}
+			for _, structDiff := range result.StructDiffs {
+				log.Warn("table struct difference", zap.String("schema", schema), zap.String("table", table), zap.Stringer("diff", structDiff), zap.Strings("ddls", structDiff.DDLs))
 			}
 		}
 	}
 
+	for _, object := range r.UnmatchedObjects {
+		log.Warn("unmatched object", zap.Stringer("object", object))
+	}
+
 	return
 }
 
@@ -97,39 +176,53 @@ func (r *Report) SetTableStructCheckResult(schema, table string, equal bool) {
 	r.Lock()
 	defer r.Unlock()
 
-	if _, ok := r.TableResults[schema]; !ok {
-		r.TableResults[schema] = make(map[string]*TableResult)
-	}
-
-	if tableResult, ok := r.TableResults[schema][table]; ok {
-		tableResult.StructEqual = equal
-	} else {
-		r.TableResults[schema][table] = &TableResult{
-			StructEqual: equal,
-		}
-	}
+	r.tableResult(schema, table).StructEqual = equal
 
 	if !equal {
 		r.Result = Fail
 	}
 }
 
+// SetTableStructDiffs sets the differences of the struct between the source tables and the target table.
+func (r *Report) SetTableStructDiffs(schema, table string, structDiffs []*diff.TableStructDiff) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.tableResult(schema, table).StructDiffs = structDiffs
+}
+
+// AddUnmatchedObject adds an object only exists in source or target, it is counted as a failed check.
+func (r *Report) AddUnmatchedObject(object *UnmatchedObject) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.UnmatchedObjects = append(r.UnmatchedObjects, object)
+	r.FailedNum++
+	r.Result = Fail
+}
+
+// AddPassNum counts a table whose check is passed.
+func (r *Report) AddPassNum() {
+	r.Lock()
+	defer r.Unlock()
+
+	r.PassNum++
+}
+
+// AddFailedNum counts a table whose check is failed.
+func (r *Report) AddFailedNum() {
+	r.Lock()
+	defer r.Unlock()
+
+	r.FailedNum++
+}
+
 // SetTableDataCheckResult sets the data check result for table.
 func (r *Report) SetTableDataCheckResult(schema, table string, equal bool) {
 	r.Lock()
 	defer r.Unlock()
 
-	if _, ok := r.TableResults[schema]; !ok {
-		r.TableResults[schema] = make(map[string]*TableResult)
-	}
-
-	if tableResult, ok := r.TableResults[schema][table]; ok {
-		tableResult.DataEqual = equal
-	} else {
-		r.TableResults[schema][table] = &TableResult{
-			DataEqual: equal,
-		}
-	}
+	r.tableResult(schema, table).DataEqual = equal
 
 	if !equal {
 		r.Result = Fail
@@ -138,17 +231,183 @@ func (r *Report) SetTableDataCheckResult(schema, table string, equal bool) {
 
 // SetTableMeetError sets meet error when check the table.
 func (r *Report) SetTableMeetError(schema, table string, err error) {
-	if _, ok := r.TableResults[schema]; !ok {
-		r.TableResults[schema] = make(map[string]*TableResult)
+	r.Lock()
+	defer r.Unlock()
+
+	r.tableResult(schema, table).MeetError = err
+
+	r.Result = Fail
+}
+
+// SetTableStats sets the data check's statistics and time cost for table.
+func (r *Report) SetTableStats(schema, table string, stats diff.TableStats, cost time.Duration) {
+	r.Lock()
+	defer r.Unlock()
+
+	tableResult := r.tableResult(schema, table)
+	tableResult.TableStats = stats
+	tableResult.Cost = cost
+}
+
+// jsonTableResult is the table's check result in json report.
+type jsonTableResult struct {
+	*TableResult
+
+	Error       string  `json:"error,omitempty"`
+	CostSeconds float64 `json:"cost-seconds"`
+}
+
+// jsonReport is the check report in json format.
+type jsonReport struct {
+	Result      string             `json:"result"`
+	PassNum     int32              `json:"pass-num"`
+	FailedNum   int32              `json:"failed-num"`
+	CostSeconds float64            `json:"cost-seconds"`
+	Tables      []*jsonTableResult `json:"tables"`
+
+	UnmatchedObjects []*UnmatchedObject `json:"unmatched-objects,omitempty"`
+}
+
+// junitFailure is the failure or error message of a junit test case.
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Content string `xml:",chardata"`
+}
+
+// junitTestCase is a table's check result in junit report.
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Error     *junitFailure `xml:"error,omitempty"`
+}
+
+// junitTestSuite is the check report in junit format.
+type junitTestSuite struct {
+	XMLName   xml.Name         `xml:"testsuite"`
+	Name      string           `xml:"name,attr"`
+	Tests     int              `xml:"tests,attr"`
+	Failures  int              `xml:"failures,attr"`
+	Errors    int              `xml:"errors,attr"`
+	Time      string           `xml:"time,attr"`
+	TestCases []*junitTestCase `xml:"testcase"`
+}
+
+// WriteJSON writes the check report to w in json format.
+func (r *Report) WriteJSON(w io.Writer) error {
+	r.RLock()
+	defer r.RUnlock()
+
+	report := &jsonReport{
+		Result:      r.Result,
+		PassNum:     r.PassNum,
+		FailedNum:   r.FailedNum,
+		CostSeconds: time.Since(r.startTime).Seconds(),
+
+		UnmatchedObjects: r.UnmatchedObjects,
 	}
 
-	if tableResult, ok := r.TableResults[schema][table]; ok {
-		tableResult.MeetError = err
-	} else {
-		r.TableResults[schema][table] = &TableResult{
-			MeetError: err,
+	for _, result := range r.sortedTableResults() {
+		tableResult := &jsonTableResult{
+			TableResult: result,
+			CostSeconds: result.Cost.Seconds(),
 		}
+		if result.MeetError != nil {
+			tableResult.Error = result.MeetError.Error()
+		}
+		report.Tables = append(report.Tables, tableResult)
 	}
 
-	r.Result = Fail
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return errors.Trace(encoder.Encode(report))
+}
+
+// WriteJUnit writes the check report to w in junit xml format, every table is a test case.
+func (r *Report) WriteJUnit(w io.Writer) error {
+	r.RLock()
+	defer r.RUnlock()
+
+	suite := &junitTestSuite{
+		Name: "sync_diff_inspector",
+		Time: fmt.Sprintf("%.3f", time.Since(r.startTime).Seconds()),
+	}
+
+	for _, result := range r.sortedTableResults() {
+		testCase := &junitTestCase{
+			ClassName: result.Schema,
+			Name:      result.Table,
+			Time:      fmt.Sprintf("%.3f", result.Cost.Seconds()),
+		}
+
+		if result.MeetError != nil {
+			testCase.Error = &junitFailure{
+				Message: "meet error when check table",
+				Content: result.MeetError.Error(),
+			}
+			suite.Errors++
+		} else if !result.StructEqual || !result.DataEqual {
+			content := fmt.Sprintf("struct equal: %v, data equal: %v, chunk num: %d, failed chunk num: %d, repaired chunk num: %d, fixed row num: %d, insert row num: %d, delete row num: %d, update row num: %d, different columns: %v",
+				result.StructEqual, result.DataEqual, result.ChunkNum, result.FailedChunkNum, result.RepairedChunkNum, result.FixedRowNum, result.InsertRowNum, result.DeleteRowNum, result.UpdateRowNum, result.DiffColumnNum)
+			for _, structDiff := range result.StructDiffs {
+				content += "\n" + structDiff.String()
+			}
+			testCase.Failure = &junitFailure{
+				Message: "table is not equal",
+				Content: content,
+			}
+			suite.Failures++
+		}
+
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+
+	// every unmatched object is a failed test case
+	for _, object := range r.UnmatchedObjects {
+		name := object.Name
+		if len(name) == 0 {
+			name = object.Schema
+		}
+		suite.TestCases = append(suite.TestCases, &junitTestCase{
+			ClassName: object.Schema,
+			Name:      name,
+			Time:      fmt.Sprintf("%.3f", 0.0),
+			Failure: &junitFailure{
+				Message: fmt.Sprintf("%s only exists in %s", object.Type, object.OnlyIn),
+				Content: object.String(),
+			},
+		})
+		suite.Failures++
+	}
+	suite.Tests = len(suite.TestCases)
+
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	return errors.Trace(encoder.Encode(suite))
+}
+
+// WriteFile writes the check report to file in the specified format.
+func (r *Report) WriteFile(path, format string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer f.Close()
+
+	switch format {
+	case JSONFormat:
+		err = r.WriteJSON(f)
+	case JUnitFormat:
+		err = r.WriteJUnit(f)
+	default:
+		err = errors.NotSupportedf("report format %s", format)
+	}
+
+	return errors.Trace(err)
 }
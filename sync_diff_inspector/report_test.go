@@ -0,0 +1,111 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/tidb-tools/pkg/diff"
+)
+
+var _ = Suite(&testReportSuite{})
+
+type testReportSuite struct{}
+
+func (s *testReportSuite) newReport() *Report {
+	report := NewReport()
+	report.SetTableStructCheckResult("test", "t1", true)
+	report.SetTableDataCheckResult("test", "t1", true)
+	report.SetTableStats("test", "t1", diff.TableStats{ChunkNum: 2, SuccessChunkNum: 2}, time.Second)
+	report.AddPassNum()
+
+	report.SetTableStructCheckResult("test", "t2", true)
+	report.SetTableDataCheckResult("test", "t2", false)
+	report.SetTableStats("test", "t2", diff.TableStats{ChunkNum: 3, SuccessChunkNum: 2, FailedChunkNum: 1, InsertRowNum: 1, UpdateRowNum: 2}, 2*time.Second)
+	report.AddFailedNum()
+
+	report.SetTableStructCheckResult("test", "t3", false)
+	report.SetTableDataCheckResult("test", "t3", false)
+	report.SetTableStructDiffs("test", "t3", []*diff.TableStructDiff{{
+		SourceTable: "`test`.`t3`",
+		Diffs:       []*diff.StructDiff{{Type: diff.StructDiffColumn, Name: "`id`", Source: "`id` int(11) NOT NULL", Target: "`id` bigint(20) NOT NULL"}},
+		DDLs:        []string{"ALTER TABLE `test`.`t3` MODIFY COLUMN `id` int(11) NOT NULL;"},
+	}})
+	report.AddFailedNum()
+
+	report.SetTableMeetError("test", "t0", errors.New("table not found"))
+	report.AddFailedNum()
+
+	report.AddUnmatchedObject(&UnmatchedObject{Type: ViewObject, Schema: "test", Name: "v1", OnlyIn: TargetSide})
+
+	return report
+}
+
+func (s *testReportSuite) TestWriteJSON(c *C) {
+	report := s.newReport()
+
+	var buf bytes.Buffer
+	c.Assert(report.WriteJSON(&buf), IsNil)
+
+	result := make(map[string]interface{})
+	c.Assert(json.Unmarshal(buf.Bytes(), &result), IsNil)
+	c.Assert(result["result"], Equals, Fail)
+	c.Assert(result["pass-num"], Equals, float64(1))
+	c.Assert(result["failed-num"], Equals, float64(4))
+
+	tables := result["tables"].([]interface{})
+	c.Assert(tables, HasLen, 4)
+	t0 := tables[0].(map[string]interface{})
+	c.Assert(t0["table"], Equals, "t0")
+	c.Assert(t0["error"], Equals, "table not found")
+	t2 := tables[2].(map[string]interface{})
+	c.Assert(t2["table"], Equals, "t2")
+	c.Assert(t2["data-equal"], Equals, false)
+	c.Assert(t2["chunk-num"], Equals, float64(3))
+	c.Assert(t2["failed-chunk-num"], Equals, float64(1))
+	c.Assert(t2["insert-row-num"], Equals, float64(1))
+	c.Assert(t2["update-row-num"], Equals, float64(2))
+	c.Assert(t2["cost-seconds"], Equals, float64(2))
+	c.Assert(t2["struct-diffs"], IsNil)
+	t3 := tables[3].(map[string]interface{})
+	c.Assert(t3["struct-equal"], Equals, false)
+	structDiffs := t3["struct-diffs"].([]interface{})
+	c.Assert(structDiffs, HasLen, 1)
+	c.Assert(structDiffs[0].(map[string]interface{})["ddls"], DeepEquals, []interface{}{"ALTER TABLE `test`.`t3` MODIFY COLUMN `id` int(11) NOT NULL;"})
+
+	objects := result["unmatched-objects"].([]interface{})
+	c.Assert(objects, HasLen, 1)
+	c.Assert(objects[0], DeepEquals, map[string]interface{}{"type": "view", "schema": "test", "name": "v1", "only-in": "target"})
+}
+
+func (s *testReportSuite) TestWriteJUnit(c *C) {
+	report := s.newReport()
+
+	var buf bytes.Buffer
+	c.Assert(report.WriteJUnit(&buf), IsNil)
+
+	output := buf.String()
+	c.Assert(strings.HasPrefix(output, "<?xml"), IsTrue)
+	c.Assert(output, Matches, `(?s).*<testsuite name="sync_diff_inspector" tests="5" failures="3" errors="1".*`)
+	c.Assert(output, Matches, `(?s).*<testcase classname="test" name="t0" time="0.000">\s*<error message="meet error when check table">table not found</error>.*`)
+	c.Assert(output, Matches, `(?s).*<testcase classname="test" name="t1" time="1.000"></testcase>.*`)
+	c.Assert(output, Matches, `(?s).*<testcase classname="test" name="t2" time="2.000">\s*<failure message="table is not equal">.*`)
+	c.Assert(output, Matches, "(?s).*compare with source table `test`.`t3`:&#xA;  column `id` is different, source: `id` int\\(11\\) NOT NULL, target: `id` bigint\\(20\\) NOT NULL</failure>.*")
+	c.Assert(output, Matches, "(?s).*<testcase classname=\"test\" name=\"v1\" time=\"0.000\">\\s*<failure message=\"view only exists in target\">view `test`.`v1` only exists in target</failure>.*")
+}
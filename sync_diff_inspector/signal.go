@@ -0,0 +1,42 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/pkg/diff"
+	"go.uber.org/zap"
+)
+
+// handlePauseSignals pauses checking chunks when receive SIGUSR1, and resumes it when receive SIGUSR2.
+func handlePauseSignals(pauser *diff.Pauser) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGUSR1, syscall.SIGUSR2)
+
+	go func() {
+		for sig := range sigCh {
+			log.Info("receive signal", zap.Stringer("signal", sig))
+			if sig == syscall.SIGUSR1 {
+				pauser.Pause()
+			} else {
+				pauser.Resume()
+			}
+		}
+	}()
+}
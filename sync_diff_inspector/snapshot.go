@@ -0,0 +1,172 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"go.uber.org/zap"
+)
+
+const (
+	// DrainerCheckpointTSOMapping reads the tso mapping from the ts-map saved in drainer's checkpoint table
+	DrainerCheckpointTSOMapping = "drainer-checkpoint"
+	// SyncpointTSOMapping reads the latest tso mapping from the syncpoint table
+	SyncpointTSOMapping = "syncpoint"
+
+	defaultDrainerCheckpointTable = "`tidb_binlog`.`checkpoint`"
+	defaultSyncpointTable         = "`tidb_cdc`.`syncpoint_v1`"
+)
+
+// TSOMapping means the data in source at the primary ts is the same as the data in target at the secondary ts.
+type TSOMapping struct {
+	PrimaryTS   int64
+	SecondaryTS int64
+}
+
+// getTSOMappingFromDrainerCheckpoint reads the ts-map in drainer's checkpoint, for example:
+// {"commitTS":425186328547328001,"ts-map":{"primary-ts":425186328547328001,"secondary-ts":425186329072664577}}
+func getTSOMappingFromDrainerCheckpoint(ctx context.Context, db dbutil.QueryExecutor, tableName string) (*TSOMapping, error) {
+	query := fmt.Sprintf("SELECT `checkPoint` FROM %s", tableName)
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer rows.Close()
+
+	checkpoints := make([]string, 0, 1)
+	for rows.Next() {
+		var checkpoint string
+		if err = rows.Scan(&checkpoint); err != nil {
+			return nil, errors.Trace(err)
+		}
+		checkpoints = append(checkpoints, checkpoint)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	if len(checkpoints) != 1 {
+		return nil, errors.Errorf("should have only one drainer checkpoint in %s, but have %d", tableName, len(checkpoints))
+	}
+
+	cp := struct {
+		TSMap map[string]int64 `json:"ts-map"`
+	}{}
+	if err = json.Unmarshal([]byte(checkpoints[0]), &cp); err != nil {
+		return nil, errors.Annotatef(err, "parse drainer checkpoint %s", checkpoints[0])
+	}
+
+	primaryTS, ok1 := cp.TSMap["primary-ts"]
+	secondaryTS, ok2 := cp.TSMap["secondary-ts"]
+	if !ok1 || !ok2 {
+		return nil, errors.NotFoundf("ts-map in drainer checkpoint %s", checkpoints[0])
+	}
+
+	return &TSOMapping{PrimaryTS: primaryTS, SecondaryTS: secondaryTS}, nil
+}
+
+// getTSOMappingFromSyncpoint reads the latest tso mapping of the changefeed from the syncpoint table,
+// the table saves the syncpoints of all the changefeeds replicating to the target.
+func getTSOMappingFromSyncpoint(ctx context.Context, db dbutil.QueryExecutor, tableName, changefeedID string) (*TSOMapping, error) {
+	query := fmt.Sprintf("SELECT `primary_ts`, `secondary_ts` FROM %s WHERE `cf` = ? ORDER BY CAST(`primary_ts` AS UNSIGNED) DESC LIMIT 1", tableName)
+
+	var primaryTS, secondaryTS string
+	err := db.QueryRowContext(ctx, query, changefeedID).Scan(&primaryTS, &secondaryTS)
+	if err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, errors.NotFoundf("syncpoint of changefeed %s in %s", changefeedID, tableName)
+		}
+		return nil, errors.Trace(err)
+	}
+
+	mapping := &TSOMapping{}
+	if mapping.PrimaryTS, err = strconv.ParseInt(primaryTS, 10, 64); err != nil {
+		return nil, errors.Annotatef(err, "parse primary ts %s", primaryTS)
+	}
+	if mapping.SecondaryTS, err = strconv.ParseInt(secondaryTS, 10, 64); err != nil {
+		return nil, errors.Annotatef(err, "parse secondary ts %s", secondaryTS)
+	}
+
+	return mapping, nil
+}
+
+// getTSOMapping reads the tso mapping from the target database.
+func getTSOMapping(ctx context.Context, db dbutil.QueryExecutor, tp, tableName, changefeedID string) (*TSOMapping, error) {
+	var (
+		mapping *TSOMapping
+		err     error
+	)
+	switch tp {
+	case DrainerCheckpointTSOMapping:
+		if len(tableName) == 0 {
+			tableName = defaultDrainerCheckpointTable
+		}
+		mapping, err = getTSOMappingFromDrainerCheckpoint(ctx, db, tableName)
+	case SyncpointTSOMapping:
+		if len(tableName) == 0 {
+			tableName = defaultSyncpointTable
+		}
+		mapping, err = getTSOMappingFromSyncpoint(ctx, db, tableName, changefeedID)
+	default:
+		return nil, errors.NotSupportedf("tso mapping %s", tp)
+	}
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	// the secondary ts must be a tso already allocated by target, otherwise the snapshot is invalid
+	latestTS, err := dbutil.GetTidbLatestTSO(ctx, db)
+	if err != nil {
+		return nil, errors.Annotate(err, "get target's latest tso")
+	}
+	if mapping.SecondaryTS > latestTS {
+		return nil, errors.Errorf("secondary ts %d in tso mapping is greater than target's latest tso %d", mapping.SecondaryTS, latestTS)
+	}
+
+	return mapping, nil
+}
+
+// setSnapshotByTSOMapping sets the source and target's snapshot to the tso mapping read from target, so can check the data
+// without stopping writing data in source.
+func setSnapshotByTSOMapping(ctx context.Context, cfg *Config) error {
+	targetCfg := cfg.TargetDBCfg.DBConfig
+	targetCfg.Snapshot = ""
+	targetDB, err := dbutil.OpenDB(targetCfg, nil)
+	if err != nil {
+		return errors.Annotatef(err, "create target db %s failed", targetCfg.String())
+	}
+	defer dbutil.CloseDB(targetDB)
+
+	ctx1, cancel1 := context.WithTimeout(ctx, dbutil.DefaultTimeout)
+	defer cancel1()
+
+	mapping, err := getTSOMapping(ctx1, targetDB, cfg.TSOMapping, cfg.TSOMappingTable, cfg.TSOMappingChangefeedID)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	cfg.SourceDBCfg[0].Snapshot = strconv.Quote(strconv.FormatInt(mapping.PrimaryTS, 10))
+	cfg.TargetDBCfg.Snapshot = strconv.Quote(strconv.FormatInt(mapping.SecondaryTS, 10))
+	log.Info("set snapshot by tso mapping", zap.String("tso mapping", cfg.TSOMapping), zap.Int64("primary ts", mapping.PrimaryTS), zap.Int64("secondary ts", mapping.SecondaryTS))
+
+	return nil
+}
@@ -0,0 +1,71 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	. "github.com/pingcap/check"
+)
+
+var _ = Suite(&testSnapshotSuite{})
+
+type testSnapshotSuite struct{}
+
+func (s *testSnapshotSuite) TestGetTSOMapping(c *C) {
+	db, mock, err := sqlmock.New()
+	c.Assert(err, IsNil)
+	ctx := context.Background()
+
+	masterStatusRows := func(ts string) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"File", "Position", "Binlog_Do_DB", "Binlog_Ignore_DB", "Executed_Gtid_Set"}).AddRow("tidb-binlog", ts, "", "", "")
+	}
+
+	// drainer checkpoint
+	mock.ExpectQuery("SELECT `checkPoint` FROM `tidb_binlog`.`checkpoint`").WillReturnRows(
+		sqlmock.NewRows([]string{"checkPoint"}).AddRow(`{"commitTS":100,"ts-map":{"primary-ts":100,"secondary-ts":200}}`))
+	mock.ExpectQuery("SHOW MASTER STATUS").WillReturnRows(masterStatusRows("300"))
+	mapping, err := getTSOMapping(ctx, db, DrainerCheckpointTSOMapping, "", "")
+	c.Assert(err, IsNil)
+	c.Assert(mapping, DeepEquals, &TSOMapping{PrimaryTS: 100, SecondaryTS: 200})
+
+	// drainer checkpoint without ts-map
+	mock.ExpectQuery("SELECT `checkPoint` FROM `test`.`checkpoint`").WillReturnRows(
+		sqlmock.NewRows([]string{"checkPoint"}).AddRow(`{"commitTS":100}`))
+	_, err = getTSOMapping(ctx, db, DrainerCheckpointTSOMapping, "`test`.`checkpoint`", "")
+	c.Assert(err, ErrorMatches, "ts-map in drainer checkpoint .* not found")
+
+	// syncpoint, only read the syncpoints of the changefeed
+	mock.ExpectQuery("SELECT `primary_ts`, `secondary_ts` FROM `tidb_cdc`.`syncpoint_v1` WHERE `cf` = \\? ORDER BY .* DESC LIMIT 1").WithArgs("cf-1").WillReturnRows(
+		sqlmock.NewRows([]string{"primary_ts", "secondary_ts"}).AddRow("110", "210"))
+	mock.ExpectQuery("SHOW MASTER STATUS").WillReturnRows(masterStatusRows("300"))
+	mapping, err = getTSOMapping(ctx, db, SyncpointTSOMapping, "", "cf-1")
+	c.Assert(err, IsNil)
+	c.Assert(mapping, DeepEquals, &TSOMapping{PrimaryTS: 110, SecondaryTS: 210})
+
+	// the secondary ts is not allocated by target
+	mock.ExpectQuery("SELECT `primary_ts`, `secondary_ts`").WillReturnRows(
+		sqlmock.NewRows([]string{"primary_ts", "secondary_ts"}).AddRow("110", "410"))
+	mock.ExpectQuery("SHOW MASTER STATUS").WillReturnRows(masterStatusRows("300"))
+	_, err = getTSOMapping(ctx, db, SyncpointTSOMapping, "", "cf-1")
+	c.Assert(err, ErrorMatches, ".*greater than target's latest tso 300")
+
+	// no syncpoint
+	mock.ExpectQuery("SELECT `primary_ts`, `secondary_ts`").WillReturnRows(sqlmock.NewRows([]string{"primary_ts", "secondary_ts"}))
+	_, err = getTSOMapping(ctx, db, SyncpointTSOMapping, "", "cf-1")
+	c.Assert(err, ErrorMatches, "syncpoint of changefeed cf-1 .* not found")
+
+	c.Assert(mock.ExpectationsWereMet(), IsNil)
+}
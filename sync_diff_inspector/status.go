@@ -0,0 +1,145 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/pkg/diff"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
+)
+
+// status saves the progress of the running check, and serves it and the metrics over http if status-addr is set.
+var status = &statusServer{}
+
+type statusServer struct {
+	sync.RWMutex
+
+	progress *diff.Progress
+	pauser   *diff.Pauser
+
+	// the token required by pause and resume, only the requests from localhost are allowed if is empty
+	token string
+}
+
+func (s *statusServer) setProgress(progress *diff.Progress) {
+	s.Lock()
+	s.progress = progress
+	s.Unlock()
+}
+
+func (s *statusServer) getProgress() *diff.Progress {
+	s.RLock()
+	defer s.RUnlock()
+	return s.progress
+}
+
+func (s *statusServer) setPauser(pauser *diff.Pauser) {
+	s.Lock()
+	s.pauser = pauser
+	s.Unlock()
+}
+
+func (s *statusServer) getPauser() *diff.Pauser {
+	s.RLock()
+	defer s.RUnlock()
+	return s.pauser
+}
+
+// handleProgress returns the progress of the running check in json format.
+func (s *statusServer) handleProgress(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.getProgress().Info()); err != nil {
+		log.Warn("write progress failed", zap.Error(err))
+	}
+}
+
+// pauseState is the pause state of checking chunks.
+type pauseState struct {
+	Paused bool   `json:"paused"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// handlePause pauses checking chunks if pause is true, otherwise resumes it, and then returns the pause state in json format.
+// the chunks being checked are finished before pause, and the chunks are still only checked in the time windows after resume.
+func (s *statusServer) handlePause(pause bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "only support POST method", http.StatusMethodNotAllowed)
+			return
+		}
+		if !s.authorized(r) {
+			http.Error(w, "forbidden, set the status token by header \"Authorization: Bearer <token>\", or send the request from localhost if the token is not set", http.StatusForbidden)
+			return
+		}
+		pauser := s.getPauser()
+		if pauser == nil {
+			http.Error(w, "pause is not supported", http.StatusServiceUnavailable)
+			return
+		}
+
+		if pause {
+			pauser.Pause()
+		} else {
+			pauser.Resume()
+		}
+
+		reason := pauser.PauseReason()
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(pauseState{Paused: len(reason) != 0, Reason: reason}); err != nil {
+			log.Warn("write pause state failed", zap.Error(err))
+		}
+	}
+}
+
+// authorized returns true if the request carries the token, or the request is from localhost if the token is not set.
+func (s *statusServer) authorized(r *http.Request) bool {
+	if len(s.token) != 0 {
+		return subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+s.token)) == 1
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// run starts the http server at addr, it will not return unless meet error. pause and resume require the token if it is
+// not empty, otherwise they are only allowed from localhost.
+func (s *statusServer) run(addr, token string) error {
+	s.token = token
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
+	registry.MustRegister(prometheus.NewGoCollector())
+	diff.RegisterMetrics(registry)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/progress", s.handleProgress)
+	mux.HandleFunc("/pause", s.handlePause(true))
+	mux.HandleFunc("/resume", s.handlePause(false))
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+
+	log.Info("start status server", zap.String("address", addr))
+	return http.ListenAndServe(addr, mux)
+}
@@ -0,0 +1,97 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/tidb-tools/pkg/diff"
+)
+
+var _ = Suite(&testStatusSuite{})
+
+type testStatusSuite struct{}
+
+func (s *testStatusSuite) TestHandleProgress(c *C) {
+	server := &statusServer{}
+	getProgress := func() diff.ProgressInfo {
+		w := httptest.NewRecorder()
+		server.handleProgress(w, httptest.NewRequest(http.MethodGet, "/progress", nil))
+		c.Assert(w.Code, Equals, http.StatusOK)
+		c.Assert(w.Header().Get("Content-Type"), Equals, "application/json")
+
+		info := diff.ProgressInfo{}
+		c.Assert(json.Unmarshal(w.Body.Bytes(), &info), IsNil)
+		return info
+	}
+
+	// no check is running
+	c.Assert(getProgress(), DeepEquals, diff.ProgressInfo{})
+
+	progress := diff.NewProgress(3)
+	progress.DoneTable()
+	server.setProgress(progress)
+	info := getProgress()
+	c.Assert(info.TotalTableNum, Equals, 3)
+	c.Assert(info.DoneTableNum, Equals, 1)
+}
+
+func (s *testStatusSuite) TestHandlePause(c *C) {
+	server := &statusServer{}
+	pause := func(path string, pause bool) (int, pauseState) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, path, nil)
+		r.RemoteAddr = "127.0.0.1:34567"
+		server.handlePause(pause)(w, r)
+
+		state := pauseState{}
+		if w.Code == http.StatusOK {
+			c.Assert(json.Unmarshal(w.Body.Bytes(), &state), IsNil)
+		}
+		return w.Code, state
+	}
+
+	code, _ := pause("/pause", true)
+	c.Assert(code, Equals, http.StatusServiceUnavailable)
+
+	server.setPauser(diff.NewPauser(nil))
+	code, state := pause("/pause", true)
+	c.Assert(code, Equals, http.StatusOK)
+	c.Assert(state, DeepEquals, pauseState{Paused: true, Reason: "paused manually"})
+	code, state = pause("/resume", false)
+	c.Assert(code, Equals, http.StatusOK)
+	c.Assert(state, DeepEquals, pauseState{})
+
+	w := httptest.NewRecorder()
+	server.handlePause(true)(w, httptest.NewRequest(http.MethodGet, "/pause", nil))
+	c.Assert(w.Code, Equals, http.StatusMethodNotAllowed)
+
+	// only allowed from localhost without token
+	w = httptest.NewRecorder()
+	server.handlePause(true)(w, httptest.NewRequest(http.MethodPost, "/pause", nil))
+	c.Assert(w.Code, Equals, http.StatusForbidden)
+
+	// the token is required if it is set
+	server.token = "secret"
+	code, _ = pause("/pause", true)
+	c.Assert(code, Equals, http.StatusForbidden)
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/pause", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	server.handlePause(true)(w, r)
+	c.Assert(w.Code, Equals, http.StatusOK)
+}